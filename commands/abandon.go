@@ -20,6 +20,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/KoviRobi/git-appraise/commands/input"
 	"github.com/KoviRobi/git-appraise/repository"
@@ -92,13 +93,13 @@ func abandonReview(repo repository.Repo, args []string) error {
 	c.Location = &location
 	c.Resolved = &resolved
 
-	var key string
+	var signing *gpg.Config
 	if *abandonSign {
-		key, err := repo.GetUserSigningKey()
+		signing, err = gpg.LoadSigningConfig(repo)
 		if err != nil {
 			return err
 		}
-		err = gpg.Sign(key, &c)
+		err = signing.Sign(&c)
 		if err != nil {
 			return err
 		}
@@ -111,9 +112,11 @@ func abandonReview(repo repository.Repo, args []string) error {
 
 	// Empty target ref indicates that request was abandoned
 	r.Request.TargetRef = ""
+	now := time.Now()
+	r.Request.Modified = FormatDate(&now)
 	// (re)sign the request after clearing out `TargetRef'.
 	if *abandonSign {
-		err = gpg.Sign(key, &r.Request)
+		err = signing.Sign(&r.Request)
 		if err != nil {
 			return err
 		}
@@ -100,11 +100,11 @@ func acceptReview(repo repository.Repo, args []string) error {
 	}
 
 	if *acceptSign {
-		key, err := repo.GetUserSigningKey()
+		signing, err := gpg.LoadSigningConfig(repo)
 		if err != nil {
 			return err
 		}
-		err = gpg.Sign(key, &c)
+		err = signing.Sign(&c)
 		if err != nil {
 			return err
 		}
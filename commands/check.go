@@ -0,0 +1,146 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/commands/output"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/check"
+	"github.com/KoviRobi/git-appraise/review/ci"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+var checkFlagSet = flag.NewFlagSet("check", flag.ExitOnError)
+
+var (
+	checkTimeout = checkFlagSet.Duration("timeout", check.DefaultTimeout, "The timeout of checks that do not specify their own")
+	checkSign    = checkFlagSet.Bool("S", false, "Sign the contents of the CI reports")
+	checkTrust   = checkFlagSet.Bool("trust", false, "Run the checks even if the review changes them from those of its target ref")
+)
+
+// runChecks runs the checks configured for the head of a review in a
+// temporary working tree, and records a CI report for each of them.
+func runChecks(repo repository.Repo, args []string) error {
+	checkFlagSet.Parse(args)
+	args = checkFlagSet.Args()
+
+	r, err := loadCIReview(repo, args)
+	if err != nil {
+		return err
+	}
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+	contents, err := repo.Show(head, check.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("There are no checks configured in %q at %.12s.", check.ConfigPath, head)
+	}
+	checks, err := check.Parse(contents)
+	if err != nil {
+		return fmt.Errorf("Failed to parse %q: %v", check.ConfigPath, err)
+	}
+	if len(checks) == 0 {
+		return fmt.Errorf("There are no checks configured in %q at %.12s.", check.ConfigPath, head)
+	}
+	// The checks are chosen by the author of the review, so only run them
+	// unprompted if they are the same as those already on the target ref.
+	if targetContents, err := repo.Show(r.Request.TargetRef, check.ConfigPath); !*checkTrust && (err != nil || targetContents != contents) {
+		return fmt.Errorf("The checks in %q at %.12s differ from those of %q, and so were chosen by the author of the review.\n"+
+			"Inspect them using \"git show %.12s:%s\", then use -trust to run them anyway.",
+			check.ConfigPath, head, r.Request.TargetRef, head, check.ConfigPath)
+	}
+	user, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "git-appraise-check-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := repo.AddWorktree(dir, head); err != nil {
+		return fmt.Errorf("Failed to check out %.12s: %v", head, err)
+	}
+	defer repo.RemoveWorktree(dir)
+
+	fmt.Printf("Running %d checks on %.12s\n", len(checks), head)
+	results := check.RunAll(checks, dir, *checkTimeout)
+	logs, err := review.StoreCheckLogs(repo, head, results)
+	if err != nil {
+		return err
+	}
+
+	date, err := GetDate("")
+	if err != nil {
+		return err
+	}
+	if date == nil {
+		now := time.Now()
+		date = &now
+	}
+	timestamp := FormatDate(date)
+	var signing *gpg.Config
+	if *checkSign {
+		if signing, err = gpg.LoadSigningConfig(repo); err != nil {
+			return err
+		}
+	}
+	failed := 0
+	for _, result := range results {
+		report := ci.New(result.Check.Agent(user), result.Status, logs[result.Check.Name])
+		report.Timestamp = timestamp
+		if signing != nil {
+			if err := signing.Sign(&report); err != nil {
+				return err
+			}
+		}
+		if err := review.AddCIReport(repo, head, report); err != nil {
+			return err
+		}
+		if result.Status != ci.StatusSuccess {
+			failed++
+		}
+	}
+	output.PrintCheckResults(results, logs)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks did not succeed.", failed, len(results))
+	}
+	return nil
+}
+
+// checkCmd defines the "check" subcommand.
+var checkCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s check [<option>...] [<review-hash>]\n\n", arg0)
+		fmt.Printf("The checks are read from %q at the head of the review, and so are chosen\n"+
+			"by the author of the review. Unless -trust is given, they are only run if they\n"+
+			"are the same as those of the target ref.\n\nOptions:\n", check.ConfigPath)
+		checkFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return runChecks(repo, args)
+	},
+}
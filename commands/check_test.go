@@ -0,0 +1,35 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/check"
+)
+
+func TestCheckRefusesChecksChangedByReview(t *testing.T) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	repo.SetFile(repository.TestCommitI, check.ConfigPath, "[check \"test\"]\n\tcommand = true\n")
+	repo.SetFile(repository.TestCommitJ, check.ConfigPath, "[check \"test\"]\n\tcommand = false\n")
+	err := runChecks(repo, []string{repository.TestCommitG})
+	if err == nil || !strings.Contains(err.Error(), "-trust") {
+		t.Fatalf("Unexpected result of running checks changed by the review: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/commands/output"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/checklist"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+)
+
+var checklistFlagSet = flag.NewFlagSet("checklist", flag.ExitOnError)
+
+var (
+	checklistJSON = checklistFlagSet.Bool("json", false, "Format the output as JSON")
+	checklistSign = checklistFlagSet.Bool("S", false, "Sign the contents of the mark")
+)
+
+// loadChecklistReview loads the review named by the given arguments, or the
+// current review if there are none.
+func loadChecklistReview(repo repository.Repo, args []string) (*review.Review, error) {
+	var r *review.Review
+	var err error
+	if len(args) > 1 {
+		return nil, errors.New("Only the checklist of a single review is supported.")
+	}
+	if len(args) == 1 {
+		r, err = review.Get(repo, args[0])
+	} else {
+		r, err = review.GetCurrent(repo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return nil, errors.New("There is no matching review.")
+	}
+	return r, nil
+}
+
+// markChecklistItems checks or unchecks the given comma-separated items of
+// the checklist of a review.
+func markChecklistItems(repo repository.Repo, numbers string, args []string, checked bool) error {
+	r, err := loadChecklistReview(repo, args)
+	if err != nil {
+		return err
+	}
+	items := r.GetChecklist()
+	if len(items) == 0 {
+		return errors.New("The review does not have a checklist.")
+	}
+	userEmail, err := repo.GetUserEmail()
+	if err != nil {
+		return err
+	}
+	date, err := GetDate("")
+	if err != nil {
+		return err
+	}
+	if date == nil {
+		now := time.Now()
+		date = &now
+	}
+	timestamp := FormatDate(date)
+	var marks []checklist.Mark
+	for _, number := range strings.Split(numbers, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(number))
+		if err != nil || n < 1 || n > len(items) {
+			return fmt.Errorf("There is no checklist item %q; the items are numbered from 1 to %d.", number, len(items))
+		}
+		mark := checklist.New(userEmail, items[n-1], checked)
+		mark.Timestamp = timestamp
+		marks = append(marks, mark)
+	}
+	for _, mark := range marks {
+		if *checklistSign {
+			signing, err := gpg.LoadSigningConfig(repo)
+			if err != nil {
+				return err
+			}
+			if err := signing.Sign(&mark); err != nil {
+				return err
+			}
+		}
+		if err := r.MarkChecklistItem(mark); err != nil {
+			return err
+		}
+	}
+	output.PrintChecklist(r.GetChecklist())
+	return nil
+}
+
+// runChecklist shows, checks, or unchecks the items of the checklist of a review.
+func runChecklist(repo repository.Repo, args []string) error {
+	action := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "show", "check", "uncheck":
+			action, args = args[0], args[1:]
+		}
+	}
+	checklistFlagSet.Parse(args)
+	args = checklistFlagSet.Args()
+
+	if action == "show" {
+		r, err := loadChecklistReview(repo, args)
+		if err != nil {
+			return err
+		}
+		if *checklistJSON {
+			return output.PrintChecklistJSON(r.GetChecklist())
+		}
+		output.PrintChecklist(r.GetChecklist())
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("The %s subcommand requires the numbers of the items to %s.", action, action)
+	}
+	return markChecklistItems(repo, args[0], args[1:], action == "check")
+}
+
+// checklistCmd defines the "checklist" subcommand.
+var checklistCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %[1]s checklist [show] [<option>...] [<review-hash>]\n       %[1]s checklist (check | uncheck) [<option>...] <n>[,<n>...] [<review-hash>]\n\nOptions:\n", arg0)
+		checklistFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return runChecklist(repo, args)
+	},
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/commands/output"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/ci"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+var ciFlagSet = flag.NewFlagSet("ci", flag.ExitOnError)
+
+var (
+	ciStatus = ciFlagSet.String("status", "", "The status of the build and/or test; one of "+strings.Join(ci.Statuses, ", "))
+	ciAgent  = ciFlagSet.String("agent", "", "The name of the build and test runner; defaults to the user's email")
+	ciURL    = ciFlagSet.String("url", "", "A URL for the details of the build and/or test")
+	ciJSON   = ciFlagSet.Bool("json", false, "Format the output as JSON")
+	ciSign   = ciFlagSet.Bool("S", false, "Sign the contents of the report")
+)
+
+// loadCIReview loads the review named by the given arguments, or the current
+// review if there are none.
+func loadCIReview(repo repository.Repo, args []string) (*review.Review, error) {
+	var r *review.Review
+	var err error
+	if len(args) > 1 {
+		return nil, errors.New("Only the CI reports of a single review are supported.")
+	}
+	if len(args) == 1 {
+		r, err = review.Get(repo, args[0])
+	} else {
+		r, err = review.GetCurrent(repo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return nil, errors.New("There is no matching review.")
+	}
+	return r, nil
+}
+
+// reportCI records a CI report for the given commit, or for the head of the
+// current review if no commit is given.
+func reportCI(repo repository.Repo, args []string) error {
+	if len(args) > 1 {
+		return errors.New("Only reporting on a single commit is supported.")
+	}
+	if !ci.IsValidStatus(*ciStatus) {
+		return fmt.Errorf("The status must be one of %q.", ci.Statuses)
+	}
+
+	var commit string
+	var err error
+	if len(args) == 1 {
+		commit, err = repo.GetCommitHash(args[0])
+		if err != nil {
+			return fmt.Errorf("Unknown commit %q: %v", args[0], err)
+		}
+	} else {
+		r, err := loadCIReview(repo, nil)
+		if err != nil {
+			return err
+		}
+		commit, err = r.GetHeadCommit()
+		if err != nil {
+			return err
+		}
+	}
+
+	agent := *ciAgent
+	if agent == "" {
+		agent, err = repo.GetUserEmail()
+		if err != nil {
+			return err
+		}
+	}
+	date, err := GetDate("")
+	if err != nil {
+		return err
+	}
+	if date == nil {
+		now := time.Now()
+		date = &now
+	}
+	report := ci.New(agent, *ciStatus, *ciURL)
+	report.Timestamp = FormatDate(date)
+	if err := report.Validate(); err != nil {
+		return fmt.Errorf("Invalid CI report: %v", err)
+	}
+	if *ciSign {
+		signing, err := gpg.LoadSigningConfig(repo)
+		if err != nil {
+			return err
+		}
+		if err := signing.Sign(&report); err != nil {
+			return err
+		}
+	}
+	return review.AddCIReport(repo, commit, report)
+}
+
+// showCI prints the CI reports for the head of a review.
+func showCI(repo repository.Repo, args []string) error {
+	r, err := loadCIReview(repo, args)
+	if err != nil {
+		return err
+	}
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+	reports, err := review.GetCIReports(repo, head)
+	if err != nil {
+		return err
+	}
+	if *ciJSON {
+		return output.PrintCIReportsJSON(reports)
+	}
+	people, err := identity.Load(repo)
+	if err != nil {
+		return err
+	}
+	output.PrintCIReports(head, people, reports)
+	return nil
+}
+
+// runCI records or shows the CI reports for a review.
+func runCI(repo repository.Repo, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("The ci command requires a subcommand: \"report\" or \"show\".")
+	}
+	action, args := args[0], args[1:]
+	ciFlagSet.Parse(args)
+	args = ciFlagSet.Args()
+
+	switch action {
+	case "report":
+		return reportCI(repo, args)
+	case "show":
+		return showCI(repo, args)
+	}
+	return fmt.Errorf("Unknown ci subcommand %q.", action)
+}
+
+// ciCmd defines the "ci" subcommand.
+var ciCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %[1]s ci report -status <status> [<option>...] [<commit>]\n       %[1]s ci show [<option>...] [<review-hash>]\n\nOptions:\n", arg0)
+		ciFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return runCI(repo, args)
+	},
+}
@@ -24,6 +24,7 @@ import (
 const notesRefPattern = "refs/notes/devtools/*"
 const archiveRefPattern = "refs/devtools/archives/*"
 const commentFilename = "APPRAISE_COMMENT_EDITMSG"
+const requestFilename = "APPRAISE_REQUEST_EDITMSG"
 
 // Command represents the definition of a single command.
 type Command struct {
@@ -41,16 +42,31 @@ func (cmd *Command) Run(repo repository.Repo, args []string) error {
 
 // CommandMap defines all of the available (sub)commands.
 var CommandMap = map[string]*Command{
-	"abandon": abandonCmd,
-	"accept":  acceptCmd,
-	"comment": commentCmd,
-	"list":    listCmd,
-	"pull":    pullCmd,
-	"push":    pushCmd,
-	"rebase":  rebaseCmd,
-	"reject":  rejectCmd,
-	"request": requestCmd,
-	"show":    showCmd,
-	"submit":  submitCmd,
-	"web":     webCmd,
+	"abandon":     abandonCmd,
+	"accept":      acceptCmd,
+	"check":       checkCmd,
+	"checklist":   checklistCmd,
+	"ci":          ciCmd,
+	"comment":     commentCmd,
+	"discuss":     discussCmd,
+	"inbox":       inboxCmd,
+	"list":        listCmd,
+	"mark-read":   markReadCmd,
+	"mark-unread": markUnreadCmd,
+	"pull":        pullCmd,
+	"push":        pushCmd,
+	"quarantine":  quarantineCmd,
+	"rebase":      rebaseCmd,
+	"reject":      rejectCmd,
+	"reopen":      reopenCmd,
+	"request":     requestCmd,
+	"resolve":     resolveCmd,
+	"revert":      revertCmd,
+	"show":        showCmd,
+	"stack":       stackCmd,
+	"stats":       statsCmd,
+	"submit":      submitCmd,
+	"unresolve":   unresolveCmd,
+	"verify":      verifyCmd,
+	"web":         webCmd,
 }
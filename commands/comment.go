@@ -27,6 +27,7 @@ import (
 	"github.com/KoviRobi/git-appraise/review"
 	"github.com/KoviRobi/git-appraise/review/comment"
 	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
 )
 
 var commentFlagSet = flag.NewFlagSet("comment", flag.ExitOnError)
@@ -36,8 +37,11 @@ var (
 	commentMessageFile = commentFlagSet.String("F", "", "Take the comment from the given file. Use - to read the message from the standard input")
 	commentMessage     = commentFlagSet.String("m", "", "Message body of the comment")
 	commentParent      = commentFlagSet.String("p", "", "Parent comment")
+	commentEdit        = commentFlagSet.String("edit", "", "Replace the given comment with a new version")
+	commentRetract     = commentFlagSet.String("retract", "", "Retract the given comment, hiding it by default")
 	commentFile        = commentFlagSet.String("f", "", "File being commented upon")
 	commentDetached    = commentFlagSet.Bool("d", false, "Do not attach the comment to a review")
+	commentSide        = commentFlagSet.String("side", comment.SideNew, "Side of the diff being commented upon; either \"old\" (the base of the review, e.g. for deleted lines) or \"new\" (the head of the review)")
 	commentLgtm        = commentFlagSet.Bool("lgtm", false, "'Looks Good To Me'. Set this to express your approval. This cannot be combined with nmw")
 	commentNmw         = commentFlagSet.Bool("nmw", false, "'Needs More Work'. Set this to express your disapproval. This cannot be combined with lgtm")
 	commentSign        = commentFlagSet.Bool("S", false, "Sign the contents of the comment")
@@ -54,63 +58,112 @@ including) the 4th character of the 7th line, use:
     -l 2+5:7+4`)
 }
 
-// commentHashExists checks if the given comment hash exists in the given comment threads.
-func commentHashExists(hashToFind string, threads []review.CommentThread) bool {
-	for _, thread := range threads {
-		if thread.Hash == hashToFind {
-			return true
-		}
-		if commentHashExists(hashToFind, thread.Children) {
-			return true
-		}
+// findEditedThread returns the comment thread being edited or retracted, if any.
+//
+// Only the author of a comment may edit or retract it.
+func findEditedThread(repo repository.Repo, threads []review.CommentThread) (*review.CommentThread, error) {
+	hash := *commentEdit
+	if hash == "" {
+		hash = *commentRetract
+	}
+	if hash == "" {
+		return nil, nil
+	}
+	thread, err := review.FindThread(threads, hash)
+	if err != nil {
+		return nil, err
+	}
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return nil, err
 	}
-	return false
+	if thread.Comment.Author != userEmail {
+		return nil, fmt.Errorf("Only the author of a comment, %s, can edit or retract it.", thread.Comment.Author)
+	}
+	return thread, nil
 }
 
-func validateArgs(repo repository.Repo, args []string, threads []review.CommentThread) error {
+func validateArgs(repo repository.Repo, args []string, threads []review.CommentThread) (*review.CommentThread, error) {
 	if *commentLgtm && *commentNmw {
-		return errors.New("You cannot combine the flags -lgtm and -nmw.")
+		return nil, errors.New("You cannot combine the flags -lgtm and -nmw.")
+	}
+	if *commentSide != comment.SideOld && *commentSide != comment.SideNew {
+		return nil, fmt.Errorf("Unknown diff side %q; expected %q or %q.", *commentSide, comment.SideOld, comment.SideNew)
+	}
+	if *commentEdit != "" && *commentRetract != "" {
+		return nil, errors.New("You cannot combine the flags -edit and -retract.")
+	}
+	// Detached comments are looked up by their file, so -f is still
+	// required to edit or retract them.
+	if (*commentEdit != "" || *commentRetract != "") &&
+		(*commentParent != "" || (*commentFile != "" && !*commentDetached) || commentLocation.StartLine != 0 || *commentSide != comment.SideNew) {
+		return nil, errors.New("An edited or retracted comment keeps its location; the -p, -f, -l, and -side flags cannot be used.")
 	}
-	if *commentParent != "" && !commentHashExists(*commentParent, threads) {
-		return errors.New("There is no matching parent comment.")
+	if *commentRetract != "" && (*commentMessage != "" || *commentMessageFile != "" || *commentLgtm || *commentNmw) {
+		return nil, errors.New("A retracted comment has no contents; the -m, -F, -lgtm, and -nmw flags cannot be used with -retract.")
+	}
+	if *commentParent != "" {
+		parent, err := review.ResolveCommentHash(threads, *commentParent)
+		if err == review.ErrNoMatchingComment {
+			return nil, errors.New("There is no matching parent comment.")
+		} else if err != nil {
+			return nil, err
+		}
+		*commentParent = parent
+	}
+	edited, err := findEditedThread(repo, threads)
+	if err != nil {
+		return nil, err
+	}
+	if *commentRetract != "" {
+		return edited, nil
 	}
 
 	if *commentMessageFile != "" && *commentMessage == "" {
 		var err error
 		*commentMessage, err = input.FromFile(*commentMessageFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	if *commentMessageFile == "" && *commentMessage == "" {
 		var err error
 		*commentMessage, err = input.LaunchEditor(repo, commentFilename)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	if *commentMessageFile == "" && *commentMessage == "" {
-		return errors.New("No comment")
+		return nil, errors.New("No comment")
 	}
-	return nil
+	return edited, nil
 }
 
-func buildCommentFromFlags(repo repository.Repo, commentedUponCommit string) (*comment.Comment, error) {
-	location := comment.Location{
-		Commit: commentedUponCommit,
-	}
-	if *commentFile != "" {
-		location.Path = *commentFile
-	}
-	location.Range = &commentLocation
-	if err := location.Check(repo); err != nil {
-		return nil, fmt.Errorf("Unable to comment on the given location: %v", err)
-	}
-
+// buildCommentFromFlags builds the comment described by the command line flags.
+//
+// If the 'edited' thread is not nil, then the comment is a new version of
+// that thread's root comment, or a tombstone if it is being retracted.
+func buildCommentFromFlags(repo repository.Repo, commentedUponCommit string, edited *review.CommentThread) (*comment.Comment, error) {
 	userEmail, err := repo.GetUserEmail()
 	if err != nil {
 		return nil, err
 	}
+	var c comment.Comment
+	if edited != nil {
+		if *commentRetract != "" {
+			c = edited.Retract(userEmail)
+		} else {
+			c = edited.Edit(userEmail, *commentMessage)
+		}
+	} else {
+		location, err := buildLocationFromFlags(repo, commentedUponCommit)
+		if err != nil {
+			return nil, err
+		}
+		c = comment.New(userEmail, *commentMessage)
+		c.Location = location
+		c.Parent = *commentParent
+	}
 
 	date, err := GetDate(*commentDate)
 	if err != nil {
@@ -121,9 +174,6 @@ func buildCommentFromFlags(repo repository.Repo, commentedUponCommit string) (*c
 		date = &now
 	}
 	timestamp := FormatDate(date)
-	c := comment.New(userEmail, *commentMessage)
-	c.Location = &location
-	c.Parent = *commentParent
 	if len(timestamp) > 0 {
 		c.Timestamp = timestamp
 	}
@@ -133,11 +183,11 @@ func buildCommentFromFlags(repo repository.Repo, commentedUponCommit string) (*c
 	}
 
 	if *commentSign {
-		key, err := repo.GetUserSigningKey()
+		signing, err := gpg.LoadSigningConfig(repo)
 		if err != nil {
 			return nil, err
 		}
-		err = gpg.Sign(key, &c)
+		err = signing.Sign(&c)
 		if err != nil {
 			return nil, err
 		}
@@ -145,6 +195,24 @@ func buildCommentFromFlags(repo repository.Repo, commentedUponCommit string) (*c
 	return &c, nil
 }
 
+// buildLocationFromFlags builds the location of a new comment on the given commit.
+func buildLocationFromFlags(repo repository.Repo, commentedUponCommit string) (*comment.Location, error) {
+	location := comment.Location{
+		Commit: commentedUponCommit,
+	}
+	if *commentFile != "" {
+		location.Path = *commentFile
+	}
+	if *commentSide == comment.SideOld {
+		location.Side = comment.SideOld
+	}
+	location.Range = &commentLocation
+	if err := location.Check(repo); err != nil {
+		return nil, fmt.Errorf("Unable to comment on the given location: %v", err)
+	}
+	return &location, nil
+}
+
 // commentOnReview adds a comment to the current code review.
 func commentOnReview(repo repository.Repo, args []string) error {
 	var r *review.Review
@@ -166,27 +234,43 @@ func commentOnReview(repo repository.Repo, args []string) error {
 		return errors.New("There is no matching review.")
 	}
 
-	if err := validateArgs(repo, args, r.Comments); err != nil {
+	edited, err := validateArgs(repo, args, r.Comments)
+	if err != nil {
 		return err
 	}
 
-	commentedUponCommit, err := r.GetHeadCommit()
+	var commentedUponCommit string
+	if *commentSide == comment.SideOld {
+		// Comments on the old side of the diff are anchored to the base of
+		// the review, so that deleted lines and pre-rename paths resolve.
+		commentedUponCommit, err = r.GetBaseCommit()
+	} else {
+		commentedUponCommit, err = r.GetHeadCommit()
+	}
 	if err != nil {
 		return err
 	}
 
-	c, err := buildCommentFromFlags(r.Repo, commentedUponCommit)
+	c, err := buildCommentFromFlags(r.Repo, commentedUponCommit, edited)
 	if err != nil {
 		return err
 	}
 	return r.AddComment(*c)
 }
 
-// commentOnPath adds a comment about the given file without attaching it to a review.
+// commentOnPath adds a comment about the given file or directory without
+// attaching it to a review.
 func commentOnPath(repo repository.Repo, args []string) error {
 	if *commentFile == "" {
 		return errors.New("You must specify the containing file for detached comments.")
 	}
+	*commentFile = review.CleanDetachedPath(*commentFile)
+	if *commentFile == "" {
+		return errors.New("Detached comments must be on a file or directory within the repository.")
+	}
+	if *commentSide == comment.SideOld {
+		return errors.New("Detached comments do not have an old side; the -side flag cannot be \"old\" with -d.")
+	}
 
 	if len(args) > 1 {
 		return errors.New("Only commenting on a single location is supported.")
@@ -202,15 +286,28 @@ func commentOnPath(repo repository.Repo, args []string) error {
 		return fmt.Errorf("Failed to resolve the comment location: %v\n", err)
 	}
 
-	commentThreads, err := review.GetDetachedComments(repo, *commentFile)
+	commentThreads, err := review.GetDetachedCommentsAt(repo, commentedUponCommit, *commentFile)
 	if err != nil {
 		return err
 	}
-	if err := validateArgs(repo, args, commentThreads); err != nil {
+	edited, err := validateArgs(repo, args, commentThreads)
+	if err != nil {
 		return err
 	}
+	if *commentParent != "" {
+		// Replies are kept with their parent, which may have been made
+		// on the file under a name it had before being renamed.
+		parent, err := review.FindThread(commentThreads, *commentParent)
+		if err != nil {
+			return err
+		}
+		if parent.Comment.Location != nil && parent.Comment.Location.Path != "" {
+			*commentFile = parent.Comment.Location.Path
+			commentedUponCommit = parent.Comment.Location.Commit
+		}
+	}
 
-	c, err := buildCommentFromFlags(repo, commentedUponCommit)
+	c, err := buildCommentFromFlags(repo, commentedUponCommit, edited)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,292 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/commands/input"
+	"github.com/KoviRobi/git-appraise/commands/output"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/discussion"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+var discussFlagSet = flag.NewFlagSet("discuss", flag.ExitOnError)
+
+var (
+	discussMessageFile = discussFlagSet.String("F", "", "Take the message from the given file. Use - to read the message from the standard input")
+	discussMessage     = discussFlagSet.String("m", "", "Message; when starting a discussion, its first line is the title and the rest is the description")
+	discussLabels      = discussFlagSet.String("l", "", "Comma-separated labels of a new discussion, or the label to list the discussions of")
+	discussParent      = discussFlagSet.String("p", "", "Parent comment of a reply")
+	discussAll         = discussFlagSet.Bool("a", false, "List closed discussions as well as open ones")
+	discussJSON        = discussFlagSet.Bool("json", false, "Format the output as JSON")
+	discussSign        = discussFlagSet.Bool("S", false, "Sign the contents of the discussion or reply")
+)
+
+// getDiscussMessage returns the message given by the -m or -F flags, or
+// prompts for one in an editor if neither was given and 'required' is true.
+func getDiscussMessage(repo repository.Repo, required bool) (string, error) {
+	if *discussMessageFile != "" && *discussMessage == "" {
+		return input.FromFile(*discussMessageFile)
+	}
+	if *discussMessage != "" || !required {
+		return *discussMessage, nil
+	}
+	message, err := input.LaunchEditor(repo, commentFilename)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(message) == "" {
+		return "", errors.New("No message")
+	}
+	return message, nil
+}
+
+// signDiscussionItem signs the given topic or reply if the -S flag was given.
+func signDiscussionItem(repo repository.Repo, s gpg.Signable) error {
+	if !*discussSign {
+		return nil
+	}
+	signing, err := gpg.LoadSigningConfig(repo)
+	if err != nil {
+		return err
+	}
+	return signing.Sign(s)
+}
+
+// loadDiscussion loads the discussion named by the single argument.
+func loadDiscussion(repo repository.Repo, args []string) (*review.Discussion, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Exactly one discussion must be given.")
+	}
+	d, err := review.GetDiscussion(repo, args[0])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the discussion: %v\n", err)
+	}
+	if d == nil {
+		return nil, errors.New("There is no matching discussion.")
+	}
+	return d, nil
+}
+
+// parseLabels splits the comma-separated labels given by the -l flag.
+func parseLabels(labels string) []string {
+	var result []string
+	for _, label := range strings.Split(labels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// newDiscussion starts a new discussion topic.
+func newDiscussion(repo repository.Repo, args []string) error {
+	if len(args) > 0 {
+		return errors.New("The new subcommand does not take any arguments.")
+	}
+	message, err := getDiscussMessage(repo, true)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitN(strings.TrimSpace(message), "\n", 2)
+	title := strings.TrimSpace(lines[0])
+	description := ""
+	if len(lines) > 1 {
+		description = strings.TrimSpace(lines[1])
+	}
+	if title == "" {
+		return errors.New("A discussion must have a title.")
+	}
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	topic := discussion.New(userEmail, title, description, parseLabels(*discussLabels))
+	now := time.Now()
+	topic.Timestamp = FormatDate(&now)
+	if err := signDiscussionItem(repo, &topic); err != nil {
+		return err
+	}
+	revision, err := review.StartDiscussion(repo, topic)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started the discussion %.12s: %s\n", revision, title)
+	return nil
+}
+
+// listDiscussions prints the discussions, optionally filtered by label.
+func listDiscussions(repo repository.Repo, args []string) error {
+	if len(args) > 0 {
+		return errors.New("The list subcommand does not take any arguments.")
+	}
+	all, err := review.ListDiscussions(repo)
+	if err != nil {
+		return err
+	}
+	var discussions []review.Discussion
+	for _, d := range all {
+		if (*discussAll || d.IsOpen()) && (*discussLabels == "" || d.Topic.HasLabel(*discussLabels)) {
+			discussions = append(discussions, d)
+		}
+	}
+	if *discussJSON {
+		return output.PrintDiscussionsJSON(discussions)
+	}
+	output.PrintDiscussions(discussions)
+	return nil
+}
+
+// showDiscussion prints a discussion along with the replies to it.
+func showDiscussion(repo repository.Repo, args []string) error {
+	d, err := loadDiscussion(repo, args)
+	if err != nil {
+		return err
+	}
+	d.CheckSignatures()
+	if *discussJSON {
+		return output.PrintDiscussionJSON(d)
+	}
+	return output.PrintDiscussion(d)
+}
+
+// replyToDiscussion adds a reply to a discussion, or to one of the comments in it.
+func replyToDiscussion(repo repository.Repo, args []string) error {
+	d, err := loadDiscussion(repo, args)
+	if err != nil {
+		return err
+	}
+	parent := ""
+	if *discussParent != "" {
+		parent, err = review.ResolveCommentHash(d.Comments, *discussParent)
+		if err == review.ErrNoMatchingComment {
+			return errors.New("There is no matching parent comment.")
+		} else if err != nil {
+			return err
+		}
+	}
+	message, err := getDiscussMessage(repo, true)
+	if err != nil {
+		return err
+	}
+	return addDiscussionComment(repo, d, parent, message)
+}
+
+// addDiscussionComment adds a reply with the given parent and message to the discussion.
+func addDiscussionComment(repo repository.Repo, d *review.Discussion, parent, message string) error {
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	c := comment.New(userEmail, message)
+	now := time.Now()
+	c.Timestamp = FormatDate(&now)
+	c.Parent = parent
+	if err := signDiscussionItem(repo, &c); err != nil {
+		return err
+	}
+	return d.AddComment(c)
+}
+
+// setDiscussionState closes or reopens a discussion, optionally explaining why.
+func setDiscussionState(repo repository.Repo, args []string, state string) error {
+	d, err := loadDiscussion(repo, args)
+	if err != nil {
+		return err
+	}
+	if d.Topic.State == state {
+		return fmt.Errorf("The discussion is already %s.", state)
+	}
+	message, err := getDiscussMessage(repo, false)
+	if err != nil {
+		return err
+	}
+	if message != "" {
+		if err := addDiscussionComment(repo, d, "", message); err != nil {
+			return err
+		}
+	}
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	topic := d.Topic
+	topic.Author = userEmail
+	topic.State = state
+	now := time.Now()
+	topic.Timestamp = FormatDate(&now)
+	topic.Sig = gpg.Sig{}
+	if err := signDiscussionItem(repo, &topic); err != nil {
+		return err
+	}
+	return d.Update(topic)
+}
+
+// runDiscuss starts, lists, shows, replies to, closes, or reopens discussions.
+func runDiscuss(repo repository.Repo, args []string) error {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	discussFlagSet.Parse(args)
+	args = discussFlagSet.Args()
+
+	switch action {
+	case "new":
+		return newDiscussion(repo, args)
+	case "list":
+		return listDiscussions(repo, args)
+	case "show":
+		return showDiscussion(repo, args)
+	case "reply":
+		return replyToDiscussion(repo, args)
+	case "close":
+		return setDiscussionState(repo, args, discussion.StateClosed)
+	case "reopen":
+		return setDiscussionState(repo, args, discussion.StateOpen)
+	}
+	return fmt.Errorf("Unknown discuss subcommand %q.", action)
+}
+
+// discussCmd defines the "discuss" subcommand.
+var discussCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf(`Usage: %[1]s discuss new [-m <title> | -F <file>] [-l <label>,...] [-S]
+       %[1]s discuss [list] [-a] [-l <label>] [-json]
+       %[1]s discuss show [-json] <discussion>
+       %[1]s discuss reply [-p <comment-hash>] [-m <message> | -F <file>] [-S] <discussion>
+       %[1]s discuss (close | reopen) [-m <message>] [-S] <discussion>
+
+A discussion is named by its "#<n>" number, or by a prefix of its hash.
+
+Options:
+`, arg0)
+		discussFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return runDiscuss(repo, args)
+	},
+}
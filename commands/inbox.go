@@ -0,0 +1,83 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/KoviRobi/git-appraise/commands/output"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/inbox"
+)
+
+var inboxFlagSet = flag.NewFlagSet("inbox", flag.ExitOnError)
+
+var (
+	inboxUser       = inboxFlagSet.String("user", "", "Show the inbox of the given user rather than the current one")
+	inboxJSONOutput = inboxFlagSet.Bool("json", false, "Format the output as JSON")
+)
+
+// showInbox lists the open reviews that need the attention of the user.
+func showInbox(repo repository.Repo, args []string) error {
+	inboxFlagSet.Parse(args)
+	args = inboxFlagSet.Args()
+	if len(args) > 0 {
+		return errors.New("The inbox command does not take any arguments.")
+	}
+
+	people, err := identity.Load(repo)
+	if err != nil {
+		return err
+	}
+	userEmail := *inboxUser
+	if userEmail == "" {
+		userEmail, err = repo.GetUserEmail()
+		if err != nil {
+			return err
+		}
+	}
+	userEmail = people.Canonical(userEmail)
+	in, err := inbox.Get(repo, people, userEmail)
+	if err != nil {
+		return err
+	}
+	if *inboxJSONOutput {
+		b, err := json.MarshalIndent(in, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	output.PrintInbox(in)
+	return nil
+}
+
+// inboxCmd defines the "inbox" subcommand.
+var inboxCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s inbox [<option>...]\n\nOptions:\n", arg0)
+		inboxFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return showInbox(repo, args)
+	},
+}
@@ -78,6 +78,21 @@ func LaunchEditor(repo repository.Repo, fileName string) (string, error) {
 	return string(output), err
 }
 
+// LaunchEditorWithContents launches the default editor configured for the
+// given repo, like LaunchEditor, but with the temporary file first populated
+// with the given contents.
+func LaunchEditorWithContents(repo repository.Repo, fileName, contents string) (string, error) {
+	dataDir, err := repo.GetDataDir()
+	if err != nil {
+		return "", fmt.Errorf("Unable to get repo data directory: %v\n", err)
+	}
+	path := fmt.Sprintf("%s/%s", dataDir, fileName)
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		return "", fmt.Errorf("Error writing the file to edit: %v\n", err)
+	}
+	return LaunchEditor(repo, fileName)
+}
+
 // FromFile loads and returns the contents of a given file. If - is passed
 // through, much like git, it will read from stdin. This can be piped data,
 // unless there is a tty in which case the user will be prompted to enter a
@@ -24,8 +24,26 @@ import (
 	"github.com/KoviRobi/git-appraise/commands/output"
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/query"
+	"github.com/KoviRobi/git-appraise/review/readstate"
 )
 
+const listQueryUsage = `
+Query terms:
+  status:open|pending|accepted|rejected|submitted|abandoned|closed|all
+  reviewer:<user>, requester:<user>  (use "me" for the current user)
+  target:<ref>, source:<ref>
+  has:unresolved|comments|reviewers|dependencies
+  text:<text>, or a bare word
+  after:<yyyy-mm-dd>, before:<yyyy-mm-dd>
+  sort:newest|oldest|updated|target|requester
+  limit:<n>
+Prefix a term with '-' to negate it. Each argument is a single term, so
+quote terms containing spaces for the shell, e.g. 'text:flaky test'.
+A user given with an "@" must match the whole email.
+`
+
 var listFlagSet = flag.NewFlagSet("list", flag.ExitOnError)
 
 var (
@@ -34,11 +52,36 @@ var (
 )
 
 // listReviews lists all extant reviews.
-// TODO(ojarjur): Add more flags for filtering the output (e.g. filtering by reviewer or status).
+//
+// Any remaining arguments are treated as the terms of a query for filtering
+// the listed reviews. Unless the query specifies a status, or the
+// "-a" flag is given, only open reviews are matched.
 func listReviews(repo repository.Repo, args []string) error {
 	listFlagSet.Parse(args)
+	args = listFlagSet.Args()
 	var reviews []review.Summary
-	if *listAll {
+	if len(args) > 0 {
+		userEmail, err := identity.CurrentUser(repo)
+		if err != nil {
+			return err
+		}
+		people, err := identity.Load(repo)
+		if err != nil {
+			return err
+		}
+		q, err := query.ParseTerms(args, userEmail, people)
+		if err != nil {
+			return err
+		}
+		if q.Has(query.KeyStatus) {
+			*listAll = true
+		}
+		if *listAll {
+			reviews = q.Filter(review.ListAll(repo))
+		} else {
+			reviews = q.Filter(review.ListOpen(repo))
+		}
+	} else if *listAll {
 		reviews = review.ListAll(repo)
 	} else {
 		reviews = review.ListOpen(repo)
@@ -51,15 +94,30 @@ func listReviews(repo repository.Repo, args []string) error {
 		fmt.Println(string(b))
 		return nil
 	}
-	output.PrintSummaries(reviews, *listAll)
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	readState, err := readstate.Load(repo)
+	if err != nil {
+		return err
+	}
+	unread := make(map[string]bool)
+	for i := range reviews {
+		if readState.HasUnread(userEmail, &reviews[i]) {
+			unread[reviews[i].Revision] = true
+		}
+	}
+	output.PrintSummariesMarkingUnread(reviews, *listAll, unread)
 	return nil
 }
 
 // listCmd defines the "list" subcommand.
 var listCmd = &Command{
 	Usage: func(arg0 string) {
-		fmt.Printf("Usage: %s list [<option>...]\n\nOptions:\n", arg0)
+		fmt.Printf("Usage: %s list [<option>...] [<query>...]\n\nOptions:\n", arg0)
 		listFlagSet.PrintDefaults()
+		fmt.Print(listQueryUsage)
 	},
 	RunMethod: func(repo repository.Repo, args []string) error {
 		return listReviews(repo, args)
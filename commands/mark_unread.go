@@ -0,0 +1,77 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/readstate"
+)
+
+var markUnreadFlagSet = flag.NewFlagSet("mark-unread", flag.ExitOnError)
+
+// markUnread forgets that the user has seen any of the activity on a review.
+func markUnread(repo repository.Repo, args []string) error {
+	markUnreadFlagSet.Parse(args)
+	args = markUnreadFlagSet.Args()
+
+	var r *review.Review
+	var err error
+	if len(args) > 1 {
+		return errors.New("Only marking a single review as unread is supported.")
+	}
+
+	if len(args) == 1 {
+		r, err = review.Get(repo, args[0])
+	} else {
+		r, err = review.GetCurrent(repo)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return errors.New("There is no matching review.")
+	}
+
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	readState, err := readstate.Load(repo)
+	if err != nil {
+		return err
+	}
+	readState.MarkUnread(userEmail, r.Revision)
+	return readState.Save()
+}
+
+// markUnreadCmd defines the "mark-unread" subcommand.
+var markUnreadCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s mark-unread [<review-hash>]\n\nOptions:\n", arg0)
+		markUnreadFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return markUnread(repo, args)
+	},
+}
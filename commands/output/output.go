@@ -18,13 +18,21 @@ limitations under the License.
 package output
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/check"
+	"github.com/KoviRobi/git-appraise/review/checklist"
+	"github.com/KoviRobi/git-appraise/review/discussion"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/inbox"
 )
 
 const (
@@ -38,24 +46,104 @@ const (
 	commentListTemplate = `Loaded %d comment threads:
 `
 	// Template for printing the summary of a code review.
-	reviewSummaryTemplate = `[%s] %.12s
+	reviewSummaryTemplate = `[%s] %.12s%s
   %s
+`
+	// Template for the numeric alias of a review, which can be used in place of its hash.
+	reviewNumberTemplate = " #%d"
+	// Marker for the aggregate CI status of a review.
+	ciStatusMarkerTemplate = " [ci: %s]"
+	// Marker appended to reviews and comments with activity not yet seen by the user.
+	unreadMarker = " (new)"
+	// Placeholder for the description of a retracted comment.
+	retractedDescription = "(retracted)"
+	// Template for printing the users mentioned in a code review.
+	reviewMentionsTemplate = `  mentions: %s
 `
 	// Template for printing the summary of a code review.
 	reviewDetailsTemplate = `  %q -> %q
   reviewers: %q
   requester: %q
   build status: %s
+`
+	// Template for printing the result of checking the signature on a review request.
+	reviewSignatureTemplate = `  signature: %s
+`
+	// Template for the result of checking the signature on a comment.
+	commentSignatureTemplate = " [%s]"
+	// Template for printing the reviews that a code review depends upon.
+	reviewDependenciesTemplate = `  depends on: %s
+`
+	// Template for printing the review that a code review reverts.
+	reviewRevertsTemplate = `  reverts: %.12s
+`
+	// Template for printing a single review within a stack of reviews.
+	stackEntryTemplate = `%s[%s] %.12s %s
+`
+	// Template for printing a single event in the timeline of a review.
+	timelineEventTemplate = `%-28s  %-9s  %-12.12s  %s  %s
+`
+	// Template for printing the heading of a category of reviews in an inbox.
+	inboxCategoryTemplate = `%s (%d):
+`
+	// Template for printing a comment thread in which the user was mentioned.
+	inboxMentionTemplate = `[%s] %.12s %s
+  %s: %s
 `
 	// Template for printing the location of an inline comment
 	commentLocationTemplate = `%sgit appraise comment -f '%s' %.12s
+`
+	// Template for printing the location of an inline comment on the old side of a diff
+	oldSideCommentLocationTemplate = `%sgit appraise comment -side old -f '%s' %.12s
 `
 	// Template for printing a single comment.
-	commentTemplate = `git appraise comment -p %s %s
+	commentTemplate = `%s -p %s %s
 author: %s
 time:   %s
 status: %s`
 
+	// Command for replying to a comment on a review.
+	reviewReplyCommand = "git appraise comment"
+	// Command for replying to a comment in a discussion.
+	discussionReplyCommand = "git appraise discuss reply"
+
+	// Template for printing the summary of a list of discussions.
+	discussionListTemplate = `Loaded %d discussions:
+`
+	// Template for printing the summary of a discussion.
+	discussionSummaryTemplate = `[%s] #%d %.12s
+  %s
+`
+	// Template for printing the details of a discussion.
+	discussionDetailsTemplate = `  author: %s
+  started: %s
+  replies: %d
+`
+
+	// Template for printing the heading of the checklist of a review.
+	checklistSummaryTemplate = `  checklist (%d of %d done):
+`
+	// Template for printing a single item of the checklist of a review.
+	checklistItemTemplate = `    %d. [%s] %s
+`
+
+	// Template for printing the heading of the CI reports for a commit.
+	ciReportListTemplate = `Loaded %d CI reports for %.12s:
+`
+	// Template for printing the latest CI report from a single agent for a review.
+	ciMatrixTemplate = `    [%s] %s%s
+`
+	// Template for printing the result of running a local check.
+	checkResultTemplate = `[%s] %s (%s): git show %s
+`
+	// Template for printing a single CI report.
+	ciReportTemplate = `  [%s] %s  %s%s
+`
+
+	// Template for printing the heading of the detached comments on a path.
+	detachedCommentsTemplate = `%s (%d threads):
+`
+
 	// Template for displaying the summary of the comment threads for a review
 	commentSummaryTemplate = `  comments (%d threads):
 `
@@ -95,21 +183,79 @@ func getStatusString(r *review.Summary) string {
 
 // PrintSummaries prints single-line summaries of a slice of reviews.
 func PrintSummaries(reviews []review.Summary, listAll bool) {
+	PrintSummariesMarkingUnread(reviews, listAll, nil)
+}
+
+// PrintSummariesMarkingUnread prints single-line summaries of a slice of
+// reviews, marking those whose revisions are in the 'unread' set as new.
+func PrintSummariesMarkingUnread(reviews []review.Summary, listAll bool, unread map[string]bool) {
 	if listAll {
 		fmt.Printf(reviewListTemplate, len(reviews))
 	} else {
 		fmt.Printf(openReviewListTemplate, len(reviews))
 	}
 	for _, r := range reviews {
-		PrintSummary(&r)
+		printSummary(&r, unread[r.Revision])
 	}
 }
 
 // PrintSummary prints a single-line summary of a review.
 func PrintSummary(r *review.Summary) {
+	printSummary(r, false)
+}
+
+func printSummary(r *review.Summary, unread bool) {
 	statusString := getStatusString(r)
+	marker := ""
+	if r.Number > 0 {
+		marker = fmt.Sprintf(reviewNumberTemplate, r.Number)
+	}
+	if ciStatus := r.GetCIStatus(); ciStatus != "" {
+		marker += fmt.Sprintf(ciStatusMarkerTemplate, ciStatus)
+	}
+	if unread {
+		marker += unreadMarker
+	}
 	indentedDescription := strings.Replace(r.Request.Description, "\n", "\n  ", -1)
-	fmt.Printf(reviewSummaryTemplate, statusString, r.Revision, indentedDescription)
+	fmt.Printf(reviewSummaryTemplate, statusString, r.Revision, marker, indentedDescription)
+}
+
+// PrintInbox prints the reviews that need the attention of a user, grouped by category.
+func PrintInbox(in *inbox.Inbox) {
+	for _, category := range []struct {
+		title   string
+		reviews []review.Summary
+	}{
+		{"Awaiting your review", in.AwaitingReview},
+		{"New comments on your reviews", in.NewComments},
+		{"Ready to submit", in.ReadyToSubmit},
+	} {
+		fmt.Printf(inboxCategoryTemplate, category.title, len(category.reviews))
+		for _, r := range category.reviews {
+			PrintSummary(&r)
+		}
+	}
+	fmt.Printf(inboxCategoryTemplate, "Mentions", len(in.Mentions))
+	for _, mention := range in.Mentions {
+		description := strings.SplitN(mention.Review.Request.Description, "\n", 2)[0]
+		comment := strings.SplitN(mention.Thread.Comment.Description, "\n", 2)[0]
+		fmt.Printf(inboxMentionTemplate, getStatusString(&mention.Review), mention.Review.Revision,
+			description, mention.Review.DisplayName(mention.Thread.Comment.Author), comment)
+	}
+}
+
+// PrintStack prints a tree of stacked reviews, one review per line, with
+// each review indented beneath the review that it depends upon.
+func PrintStack(s *review.Stack) {
+	printStackWithIndent(s, "")
+}
+
+func printStackWithIndent(s *review.Stack, indent string) {
+	description := strings.SplitN(s.Summary.Request.Description, "\n", 2)[0]
+	fmt.Printf(stackEntryTemplate, indent, getStatusString(s.Summary), s.Summary.Revision, description)
+	for _, child := range s.Children {
+		printStackWithIndent(child, indent+"  ")
+	}
 }
 
 // reformatTimestamp takes a timestamp string of the form "0123456789" and changes it
@@ -127,7 +273,9 @@ func reformatTimestamp(timestamp string) string {
 }
 
 // showThread prints the detailed output for an entire comment thread.
-func showThread(review string, repo repository.Repo, thread review.CommentThread, indent string) error {
+//
+// Comments whose hashes are in the 'unread' set are marked as new.
+func showThread(review string, repo repository.Repo, people *identity.Registry, thread review.CommentThread, indent string, unread map[string]bool) error {
 	comment := thread.Comment
 	if comment.Location != nil && comment.Location.Path != "" && comment.Location.Range != nil && comment.Location.Range.StartLine > 0 {
 		contents, err := repo.Show(comment.Location.Commit, comment.Location.Path)
@@ -159,15 +307,44 @@ func showThread(review string, repo repository.Repo, thread review.CommentThread
 				firstLine = uint32(minLine)
 			}
 
-			fmt.Printf(commentLocationTemplate, indent, comment.Location.Path, comment.Location.Commit)
+			locationTemplate := commentLocationTemplate
+			if comment.Location.IsOldSide() {
+				locationTemplate = oldSideCommentLocationTemplate
+			}
+			fmt.Printf(locationTemplate, indent, comment.Location.Path, comment.Location.Commit)
 			fmt.Println(indent + "|" + strings.Join(lines[firstLine-1:lastLine], "\n"+indent+"|"))
 		}
 	}
-	return showSubThread(review, repo, thread, indent)
+	return showSubThread(review, repo, people, thread, indent, unread)
+}
+
+// SignatureBadge returns a short description of the result of checking a
+// signature, or the empty string if there was no signature to check.
+func SignatureBadge(people *identity.Registry, result *gpg.Result) string {
+	if result == nil {
+		return ""
+	}
+	switch result.Status {
+	case gpg.StatusUnsigned:
+		return ""
+	case gpg.StatusValid:
+		return "verified"
+	case gpg.StatusUntrustedKey:
+		return "unverified: untrusted key"
+	case gpg.StatusIdentityMismatch:
+		return "unverified: signed by " + people.DisplayName(result.Signer)
+	}
+	return "invalid signature"
 }
 
 // showSubThread prints the given comment (sub)thread, indented by the given prefix string.
-func showSubThread(review string, repo repository.Repo, thread review.CommentThread, indent string) error {
+func showSubThread(review string, repo repository.Repo, people *identity.Registry, thread review.CommentThread, indent string, unread map[string]bool) error {
+	return showReplyableSubThread(reviewReplyCommand, review, people, thread, indent, unread)
+}
+
+// showReplyableSubThread prints the given comment (sub)thread, along with the
+// command for replying to each comment in it.
+func showReplyableSubThread(replyCommand, target string, people *identity.Registry, thread review.CommentThread, indent string, unread map[string]bool) error {
 	statusString := "fyi"
 	if thread.Resolved != nil {
 		if *thread.Resolved {
@@ -176,16 +353,27 @@ func showSubThread(review string, repo repository.Repo, thread review.CommentThr
 			statusString = "needs work"
 		}
 	}
+	if unread[thread.Hash] {
+		statusString += unreadMarker
+	}
 	threadHash := thread.Hash
 	timestamp := reformatTimestamp(thread.Comment.Timestamp)
-	commentSummary := fmt.Sprintf(indent+commentTemplate, threadHash, review, thread.Comment.Author, timestamp, statusString)
+	author := people.DisplayName(thread.Comment.Author)
+	if badge := SignatureBadge(people, thread.Signature); badge != "" {
+		author += fmt.Sprintf(commentSignatureTemplate, badge)
+	}
+	commentSummary := fmt.Sprintf(indent+commentTemplate, replyCommand, threadHash, target, author, timestamp, statusString)
 	indent = indent + "  "
 	indentedSummary := strings.Replace(commentSummary, "\n", "\n"+indent, -1)
-	indentedDescription := Reflow(thread.Comment.Description, indent, 80)
+	description := thread.Comment.Description
+	if thread.Comment.Retracted {
+		description = retractedDescription
+	}
+	indentedDescription := Reflow(description, indent, 80)
 	fmt.Println(indentedSummary)
 	fmt.Println(indentedDescription)
 	for _, child := range thread.Children {
-		err := showSubThread(review, repo, child, indent)
+		err := showReplyableSubThread(replyCommand, target, people, child, indent, unread)
 		if err != nil {
 			return err
 		}
@@ -193,15 +381,35 @@ func showSubThread(review string, repo repository.Repo, thread review.CommentThr
 	return nil
 }
 
+// printCIMatrix prints the latest CI report from each agent for the latest
+// commit in the review, if more than one agent has reported on it.
+func printCIMatrix(r *review.Review) {
+	matrix, err := r.GetCIMatrix()
+	if err != nil || len(matrix) < 2 {
+		return
+	}
+	for _, report := range matrix {
+		status := report.Status
+		if status == "" {
+			status = "unknown"
+		}
+		url := ""
+		if report.URL != "" {
+			url = fmt.Sprintf(" (%s)", report.URL)
+		}
+		fmt.Printf(ciMatrixTemplate, status, report.Agent, url)
+	}
+}
+
 // printAnalyses prints the static analysis results for the latest commit in the review.
 func printAnalyses(r *review.Review) {
 	fmt.Println("  analyses: ", r.GetAnalysesMessage())
 }
 
 // printCommentsWithIndent prints all of the comment threads with the given indent before each line.
-func printCommentsWithIndent(review string, repo repository.Repo, c []review.CommentThread, indent string) error {
+func printCommentsWithIndent(review string, repo repository.Repo, people *identity.Registry, c []review.CommentThread, indent string, unread map[string]bool) error {
 	for _, thread := range c {
-		err := showThread(review, repo, thread, indent)
+		err := showThread(review, repo, people, thread, indent, unread)
 		if err != nil {
 			return err
 		}
@@ -212,7 +420,39 @@ func printCommentsWithIndent(review string, repo repository.Repo, c []review.Com
 // PrintComments prints all of the given comment threads.
 func PrintComments(review string, repo repository.Repo, c []review.CommentThread) error {
 	fmt.Printf(commentListTemplate, len(c))
-	return printCommentsWithIndent(review, repo, c, "  ")
+	// A malformed people file just means that emails are shown as they are.
+	people, _ := identity.Load(repo)
+	return printCommentsWithIndent(review, repo, people, c, "  ", nil)
+}
+
+// PrintDetachedComments prints the given detached comment threads, grouped
+// by the path that they were made on.
+func PrintDetachedComments(repo repository.Repo, commentsByPath map[string][]review.CommentThread) error {
+	var paths []string
+	for path := range commentsByPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	// A malformed people file just means that emails are shown as they are.
+	people, _ := identity.Load(repo)
+	for _, path := range paths {
+		fmt.Printf(detachedCommentsTemplate, path, len(commentsByPath[path]))
+		if err := printCommentsWithIndent("-d", repo, people, commentsByPath[path], "  ", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintDetachedCommentsJSON pretty prints the given detached comment
+// threads, keyed by the path that they were made on, in JSON format.
+func PrintDetachedCommentsJSON(commentsByPath map[string][]review.CommentThread) error {
+	b, err := json.MarshalIndent(commentsByPath, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
 }
 
 // Separates comments into commit message comments and file comments. A line
@@ -220,9 +460,14 @@ func PrintComments(review string, repo repository.Repo, c []review.CommentThread
 // line 0 is invalid. The line numbers indicate the end line rather than the
 // start line (but if there is only start line then it is the start line). This
 // way you can display comments just below what they are commenting on.
+//
+// File comments on the new side of the diff are keyed by the new path of the
+// file, while comments on the old side (e.g. on deleted lines) are put in
+// oldLineThreads and keyed by the old path of the file.
 func SeparateComments(threads []review.CommentThread,
 	commitThreads map[uint32][]review.CommentThread,
-	lineThreads map[string]map[uint32][]review.CommentThread) {
+	lineThreads map[string]map[uint32][]review.CommentThread,
+	oldLineThreads map[string]map[uint32][]review.CommentThread) {
 	for _, thread := range threads {
 		c := thread.Comment
 		var commentLine uint32
@@ -235,14 +480,18 @@ func SeparateComments(threads []review.CommentThread,
 			commentThread = append(commentThread, thread)
 			commitThreads[commentLine] = commentThread
 		} else {
-			fileThread := lineThreads[c.Location.Path]
+			sideThreads := lineThreads
+			if c.Location.IsOldSide() {
+				sideThreads = oldLineThreads
+			}
+			fileThread := sideThreads[c.Location.Path]
 			if fileThread == nil {
 				fileThread = make(map[uint32][]review.CommentThread)
 			}
 			lineThread := fileThread[commentLine]
 			lineThread = append(lineThread, thread)
 			fileThread[commentLine] = lineThread
-			lineThreads[c.Location.Path] = fileThread
+			sideThreads[c.Location.Path] = fileThread
 		}
 	}
 }
@@ -267,7 +516,8 @@ func PrintInlineComments(r *review.Review, diffArgs ...string) error {
 
 	var commitThreads = make(map[uint32][]review.CommentThread)
 	var lineThreads = make(map[string]map[uint32][]review.CommentThread)
-	SeparateComments(r.Summary.Comments, commitThreads, lineThreads)
+	var oldLineThreads = make(map[string]map[uint32][]review.CommentThread)
+	SeparateComments(r.Summary.Comments, commitThreads, lineThreads, oldLineThreads)
 
 	// Line 0 is whole commit message comment
 	// TODO: Print commit message
@@ -276,22 +526,26 @@ func PrintInlineComments(r *review.Review, diffArgs ...string) error {
 	// on the commit message?
 	fmt.Printf(commitTemplate, headCommit, commitDetails.Author, commitDetails.AuthorTime)
 	for _, thread := range commitThreads[0] {
-		showSubThread(r.Summary.Revision, r.Repo, thread, "")
+		showSubThread(r.Summary.Revision, r.Repo, r.People, thread, "", nil)
 	}
 	commitMessageLines := strings.Split(commitMessage, "\n")
 	for i, line := range commitMessageLines {
 		fmt.Println(line)
 		for _, thread := range commitThreads[uint32(i+1)] {
-			showSubThread(r.Summary.Revision, r.Repo, thread, "")
+			showSubThread(r.Summary.Revision, r.Repo, r.People, thread, "", nil)
 		}
 	}
 
 	for _, file := range diffFiles {
-		// TODO: Are comments on old name, new name, or either?
+		// Comments on the new side use the new name of the file, while
+		// comments on the old side use the old (pre-rename) name.
 		fmt.Printf(commentLocationTemplate, "", file.NewName, headCommit)
 		// Line 0 is whole file comment
+		for _, thread := range oldLineThreads[file.OldName][0] {
+			showSubThread(r.Summary.Revision, r.Repo, r.People, thread, "| ", nil)
+		}
 		for _, thread := range lineThreads[file.NewName][0] {
-			showSubThread(r.Summary.Revision, r.Repo, thread, "| ")
+			showSubThread(r.Summary.Revision, r.Repo, r.People, thread, "| ", nil)
 		}
 		var prevLine uint64 = 1
 		for _, frag := range file.Fragments {
@@ -327,11 +581,16 @@ func PrintInlineComments(r *review.Review, diffArgs ...string) error {
 				}
 				fmt.Printf("%s%s\n", line.Op.String(), strings.Trim(line.Line, "\n"))
 
+				indent := strings.Repeat(" ", 2*digits+1)
+				if line.Op == repository.OpContext || line.Op == repository.OpDelete {
+					for _, thread := range oldLineThreads[file.OldName][uint32(lhs-1)] {
+						showSubThread(r.Summary.Revision, r.Repo, r.People, thread, indent+"| ", nil)
+					}
+				}
 				if line.Op == repository.OpContext || line.Op == repository.OpAdd {
 					if rhs-1 >= 0 {
 						for _, thread := range lineThreads[file.NewName][uint32(rhs-1)] {
-							indent := strings.Repeat(" ", 2*digits+1)
-							showSubThread(r.Summary.Revision, r.Repo, thread, indent+"| ")
+							showSubThread(r.Summary.Revision, r.Repo, r.People, thread, indent+"| ", nil)
 						}
 					}
 				}
@@ -343,21 +602,119 @@ func PrintInlineComments(r *review.Review, diffArgs ...string) error {
 }
 
 // printComments prints all of the comments for the review, with snippets of the preceding source code.
-func printComments(r *review.Review) error {
+func printComments(r *review.Review, unread map[string]bool) error {
 	fmt.Printf(commentSummaryTemplate, len(r.Comments))
-	return printCommentsWithIndent(r.Summary.Revision, r.Repo, r.Comments, "    ")
+	return printCommentsWithIndent(r.Summary.Revision, r.Repo, r.People, r.Comments, "    ", unread)
 }
 
 // PrintDetails prints a multi-line overview of a review, including all comments.
 func PrintDetails(r *review.Review) error {
-	PrintSummary(r.Summary)
+	return PrintDetailsWithReadState(r, nil, nil)
+}
+
+// PrintDetailsWithReadState prints a multi-line overview of a review,
+// including the users mentioned in it and all comments, marking the comment
+// threads whose hashes are in the 'unread' set as new.
+func PrintDetailsWithReadState(r *review.Review, mentions []string, unread map[string]bool) error {
+	printSummary(r.Summary, len(unread) > 0)
+	var reviewers []string
+	for _, reviewer := range r.Request.Reviewers {
+		reviewers = append(reviewers, r.DisplayName(reviewer))
+	}
 	fmt.Printf(reviewDetailsTemplate, r.Request.ReviewRef, r.Request.TargetRef,
-		strings.Join(r.Request.Reviewers, ", "),
-		r.Request.Requester, r.GetBuildStatusMessage())
+		strings.Join(reviewers, ", "),
+		r.DisplayName(r.Request.Requester), r.GetBuildStatusMessage())
+	printCIMatrix(r)
+	if badge := SignatureBadge(r.People, r.Signature); badge != "" {
+		fmt.Printf(reviewSignatureTemplate, badge)
+	}
+	if len(r.Request.DependsOn) > 0 {
+		var dependencies []string
+		for _, dependency := range r.Request.DependsOn {
+			dependencies = append(dependencies, fmt.Sprintf("%.12s", dependency))
+		}
+		fmt.Printf(reviewDependenciesTemplate, strings.Join(dependencies, ", "))
+	}
+	if r.Request.Reverts != "" {
+		fmt.Printf(reviewRevertsTemplate, r.Request.Reverts)
+	}
+	if len(mentions) > 0 {
+		fmt.Printf(reviewMentionsTemplate, strings.Join(mentions, ", "))
+	}
 	printAnalyses(r)
-	if err := printComments(r); err != nil {
+	PrintChecklist(r.GetChecklist())
+	if err := printComments(r, unread); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PrintChecklist prints the items of the checklist of a review, if it has any.
+func PrintChecklist(items []checklist.Item) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf(checklistSummaryTemplate, len(items)-len(checklist.Remaining(items)), len(items))
+	for _, item := range items {
+		mark := " "
+		if item.Checked {
+			mark = "x"
+		}
+		fmt.Printf(checklistItemTemplate, item.Number, mark, item.Text)
+	}
+}
+
+// PrintChecklistJSON pretty prints the items of the checklist of a review in JSON format.
+func PrintChecklistJSON(items []checklist.Item) error {
+	if items == nil {
+		items = []checklist.Item{}
+	}
+	b, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
 		return err
 	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// PrintCIReports prints the given CI reports for a commit, one per line.
+func PrintCIReports(commit string, people *identity.Registry, reports []review.CIReport) {
+	fmt.Printf(ciReportListTemplate, len(reports), commit)
+	for _, r := range reports {
+		status := r.Report.Status
+		if status == "" {
+			status = "unknown"
+		}
+		details := r.Report.Agent
+		if r.Report.URL != "" {
+			details += fmt.Sprintf(" (%s)", r.Report.URL)
+		}
+		badge := ""
+		if b := SignatureBadge(people, r.Signature); b != "" {
+			badge = fmt.Sprintf(commentSignatureTemplate, b)
+		}
+		fmt.Printf(ciReportTemplate, status, reformatTimestamp(r.Report.Timestamp), details, badge)
+	}
+}
+
+// PrintCheckResults prints the results of running local checks, along with
+// the command for showing the log of each.
+func PrintCheckResults(results []check.Result, logs map[string]string) {
+	for _, result := range results {
+		fmt.Printf(checkResultTemplate, result.Status, result.Check.Name, result.Duration.Round(time.Millisecond), logs[result.Check.Name])
+	}
+}
+
+// PrintCIReportsJSON pretty prints the given CI reports in JSON format.
+func PrintCIReportsJSON(reports []review.CIReport) error {
+	if reports == nil {
+		reports = []review.CIReport{}
+	}
+	b, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
 	return nil
 }
 
@@ -381,6 +738,32 @@ func PrintJSON(r *review.Review) error {
 	return nil
 }
 
+// PrintTimeline prints the events of a review in chronological order, one per line.
+func PrintTimeline(r *review.Review) {
+	PrintSummary(r.Summary)
+	for _, event := range r.Timeline() {
+		timestamp := "-"
+		if event.Timestamp != "" {
+			timestamp = reformatTimestamp(event.Timestamp)
+		}
+		commit := event.Commit
+		if commit == "" {
+			commit = "-"
+		}
+		fmt.Printf(timelineEventTemplate, timestamp, event.Type, commit, r.DisplayName(event.Author), event.Summary())
+	}
+}
+
+// PrintTimelineJSON pretty prints the events of a review in JSON format.
+func PrintTimelineJSON(r *review.Review) error {
+	b, err := json.MarshalIndent(r.Timeline(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
 // PrintDiff prints the diff of the review.
 func PrintDiff(r *review.Review, diffArgs ...string) error {
 	diff, err := r.GetDiff(diffArgs...)
@@ -390,3 +773,69 @@ func PrintDiff(r *review.Review, diffArgs ...string) error {
 	fmt.Println(diff)
 	return nil
 }
+
+// discussionState returns the state of a discussion, as shown in its summary.
+func discussionState(d *review.Discussion) string {
+	if d.IsOpen() {
+		return discussion.StateOpen
+	}
+	return discussion.StateClosed
+}
+
+// PrintDiscussions prints single-line summaries of a slice of discussions.
+func PrintDiscussions(discussions []review.Discussion) {
+	fmt.Printf(discussionListTemplate, len(discussions))
+	for i := range discussions {
+		printDiscussionSummary(&discussions[i])
+	}
+}
+
+func printDiscussionSummary(d *review.Discussion) {
+	title := d.Topic.Title
+	if len(d.Topic.Labels) > 0 {
+		title += " [" + strings.Join(d.Topic.Labels, ", ") + "]"
+	}
+	fmt.Printf(discussionSummaryTemplate, discussionState(d), d.Number, d.Revision, title)
+}
+
+// PrintDiscussion prints a discussion, along with all of the replies to it.
+func PrintDiscussion(d *review.Discussion) error {
+	printDiscussionSummary(d)
+	author := d.People.DisplayName(d.AllVersions[0].Author)
+	if badge := SignatureBadge(d.People, d.Signature); badge != "" {
+		author += fmt.Sprintf(commentSignatureTemplate, badge)
+	}
+	fmt.Printf(discussionDetailsTemplate, author, reformatTimestamp(d.AllVersions[0].Timestamp), d.Replies())
+	if d.Topic.Description != "" {
+		fmt.Println(Reflow(d.Topic.Description, "    ", 80))
+	}
+	if len(d.Comments) > 0 {
+		fmt.Printf(commentSummaryTemplate, len(d.Comments))
+	}
+	for _, thread := range d.Comments {
+		if err := showReplyableSubThread(discussionReplyCommand, fmt.Sprintf("#%d", d.Number), d.People, thread, "    ", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintDiscussionJSON pretty prints the given discussion in JSON format.
+func PrintDiscussionJSON(d *review.Discussion) error {
+	json, err := d.GetJSON()
+	if err != nil {
+		return err
+	}
+	fmt.Println(json)
+	return nil
+}
+
+// PrintDiscussionsJSON pretty prints the given discussions in JSON format.
+func PrintDiscussionsJSON(discussions []review.Discussion) error {
+	b, err := json.MarshalIndent(discussions, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
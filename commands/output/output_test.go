@@ -0,0 +1,78 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package output
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+)
+
+func TestSeparateComments(t *testing.T) {
+	thread := func(description string, location *comment.Location) review.CommentThread {
+		return review.CommentThread{
+			Hash:    description,
+			Comment: comment.Comment{Description: description, Location: location},
+		}
+	}
+	threads := []review.CommentThread{
+		thread("commit", nil),
+		thread("commit message line", &comment.Location{Range: &comment.Range{StartLine: 2}}),
+		thread("file", &comment.Location{Path: "new.go"}),
+		thread("added line", &comment.Location{Path: "new.go", Range: &comment.Range{StartLine: 3, EndLine: 5}}),
+		thread("explicitly new line", &comment.Location{Path: "new.go", Side: comment.SideNew, Range: &comment.Range{StartLine: 4}}),
+		thread("deleted line", &comment.Location{Path: "old.go", Side: comment.SideOld, Range: &comment.Range{StartLine: 4}}),
+		thread("old file", &comment.Location{Path: "old.go", Side: comment.SideOld}),
+	}
+	commitThreads := make(map[uint32][]review.CommentThread)
+	lineThreads := make(map[string]map[uint32][]review.CommentThread)
+	oldLineThreads := make(map[string]map[uint32][]review.CommentThread)
+	SeparateComments(threads, commitThreads, lineThreads, oldLineThreads)
+
+	hashes := func(threads []review.CommentThread) []string {
+		var result []string
+		for _, thread := range threads {
+			result = append(result, thread.Hash)
+		}
+		return result
+	}
+	for _, tc := range []struct {
+		name     string
+		threads  []review.CommentThread
+		expected []string
+	}{
+		{"whole commit", commitThreads[0], []string{"commit"}},
+		{"commit message line", commitThreads[2], []string{"commit message line"}},
+		{"whole new file", lineThreads["new.go"][0], []string{"file"}},
+		{"new line 4", lineThreads["new.go"][4], []string{"explicitly new line"}},
+		{"new line 5", lineThreads["new.go"][5], []string{"added line"}},
+		{"whole old file", oldLineThreads["old.go"][0], []string{"old file"}},
+		{"deleted line 4", oldLineThreads["old.go"][4], []string{"deleted line"}},
+	} {
+		if got := hashes(tc.threads); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Unexpected threads for the %s: %v", tc.name, got)
+		}
+	}
+	if _, ok := lineThreads["old.go"]; ok {
+		t.Errorf("Old side threads were also kept with the new side threads: %v", lineThreads["old.go"])
+	}
+	if _, ok := oldLineThreads["new.go"]; ok {
+		t.Errorf("New side threads were also kept with the old side threads: %v", oldLineThreads["new.go"])
+	}
+}
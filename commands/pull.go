@@ -53,24 +53,24 @@ func pull(repo repository.Repo, args []string) error {
 			archiveRefPattern)
 	}
 
-	// Otherwise, we collect the fetched reviewed revisions (their hashes), get
-	// their reviews, and then one by one, verify them. If we make it through
-	// the set, _then_ we merge the remote reference into the local branch.
+	// Otherwise, we collect the fetched reviewed revisions (their hashes), and
+	// then one by one, verify their reviews. Those that fail verification are
+	// moved into quarantine, and _then_ we merge the remote reference into the
+	// local branch, so that one bad review does not hold back all of the others.
 	revisions, err := repo.FetchAndReturnNewReviewHashes(remote,
 		notesRefPattern, archiveRefPattern)
 	if err != nil {
 		return err
 	}
+	quarantined := 0
 	for _, revision := range revisions {
-		rvw, err := review.GetSummaryViaRefs(repo,
-			"refs/notes/"+remote+"/devtools/reviews",
-			"refs/notes/"+remote+"/devtools/discuss", revision)
-		if err != nil {
-			return err
-		}
-		err = rvw.Verify()
-		if err != nil {
-			return err
+		if err := review.VerifyFetched(repo, remote, revision); err != nil {
+			if err := review.Quarantine(repo, remote, revision); err != nil {
+				return fmt.Errorf("Failed to quarantine the review %s: %v", revision, err)
+			}
+			fmt.Printf("quarantined review: %s: %v\n", revision, err)
+			quarantined++
+			continue
 		}
 		fmt.Println("verified review:", revision)
 	}
@@ -79,7 +79,13 @@ func pull(repo repository.Repo, args []string) error {
 	if err != nil {
 		return err
 	}
-	return repo.MergeArchives(remote, archiveRefPattern)
+	if err := repo.MergeArchives(remote, archiveRefPattern); err != nil {
+		return err
+	}
+	if quarantined > 0 {
+		fmt.Printf("%d reviews were quarantined; use \"git appraise quarantine list\" to see them.\n", quarantined)
+	}
+	return nil
 }
 
 var pullCmd = &Command{
@@ -0,0 +1,140 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+)
+
+var quarantineFlagSet = flag.NewFlagSet("quarantine", flag.ExitOnError)
+
+var (
+	quarantineRemote = quarantineFlagSet.String("remote", "", "Only consider the notes quarantined from the given remote")
+)
+
+// findQuarantined returns the quarantined reviews whose revisions start with
+// the given name.
+func findQuarantined(repo repository.Repo, name string) ([]review.QuarantinedReview, error) {
+	if hash, err := repo.GetCommitHash(name); err == nil {
+		name = hash
+	}
+	all, err := review.ListQuarantined(repo)
+	if err != nil {
+		return nil, err
+	}
+	var matches []review.QuarantinedReview
+	revisions := make(map[string]bool)
+	for _, q := range all {
+		if *quarantineRemote != "" && q.Remote != *quarantineRemote {
+			continue
+		}
+		if strings.HasPrefix(q.Revision, name) {
+			matches = append(matches, q)
+			revisions[q.Revision] = true
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("There is no quarantined review matching %q.", name)
+	}
+	if len(revisions) > 1 {
+		var candidates []string
+		for _, q := range matches {
+			candidates = append(candidates, fmt.Sprintf("[%s] %s", q.Remote, q.Revision))
+		}
+		return nil, fmt.Errorf("%q matches multiple quarantined reviews:\n  %s", name, strings.Join(candidates, "\n  "))
+	}
+	return matches, nil
+}
+
+// listQuarantined prints the quarantined reviews, along with the reasons they
+// were quarantined.
+func listQuarantined(repo repository.Repo) error {
+	all, err := review.ListQuarantined(repo)
+	if err != nil {
+		return err
+	}
+	for _, q := range all {
+		if *quarantineRemote != "" && q.Remote != *quarantineRemote {
+			continue
+		}
+		fmt.Printf("[%s] %.12s %s\n", q.Remote, q.Revision, q.Title(repo))
+		if err := q.Verify(repo); err != nil {
+			fmt.Printf("  %v\n", err)
+		} else {
+			fmt.Println("  now verifies")
+		}
+	}
+	return nil
+}
+
+// runQuarantine lists, accepts, or drops the notes held back by "pull -verify-signatures".
+func runQuarantine(repo repository.Repo, args []string) error {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	quarantineFlagSet.Parse(args)
+	args = quarantineFlagSet.Args()
+
+	if action == "list" {
+		if len(args) > 0 {
+			return errors.New("The list subcommand does not take any arguments.")
+		}
+		return listQuarantined(repo)
+	}
+	if action != "accept" && action != "drop" {
+		return fmt.Errorf("Unknown quarantine subcommand %q.", action)
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("The %s subcommand takes exactly one review.", action)
+	}
+	matches, err := findQuarantined(repo, args[0])
+	if err != nil {
+		return err
+	}
+	for _, q := range matches {
+		if action == "accept" {
+			if err := q.Accept(repo); err != nil {
+				return err
+			}
+			fmt.Printf("Accepted the notes for %s from %s\n", q.Revision, q.Remote)
+		} else {
+			if err := q.Drop(repo); err != nil {
+				return err
+			}
+			fmt.Printf("Dropped the notes for %s from %s\n", q.Revision, q.Remote)
+		}
+	}
+	return nil
+}
+
+// quarantineCmd defines the "quarantine" subcommand.
+var quarantineCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %[1]s quarantine [list] [<option>...]\n       %[1]s quarantine (accept | drop) [<option>...] <review-hash>\n\nOptions:\n", arg0)
+		quarantineFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return runQuarantine(repo, args)
+	},
+}
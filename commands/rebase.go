@@ -31,6 +31,12 @@ var (
 	rebaseArchive = rebaseFlagSet.Bool("archive", true, "Prevent the original commit from being garbage collected.")
 	rebaseSign    = rebaseFlagSet.Bool("S", false,
 		"Sign the contents of the request after the rebase")
+	rebaseInteractive = rebaseFlagSet.Bool("interactive", true,
+		"Edit the list of commits being rebased. If false, the rebase runs without prompting.")
+	rebaseContinue = rebaseFlagSet.Bool("continue", false,
+		"Finish a rebase of a review that stopped, e.g. after resolving conflicts.")
+	rebaseAbort = rebaseFlagSet.Bool("abort", false,
+		"Give up on a rebase of a review that stopped, restoring the review to its previous state.")
 )
 
 // Validate that the user's request to rebase a review makes sense.
@@ -78,20 +84,36 @@ func rebaseReview(repo repository.Repo, args []string) error {
 	rebaseFlagSet.Parse(args)
 	args = rebaseFlagSet.Args()
 
+	if *rebaseContinue || *rebaseAbort {
+		if *rebaseContinue && *rebaseAbort {
+			return errors.New("Only one of -continue or -abort is allowed.")
+		}
+		if len(args) > 0 {
+			return errors.New("A review cannot be specified when continuing or aborting a rebase.")
+		}
+		if *rebaseAbort {
+			return review.AbortRebase(repo)
+		}
+		return review.ContinueRebase(repo)
+	}
+
 	r, err := validateRebaseRequest(repo, args)
 	if err != nil {
 		return err
 	}
-	if *rebaseSign {
-		return r.RebaseAndSign(*rebaseArchive)
-	}
-	return r.Rebase(*rebaseArchive)
+	return r.RebaseWithOptions(review.RebaseOptions{
+		Archive:     *rebaseArchive,
+		Sign:        *rebaseSign,
+		Interactive: *rebaseInteractive,
+	})
 }
 
 // rebaseCmd defines the "rebase" subcommand.
 var rebaseCmd = &Command{
 	Usage: func(arg0 string) {
-		fmt.Printf("Usage: %s rebase [<option>...] [<review-hash>]\n\nOptions:\n", arg0)
+		fmt.Printf("Usage: %s rebase [<option>...] [<review-hash>]\n", arg0)
+		fmt.Printf("       %s rebase -continue\n", arg0)
+		fmt.Printf("       %s rebase -abort\n\nOptions:\n", arg0)
 		rebaseFlagSet.PrintDefaults()
 	},
 	RunMethod: func(repo repository.Repo, args []string) error {
@@ -95,11 +95,11 @@ func rejectReview(repo repository.Repo, args []string) error {
 	c.Location = &location
 	c.Resolved = &resolved
 	if *rejectSign {
-		key, err := repo.GetUserSigningKey()
+		signing, err := gpg.LoadSigningConfig(repo)
 		if err != nil {
 			return err
 		}
-		err = gpg.Sign(key, &c)
+		err = signing.Sign(&c)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,138 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/commands/input"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+var reopenFlagSet = flag.NewFlagSet("reopen", flag.ExitOnError)
+
+var (
+	reopenMessageFile = reopenFlagSet.String("F", "", "Take the comment from the given file. Use - to read the message from the standard input")
+	reopenMessage     = reopenFlagSet.String("m", "", "Message to attach to the review")
+	reopenTarget      = reopenFlagSet.String("target", "", "Target ref for the reopened review; defaults to the one it had before being abandoned")
+
+	reopenSign = reopenFlagSet.Bool("S", false,
+		"Sign the contents of the reopened request")
+)
+
+// reopenReview restores the target ref of an abandoned code review.
+func reopenReview(repo repository.Repo, args []string) error {
+	reopenFlagSet.Parse(args)
+	args = reopenFlagSet.Args()
+
+	var r *review.Review
+	var err error
+	if len(args) > 1 {
+		return errors.New("Only reopening a single review is supported.")
+	}
+
+	if len(args) == 1 {
+		r, err = review.Get(repo, args[0])
+	} else {
+		r, err = review.GetCurrent(repo)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return errors.New("There is no matching review.")
+	}
+	if !r.IsAbandoned() {
+		return errors.New("The review has not been abandoned.")
+	}
+
+	target := *reopenTarget
+	if target == "" {
+		target = r.PreviousTargetRef()
+	}
+	if target == "" {
+		return errors.New("The review never had a target ref; use -target to specify one.")
+	}
+	if err := repo.VerifyGitRef(target); err != nil {
+		return err
+	}
+
+	if *reopenMessageFile != "" && *reopenMessage == "" {
+		*reopenMessage, err = input.FromFile(*reopenMessageFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	userEmail, err := repo.GetUserEmail()
+	if err != nil {
+		return err
+	}
+	var signing *gpg.Config
+	if *reopenSign {
+		signing, err = gpg.LoadSigningConfig(repo)
+		if err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+
+	if *reopenMessage != "" {
+		c := comment.New(userEmail, *reopenMessage)
+		c.Timestamp = FormatDate(&now)
+		if *reopenSign {
+			if err := signing.Sign(&c); err != nil {
+				return err
+			}
+		}
+		if err := r.AddComment(c); err != nil {
+			return err
+		}
+	}
+
+	r.Request.TargetRef = target
+	r.Request.Modified = FormatDate(&now)
+	if *reopenSign {
+		if err := signing.Sign(&r.Request); err != nil {
+			return err
+		}
+	}
+	note, err := r.Request.Write()
+	if err != nil {
+		return err
+	}
+	return repo.AppendNote(request.Ref, r.Revision, note)
+}
+
+// reopenCmd defines the "reopen" subcommand.
+var reopenCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s reopen [<option>...] [<review-hash>]\n\nOptions:\n", arg0)
+		reopenFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return reopenReview(repo, args)
+	},
+}
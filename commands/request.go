@@ -25,7 +25,10 @@ import (
 
 	"github.com/KoviRobi/git-appraise/commands/input"
 	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/checklist"
 	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
 	"github.com/KoviRobi/git-appraise/review/request"
 )
 
@@ -49,6 +52,8 @@ var (
 	requestAllowUncommitted = requestFlagSet.Bool("allow-uncommitted", false, "Allow uncommitted local changes.")
 	requestSign             = requestFlagSet.Bool("S", false, "GPG sign the content of the request")
 	requestDate             = requestFlagSet.String("date", "", "request date")
+	requestDepends          = requestFlagSet.String("depends", "", "Comma-separated list of reviews that this review is stacked on top of")
+	requestReverts          = requestFlagSet.String("reverts", "", "Submitted review whose changes this review reverts")
 )
 
 // Build the template review request based solely on the parsed flag values.
@@ -81,23 +86,105 @@ func buildRequestFromFlags(requester string) (request.Request, error) {
 	if len(timestamp) > 0 {
 		req.Timestamp = timestamp
 	}
+	if len(*requestDepends) > 0 {
+		for _, dependency := range strings.Split(*requestDepends, ",") {
+			req.DependsOn = append(req.DependsOn, strings.TrimSpace(dependency))
+		}
+	}
+	req.Reverts = *requestReverts
 	return req, nil
 }
 
+// Resolve the review that the request reverts, if any.
+//
+// This replaces the reverted review in the request with its full revision.
+func resolveReverts(repo repository.Repo, r *request.Request) error {
+	if r.Reverts == "" {
+		return nil
+	}
+	reverted, err := review.Get(repo, r.Reverts)
+	if err != nil {
+		return fmt.Errorf("Failed to load the reverted review %q: %v", r.Reverts, err)
+	}
+	if reverted == nil {
+		return fmt.Errorf("There is no review for %q to revert.", r.Reverts)
+	}
+	if !reverted.Submitted {
+		return fmt.Errorf("The review %.12s has not been submitted, so it cannot be reverted.", reverted.Revision)
+	}
+	r.Reverts = reverted.Revision
+	return nil
+}
+
+// expandReviewers replaces any teams (e.g. "@core") in the given reviewers
+// with their members, and the remaining reviewers with their canonical emails.
+func expandReviewers(repo repository.Repo, reviewers []string) ([]string, error) {
+	if len(reviewers) == 0 {
+		return nil, nil
+	}
+	people, err := identity.Load(repo)
+	if err != nil {
+		return nil, err
+	}
+	return people.Expand(reviewers)
+}
+
+// Resolve the reviews that the request depends upon.
+//
+// This replaces the dependencies in the request with the full revisions of
+// the corresponding reviews, and returns the head commit of the first one,
+// which the new review is stacked on top of.
+func resolveDependencies(repo repository.Repo, r *request.Request) (string, error) {
+	var dependencies []string
+	var stackedOn string
+	for _, dependency := range r.DependsOn {
+		dependencyReview, err := review.Get(repo, dependency)
+		if err != nil {
+			return "", fmt.Errorf("Failed to load the review %q depended upon: %v", dependency, err)
+		}
+		if dependencyReview == nil {
+			return "", fmt.Errorf("There is no review for the revision %q depended upon.", dependency)
+		}
+		if dependencyReview.IsAbandoned() {
+			return "", fmt.Errorf("The review %.12s depended upon was abandoned.", dependencyReview.Revision)
+		}
+		if dependencyReview.Request.TargetRef != r.TargetRef {
+			return "", fmt.Errorf("The review %.12s depended upon targets %q rather than %q.",
+				dependencyReview.Revision, dependencyReview.Request.TargetRef, r.TargetRef)
+		}
+		if stackedOn == "" {
+			stackedOn, err = dependencyReview.GetHeadCommit()
+			if err != nil {
+				return "", err
+			}
+		}
+		dependencies = append(dependencies, dependencyReview.Revision)
+	}
+	r.DependsOn = dependencies
+	return stackedOn, nil
+}
+
 // Get the commit at which the review request should be anchored.
-func getReviewCommit(repo repository.Repo, r request.Request, args []string) (string, string, error) {
+//
+// If the review is stacked on top of another review, then stackedOn is the
+// head commit of that review, and is used in place of the target ref.
+func getReviewCommit(repo repository.Repo, r request.Request, stackedOn string, args []string) (string, string, error) {
 	if len(args) > 1 {
 		return "", "", errors.New("Only updating a single review is supported.")
 	}
+	upstream := r.TargetRef
+	if stackedOn != "" {
+		upstream = stackedOn
+	}
 	if len(args) == 1 {
-		base, err := repo.MergeBase(r.TargetRef, args[0])
+		base, err := repo.MergeBase(upstream, args[0])
 		if err != nil {
 			return "", "", err
 		}
 		return args[0], base, nil
 	}
 
-	base, err := repo.MergeBase(r.TargetRef, r.ReviewRef)
+	base, err := repo.MergeBase(upstream, r.ReviewRef)
 	if err != nil {
 		return "", "", err
 	}
@@ -111,6 +198,28 @@ func getReviewCommit(repo repository.Repo, r request.Request, args []string) (st
 	return reviewCommits[0], base, nil
 }
 
+// fillRequestTemplate prompts for the description of a new review, in an
+// editor pre-populated with the given commit message followed by the request
+// template of the target ref.
+//
+// If the target ref has no request template, or the review already exists,
+// then the commit message is used as it is.
+func fillRequestTemplate(repo repository.Repo, targetRef, reviewCommit, commitMessage string) (string, error) {
+	template, err := repo.Show(targetRef, checklist.TemplatePath)
+	if err != nil || len(request.ParseAllValid(repo.GetNotes(request.Ref, reviewCommit))) > 0 {
+		return commitMessage, nil
+	}
+	contents := strings.TrimRight(commitMessage, "\n") + "\n\n" + template
+	description, err := input.LaunchEditorWithContents(repo, requestFilename, contents)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(description) == "" {
+		return "", errors.New("Aborting the review request due to an empty description.")
+	}
+	return description, nil
+}
+
 // Create a new code review request.
 //
 // The "args" parameter is all of the command line arguments that followed the subcommand.
@@ -138,6 +247,9 @@ func requestReview(repo repository.Repo, args []string) error {
 	if err != nil {
 		return err
 	}
+	if r.Reviewers, err = expandReviewers(repo, r.Reviewers); err != nil {
+		return err
+	}
 	if r.ReviewRef == "HEAD" {
 		headRef, err := repo.GetHeadRef()
 		if err != nil {
@@ -152,7 +264,14 @@ func requestReview(repo repository.Repo, args []string) error {
 		return err
 	}
 
-	reviewCommit, baseCommit, err := getReviewCommit(repo, r, args)
+	stackedOn, err := resolveDependencies(repo, &r)
+	if err != nil {
+		return err
+	}
+	if err := resolveReverts(repo, &r); err != nil {
+		return err
+	}
+	reviewCommit, baseCommit, err := getReviewCommit(repo, r, stackedOn, args)
 	if err != nil {
 		return err
 	}
@@ -162,14 +281,17 @@ func requestReview(repo repository.Repo, args []string) error {
 		if err != nil {
 			return err
 		}
-		r.Description = description
+		r.Description, err = fillRequestTemplate(repo, r.TargetRef, reviewCommit, description)
+		if err != nil {
+			return err
+		}
 	}
 	if *requestSign {
-		key, err := repo.GetUserSigningKey()
+		signing, err := gpg.LoadSigningConfig(repo)
 		if err != nil {
 			return err
 		}
-		err = gpg.Sign(key, &r)
+		err = signing.Sign(&r)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,134 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/commands/input"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+var resolveFlagSet = flag.NewFlagSet("resolve", flag.ExitOnError)
+
+var (
+	resolveMessageFile = resolveFlagSet.String("F", "", "Take the comment from the given file. Use - to read the message from the standard input")
+	resolveMessage     = resolveFlagSet.String("m", "Resolved.", "Message to attach to the resolution")
+	resolveSign        = resolveFlagSet.Bool("S", false, "Sign the contents of the comment")
+)
+
+// setThreadResolved replies to the given comment thread with the given
+// resolved bit.
+//
+// Any earlier replies from the current user to that thread with the opposite
+// resolved bit are retracted, as otherwise they would still count towards the
+// status of the thread.
+func setThreadResolved(repo repository.Repo, args []string, resolved bool, message, messageFile string, sign bool) error {
+	if len(args) < 1 {
+		return errors.New("You must specify the comment to resolve or unresolve.")
+	}
+	if len(args) > 2 {
+		return errors.New("Only resolving a single comment is supported.")
+	}
+
+	var r *review.Review
+	var err error
+	if len(args) == 2 {
+		r, err = review.Get(repo, args[1])
+	} else {
+		r, err = review.GetCurrent(repo)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return errors.New("There is no matching review.")
+	}
+
+	thread, err := review.FindThread(r.Comments, args[0])
+	if err != nil {
+		return err
+	}
+	if messageFile != "" {
+		if message, err = input.FromFile(messageFile); err != nil {
+			return err
+		}
+	}
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	timestamp := FormatDate(&now)
+	var comments []comment.Comment
+	for i := range thread.Children {
+		child := &thread.Children[i]
+		if child.Comment.Author == userEmail && child.Comment.Resolved != nil && *child.Comment.Resolved != resolved {
+			retraction := child.Retract(userEmail)
+			retraction.Timestamp = timestamp
+			comments = append(comments, retraction)
+		}
+	}
+	reply := comment.New(userEmail, message)
+	reply.Timestamp = timestamp
+	reply.Parent = thread.Hash
+	reply.Location = &comment.Location{Commit: head}
+	reply.Resolved = &resolved
+	comments = append(comments, reply)
+
+	var signing *gpg.Config
+	if sign {
+		if signing, err = gpg.LoadSigningConfig(repo); err != nil {
+			return err
+		}
+	}
+	for _, c := range comments {
+		if sign {
+			if err := signing.Sign(&c); err != nil {
+				return err
+			}
+		}
+		if err := r.AddComment(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveCmd defines the "resolve" subcommand.
+var resolveCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s resolve [<option>...] <comment-hash> [<review-hash>]\n\nOptions:\n", arg0)
+		resolveFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		resolveFlagSet.Parse(args)
+		return setThreadResolved(repo, resolveFlagSet.Args(), true, *resolveMessage, *resolveMessageFile, *resolveSign)
+	},
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+var revertFlagSet = flag.NewFlagSet("revert", flag.ExitOnError)
+
+var (
+	revertMessage   = revertFlagSet.String("m", "", "Description of the review request for the revert")
+	revertReviewers = revertFlagSet.String("r", "", "Comma-separated list of reviewers; defaults to those of the reverted review")
+	revertBranch    = revertFlagSet.String("branch", "", "Name of the branch to create for the revert; defaults to \"revert-<review-hash>\"")
+	revertTarget    = revertFlagSet.String("target", "", "Target ref for the revert; defaults to that of the reverted review")
+
+	revertSign = revertFlagSet.Bool("S", false,
+		"Sign the contents of the review request for the revert")
+)
+
+// revertReview creates commits reverting a submitted review on a new
+// branch, and requests a review of them.
+func revertReview(repo repository.Repo, args []string) error {
+	revertFlagSet.Parse(args)
+	args = revertFlagSet.Args()
+
+	if len(args) != 1 {
+		return errors.New("Exactly one review to revert must be specified.")
+	}
+	r, err := review.Get(repo, args[0])
+	if err != nil {
+		return fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return errors.New("There is no matching review.")
+	}
+	commits, err := r.GetRevertCommits()
+	if err != nil {
+		return err
+	}
+
+	hasUncommitted, err := repo.HasUncommittedChanges()
+	if err != nil {
+		return err
+	}
+	if hasUncommitted {
+		return errors.New("You have uncommitted or untracked files; commit or stash them before reverting a review.")
+	}
+
+	target := *revertTarget
+	if target == "" {
+		target = r.Request.TargetRef
+	}
+	base, err := repo.GetCommitHash(target)
+	if err != nil {
+		return err
+	}
+	branch := *revertBranch
+	if branch == "" {
+		branch = fmt.Sprintf("revert-%.12s", r.Revision)
+	}
+	if !strings.HasPrefix(branch, "refs/heads/") {
+		branch = "refs/heads/" + branch
+	}
+
+	if err := repo.CreateBranch(branch, base); err != nil {
+		return err
+	}
+	if err := repo.RevertCommits(commits...); err != nil {
+		return fmt.Errorf("Failed to revert the review: %v\n"+
+			"Resolve any conflicts and run \"git revert --continue\", then run\n"+
+			"\"git appraise request -target %s -reverts %s\" to request a review of the revert.",
+			err, target, r.Revision)
+	}
+
+	userEmail, err := repo.GetUserEmail()
+	if err != nil {
+		return err
+	}
+	reviewers := r.Request.Reviewers
+	if *revertReviewers != "" {
+		reviewers = nil
+		for _, reviewer := range strings.Split(*revertReviewers, ",") {
+			reviewers = append(reviewers, strings.TrimSpace(reviewer))
+		}
+		if reviewers, err = expandReviewers(repo, reviewers); err != nil {
+			return err
+		}
+	}
+	description := *revertMessage
+	if description == "" {
+		description = r.RevertDescription()
+	}
+	revertCommits, err := repo.ListCommitsBetween(base, branch)
+	if err != nil {
+		return err
+	}
+	if len(revertCommits) == 0 {
+		return errors.New("Reverting the review did not create any commits.")
+	}
+	reviewCommit := revertCommits[0]
+
+	req := request.New(userEmail, reviewers, branch, target, description)
+	now := time.Now()
+	req.Timestamp = FormatDate(&now)
+	req.BaseCommit = base
+	req.Reverts = r.Revision
+	if *revertSign {
+		signing, err := gpg.LoadSigningConfig(repo)
+		if err != nil {
+			return err
+		}
+		if err := signing.Sign(&req); err != nil {
+			return err
+		}
+	}
+	note, err := req.Write()
+	if err != nil {
+		return err
+	}
+	if err := repo.AppendNote(request.Ref, reviewCommit, note); err != nil {
+		return err
+	}
+	fmt.Printf(requestSummaryTemplate, reviewCommit, req.TargetRef, req.ReviewRef, req.Description)
+	return nil
+}
+
+// revertCmd defines the "revert" subcommand.
+var revertCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s revert [<option>...] <review-hash>\n\nOptions:\n", arg0)
+		revertFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return revertReview(repo, args)
+	},
+}
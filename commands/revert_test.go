@@ -0,0 +1,55 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+)
+
+func TestRevertReview(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	if err := revertReview(repo, []string{repository.TestCommitB}); err != nil {
+		t.Fatal(err)
+	}
+	branch := "refs/heads/revert-" + repository.TestCommitB
+	revertHead, err := repo.GetCommitHash(branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := repo.GetCommitMessage(revertHead)
+	if err != nil || message != `Revert "Second commit"` {
+		t.Errorf("Unexpected revert commit message: %q, %v", message, err)
+	}
+	r, err := review.Get(repo, revertHead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Fatal("Failed to request a review of the revert")
+	}
+	if r.Request.Reverts != repository.TestCommitB || r.Request.TargetRef != repository.TestTargetRef ||
+		r.Request.ReviewRef != branch || r.Request.BaseCommit != repository.TestCommitJ {
+		t.Errorf("Unexpected request for the revert: %+v", r.Request)
+	}
+
+	if err := revertReview(repo, []string{repository.TestCommitG}); err == nil {
+		t.Error("Unexpected success reverting a review that has not been submitted")
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"github.com/KoviRobi/git-appraise/commands/output"
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/readstate"
 )
 
 var showFlagSet = flag.NewFlagSet("show", flag.ExitOnError)
@@ -35,6 +37,9 @@ var (
 	showDiffOutput   = showFlagSet.Bool("diff", false, "Show the current diff for the review")
 	showDiffOptions  = showFlagSet.String("diff-opts", "", "Options to pass to the diff tool; can only be used with the --diff option")
 	showInlineOutput = showFlagSet.Bool("inline", false, "Show comments inline with the diff")
+	showTimeline     = showFlagSet.Bool("timeline", false, "Show the events of the review in chronological order")
+	showRetracted    = showFlagSet.Bool("retracted", false, "Include comments that have been retracted")
+	showRecursive    = showFlagSet.Bool("r", false, "Show the detached comments for everything within the given directory; can only be used with the -d option")
 )
 
 // showDetachedComments prints the current code review.
@@ -44,7 +49,15 @@ func showDetachedComments(repo repository.Repo, args []string) error {
 	}
 	if len(args) > 1 {
 		return errors.New("Only showing comments for a single path is supported.")
-	} else if len(args) == 0 {
+	}
+	if *showRecursive {
+		dir := ""
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		return showDetachedCommentsWithin(repo, dir)
+	}
+	if len(args) == 0 {
 		return errors.New("You must specify a path whose comments are to be shown.")
 	}
 	path := args[0]
@@ -52,12 +65,37 @@ func showDetachedComments(repo repository.Repo, args []string) error {
 	if err != nil {
 		return fmt.Errorf("Failed to load the comments for %q: %v\n", path, err)
 	}
+	if !*showRetracted {
+		comments = review.HideRetracted(comments)
+	}
 	if *showJSONOutput {
 		return output.PrintCommentsJSON(comments)
 	}
 	return output.PrintComments("-d", repo, comments)
 }
 
+// showDetachedCommentsWithin prints the detached comments on the given
+// directory, and on everything within it.
+func showDetachedCommentsWithin(repo repository.Repo, dir string) error {
+	commentsByPath, err := review.ListDetachedComments(repo, dir)
+	if err != nil {
+		return fmt.Errorf("Failed to load the comments within %q: %v\n", dir, err)
+	}
+	if !*showRetracted {
+		for path, comments := range commentsByPath {
+			if comments = review.HideRetracted(comments); len(comments) > 0 {
+				commentsByPath[path] = comments
+			} else {
+				delete(commentsByPath, path)
+			}
+		}
+	}
+	if *showJSONOutput {
+		return output.PrintDetachedCommentsJSON(commentsByPath)
+	}
+	return output.PrintDetachedComments(repo, commentsByPath)
+}
+
 // showReview prints the current code review.
 func showReview(repo repository.Repo, args []string) error {
 	if *showDiffOptions != "" && !*showDiffOutput {
@@ -82,6 +120,22 @@ func showReview(repo repository.Repo, args []string) error {
 	if r == nil {
 		return errors.New("There is no matching review.")
 	}
+	if err := r.CheckSignatures(); err != nil {
+		return fmt.Errorf("Failed to check the signatures of the review: %v\n", err)
+	}
+	if !*showRetracted {
+		r.Comments = review.HideRetracted(r.Comments)
+	}
+	if *showTimeline {
+		if *showDiffOutput || *showInlineOutput {
+			return errors.New("The --timeline flag can not be combined with the --diff or --inline flags.")
+		}
+		if *showJSONOutput {
+			return output.PrintTimelineJSON(r)
+		}
+		output.PrintTimeline(r)
+		return nil
+	}
 	if *showJSONOutput {
 		return output.PrintJSON(r)
 	}
@@ -99,7 +153,17 @@ func showReview(repo repository.Repo, args []string) error {
 		}
 		return output.PrintInlineComments(r, diffArgs...)
 	}
-	return output.PrintDetails(r)
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	readState, err := readstate.Load(repo)
+	if err != nil {
+		return err
+	}
+	people, _ := identity.Load(repo)
+	mentions := r.GetMentions(r.KnownIdentities(people))
+	return output.PrintDetailsWithReadState(r, mentions, readState.UnreadThreads(userEmail, r.Summary))
 }
 
 // showCmd defines the "show" subcommand.
@@ -114,6 +178,9 @@ var showCmd = &Command{
 		if *showDetached {
 			return showDetachedComments(repo, args)
 		}
+		if *showRecursive {
+			return errors.New("The -r flag can only be used with the -d flag.")
+		}
 		return showReview(repo, args)
 	},
 }
@@ -0,0 +1,87 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/KoviRobi/git-appraise/commands/output"
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+)
+
+var stackFlagSet = flag.NewFlagSet("stack", flag.ExitOnError)
+
+var (
+	stackRebase  = stackFlagSet.Bool("rebase", false, "Rebase every review in the stack on top of the review it depends upon")
+	stackArchive = stackFlagSet.Bool("archive", true, "Prevent the original commits from being garbage collected when rebasing")
+)
+
+// stackReview shows, or restacks, the stack of reviews that a review is part of.
+func stackReview(repo repository.Repo, args []string) error {
+	stackFlagSet.Parse(args)
+	args = stackFlagSet.Args()
+
+	var r *review.Review
+	var err error
+	if len(args) > 1 {
+		return errors.New("Only showing the stack of a single review is supported.")
+	}
+
+	if len(args) == 1 {
+		r, err = review.Get(repo, args[0])
+	} else {
+		r, err = review.GetCurrent(repo)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to load the review: %v\n", err)
+	}
+	if r == nil {
+		return errors.New("There is no matching review.")
+	}
+
+	s, err := review.GetStack(repo, r.Revision)
+	if err != nil {
+		return err
+	}
+	if *stackRebase {
+		if err := s.Restack(*stackArchive); err != nil {
+			return err
+		}
+		// Reload the stack so that the updated reviews are shown.
+		s, err = review.GetStack(repo, r.Revision)
+		if err != nil {
+			return err
+		}
+	}
+	output.PrintStack(s)
+	return nil
+}
+
+// stackCmd defines the "stack" subcommand.
+var stackCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s stack [<option>...] [<review-hash>]\n\nOptions:\n", arg0)
+		stackFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return stackReview(repo, args)
+	},
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/query"
+	"github.com/KoviRobi/git-appraise/review/stats"
+)
+
+var statsFlagSet = flag.NewFlagSet("stats", flag.ExitOnError)
+
+var (
+	statsBy     = statsFlagSet.String("by", stats.ByTarget, "Group the metrics by either \"reviewer\" or \"target\"")
+	statsAfter  = statsFlagSet.String("after", "", "Only include reviews requested on or after the given date (yyyy-mm-dd)")
+	statsBefore = statsFlagSet.String("before", "", "Only include reviews requested before the given date (yyyy-mm-dd)")
+	statsJSON   = statsFlagSet.Bool("json", false, "Format the output as JSON")
+	statsCSV    = statsFlagSet.Bool("csv", false, "Format the output as CSV")
+)
+
+var statsColumns = []string{
+	"key", "reviews", "submitted", "abandoned", "abandon rate",
+	"mean time to first comment", "median time to first comment",
+	"mean time to accept", "median time to accept",
+	"mean rounds", "mean commits", "mean lines changed",
+}
+
+// formatStatsDuration formats a number of seconds to the nearest minute.
+func formatStatsDuration(seconds int64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	d := time.Duration(seconds) * time.Second
+	if d < time.Minute {
+		return d.String()
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	if days > 0 {
+		return fmt.Sprintf("%dd%dh%dm", days, hours, minutes)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+func printStatsCSV(metrics []stats.Metrics) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(statsColumns); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		record := []string{
+			m.Key,
+			strconv.Itoa(m.Reviews),
+			strconv.Itoa(m.Submitted),
+			strconv.Itoa(m.Abandoned),
+			formatFloat(m.AbandonRate),
+			strconv.FormatInt(m.MeanTimeToFirstComment, 10),
+			strconv.FormatInt(m.MedianTimeToFirstComment, 10),
+			strconv.FormatInt(m.MeanTimeToAccept, 10),
+			strconv.FormatInt(m.MedianTimeToAccept, 10),
+			formatFloat(m.MeanRounds),
+			formatFloat(m.MeanCommits),
+			formatFloat(m.MeanLinesChanged),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printStatsTable(metrics []stats.Metrics) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{
+		*statsBy, "reviews", "submitted", "abandoned",
+		"first comment (mean/median)", "accept (mean/median)",
+		"rounds", "commits", "lines",
+	}, "\t"))
+	for _, m := range metrics {
+		key := m.Key
+		if key == "" {
+			key = "(none)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d (%.0f%%)\t%s / %s\t%s / %s\t%.1f\t%.1f\t%.0f\n",
+			key, m.Reviews, m.Submitted, m.Abandoned, 100*m.AbandonRate,
+			formatStatsDuration(m.MeanTimeToFirstComment), formatStatsDuration(m.MedianTimeToFirstComment),
+			formatStatsDuration(m.MeanTimeToAccept), formatStatsDuration(m.MedianTimeToAccept),
+			m.MeanRounds, m.MeanCommits, m.MeanLinesChanged)
+	}
+	return w.Flush()
+}
+
+// reviewStats computes and prints metrics about the reviews in the repository.
+func reviewStats(repo repository.Repo, args []string) error {
+	statsFlagSet.Parse(args)
+	args = statsFlagSet.Args()
+
+	if *statsBy != stats.ByReviewer && *statsBy != stats.ByTarget {
+		return fmt.Errorf("Unknown grouping %q", *statsBy)
+	}
+	if *statsJSON && *statsCSV {
+		return errors.New("Only one of -json or -csv is allowed.")
+	}
+
+	if *statsAfter != "" {
+		args = append(args, query.KeyAfter+":"+*statsAfter)
+	}
+	if *statsBefore != "" {
+		args = append(args, query.KeyBefore+":"+*statsBefore)
+	}
+	userEmail, err := identity.CurrentUser(repo)
+	if err != nil {
+		return err
+	}
+	people, err := identity.Load(repo)
+	if err != nil {
+		return err
+	}
+	q, err := query.ParseTerms(args, userEmail, people)
+	if err != nil {
+		return err
+	}
+
+	var reviewStats []*stats.ReviewStats
+	for _, summary := range q.Filter(review.ListAll(repo)) {
+		s := stats.New(&summary)
+		r, err := summary.Details()
+		if err != nil {
+			return err
+		}
+		// The size of a review is best effort, as its commits may no longer be available.
+		s.LoadSize(r)
+		reviewStats = append(reviewStats, s)
+	}
+	metrics := stats.Aggregate(reviewStats, *statsBy)
+
+	if *statsJSON {
+		b, err := json.MarshalIndent(metrics, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if *statsCSV {
+		return printStatsCSV(metrics)
+	}
+	return printStatsTable(metrics)
+}
+
+// statsCmd defines the "stats" subcommand.
+var statsCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s stats [<option>...] [<query>...]\n\nOptions:\n", arg0)
+		statsFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return reviewStats(repo, args)
+	},
+}
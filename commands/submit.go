@@ -20,8 +20,14 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/request"
 )
 
 var submitFlagSet = flag.NewFlagSet("submit", flag.ExitOnError)
@@ -30,13 +36,43 @@ var (
 	submitMerge       = submitFlagSet.Bool("merge", false, "Create a merge of the source and target refs.")
 	submitRebase      = submitFlagSet.Bool("rebase", false, "Rebase the source ref onto the target ref.")
 	submitFastForward = submitFlagSet.Bool("fast-forward", false, "Create a merge using the default fast-forward mode.")
+	submitSquash      = submitFlagSet.Bool("squash", false, "Squash the source ref into a single commit, with the review description as its message.")
+	submitTrailers    = submitFlagSet.Bool("trailers", false, "Add Reviewed-by, Acked-by, and Review-Id trailers to the submitted commit messages.")
 	submitTBR         = submitFlagSet.Bool("tbr", false, "(To be reviewed) Force the submission of a review that has not been accepted.")
-	submitArchive     = submitFlagSet.Bool("archive", true, "Prevent the original commit from being garbage collected; only affects rebased submits.")
+	submitOverride    = submitFlagSet.Bool("override", false, "Submit even if the configured CI, analysis, or checklist preconditions are not met, recording the override in the review.")
+	submitArchive     = submitFlagSet.Bool("archive", true, "Prevent the original commit from being garbage collected; only affects rebased, squashed, or trailer-adding submits.")
+
+	submitPush = submitFlagSet.String("push", "", "Push the submitted target ref, along with the review notes and archives, to the given remote in a single atomic push.")
 
 	submitSign = submitFlagSet.Bool("S", false,
 		"Sign the contents of the submission")
 )
 
+// recordOverride adds a comment to the review recording that the current
+// user submitted it despite the given preconditions not being met.
+func recordOverride(repo repository.Repo, r *review.Review, unmet []string) error {
+	userEmail, err := repo.GetUserEmail()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	c := comment.New(userEmail, "Submitted despite the following unmet preconditions:\n  - "+strings.Join(unmet, "\n  - "))
+	c.Timestamp = FormatDate(&now)
+	if head, err := r.GetHeadCommit(); err == nil {
+		c.Location = &comment.Location{Commit: head}
+	}
+	if *submitSign {
+		signing, err := gpg.LoadSigningConfig(repo)
+		if err != nil {
+			return err
+		}
+		if err := signing.Sign(&c); err != nil {
+			return err
+		}
+	}
+	return r.AddComment(c)
+}
+
 // Submit the current code review request.
 //
 // The "args" parameter contains all of the command line arguments that followed the subcommand.
@@ -44,8 +80,14 @@ func submitReview(repo repository.Repo, args []string) error {
 	submitFlagSet.Parse(args)
 	args = submitFlagSet.Args()
 
-	if *submitMerge && *submitRebase {
-		return errors.New("Only one of --merge or --rebase is allowed.")
+	strategies := 0
+	for _, strategy := range []bool{*submitMerge, *submitRebase, *submitFastForward, *submitSquash} {
+		if strategy {
+			strategies++
+		}
+	}
+	if strategies > 1 {
+		return errors.New("Only one of --merge, --rebase, --fast-forward, or --squash is allowed.")
 	}
 
 	var r *review.Review
@@ -74,10 +116,39 @@ func submitReview(repo repository.Repo, args []string) error {
 		return errors.New("Not submitting as the review has not yet been accepted.")
 	}
 
+	unsubmitted, err := r.GetUnsubmittedDependencies()
+	if err != nil {
+		return err
+	}
+	if len(unsubmitted) > 0 {
+		return fmt.Errorf("Not submitting as the review depends on the unsubmitted review %.12s.", unsubmitted[0].Revision)
+	}
+
+	// Record the local state that the submission changes, so that it can
+	// be rolled back if pushing the submission fails.
+	snapshot, err := takeSubmissionSnapshot(repo, r)
+	if err != nil {
+		return err
+	}
+
+	preconditions, err := review.GetPreconditions(repo)
+	if err != nil {
+		return err
+	}
+	unmet := r.UnmetPreconditions(preconditions)
+	if len(unmet) > 0 && !*submitOverride {
+		return fmt.Errorf("Not submitting as the review does not meet the following preconditions:\n  - %s\nUse --override to submit it anyway.",
+			strings.Join(unmet, "\n  - "))
+	}
+
 	target := r.Request.TargetRef
 	if err := repo.VerifyGitRef(target); err != nil {
 		return err
 	}
+	previousTarget, err := repo.GetCommitHash(target)
+	if err != nil {
+		return err
+	}
 	source, err := r.GetHeadCommit()
 	if err != nil {
 		return err
@@ -91,19 +162,20 @@ func submitReview(repo repository.Repo, args []string) error {
 		return errors.New("Refusing to submit a non-fast-forward review. First merge the target ref.")
 	}
 
-	if !(*submitRebase || *submitMerge || *submitFastForward) {
+	if strategies == 0 {
 		submitStrategy, err := repo.GetSubmitStrategy()
 		if err != nil {
 			return err
 		}
-		if submitStrategy == "merge" && !*submitRebase && !*submitFastForward {
+		switch submitStrategy {
+		case "merge":
 			*submitMerge = true
-		}
-		if submitStrategy == "rebase" && !*submitMerge && !*submitFastForward {
+		case "rebase":
 			*submitRebase = true
-		}
-		if submitStrategy == "fast-forward" && !*submitRebase && !*submitMerge {
+		case "fast-forward":
 			*submitFastForward = true
+		case "squash":
+			*submitSquash = true
 		}
 	}
 
@@ -114,6 +186,9 @@ func submitReview(repo repository.Repo, args []string) error {
 		} else {
 			err = r.Rebase(*submitArchive)
 		}
+		if err == review.ErrRebaseStopped {
+			return fmt.Errorf("%v\nThe review has not been submitted. Once the rebase is finished, run \"git appraise submit\" again to submit it.", err)
+		}
 		if err != nil {
 			return err
 		}
@@ -124,17 +199,64 @@ func submitReview(repo repository.Repo, args []string) error {
 		}
 	}
 
+	var trailers []string
+	if *submitTrailers {
+		trailers = r.Trailers()
+	}
+	if *submitSquash || (len(trailers) > 0 && !*submitMerge) {
+		var rewritten string
+		if *submitSquash {
+			message := review.AppendTrailers(r.Request.Description, trailers)
+			rewritten, err = review.Squash(repo, target, source, message, *submitSign)
+		} else {
+			rewritten, err = review.AddTrailers(repo, target, source, trailers, *submitSign)
+		}
+		if err != nil {
+			return err
+		}
+		// Record the rewritten commits, so that the review is still
+		// recognized as submitted once they are merged.
+		if err := r.Rewrite(source, previousTarget, rewritten, review.RebaseOptions{
+			Archive: *submitArchive,
+			Sign:    *submitSign,
+		}); err != nil {
+			return err
+		}
+		source = rewritten
+	}
+
 	if err := repo.SwitchToRef(target); err != nil {
 		return err
 	}
+	if err := mergeSubmission(repo, r, source, trailers); err != nil {
+		return err
+	}
+	// The override is only recorded once the review has been submitted, so
+	// that a failed submission does not leave a misleading comment behind.
+	if len(unmet) > 0 {
+		if err := recordOverride(repo, r, unmet); err != nil {
+			return err
+		}
+	}
+	if *submitPush != "" {
+		return pushSubmission(repo, *submitPush, target, previousTarget, snapshot)
+	}
+	return nil
+}
+
+// mergeSubmission merges the given source commit into the current ref,
+// which is the target of the review.
+func mergeSubmission(repo repository.Repo, r *review.Review, source string, trailers []string) error {
 	if *submitMerge {
 		submitMessage := fmt.Sprintf("Submitting review %.12s", r.Revision)
+		messages := []string{submitMessage, r.Request.Description}
+		if len(trailers) > 0 {
+			messages = append(messages, strings.Join(trailers, "\n"))
+		}
 		if *submitSign {
-			return repo.MergeAndSignRef(source, false, submitMessage,
-				r.Request.Description)
+			return repo.MergeAndSignRef(source, false, messages...)
 		} else {
-			return repo.MergeRef(source, false, submitMessage,
-				r.Request.Description)
+			return repo.MergeRef(source, false, messages...)
 		}
 	} else {
 		if *submitSign {
@@ -145,6 +267,82 @@ func submitReview(repo repository.Repo, args []string) error {
 	}
 }
 
+// submissionSnapshot holds the parts of a review that submitting it can
+// change locally: its request and comment notes, and its review ref.
+type submissionSnapshot struct {
+	revision     string
+	requestNotes []repository.Note
+	commentNotes []repository.Note
+	reviewRef    string
+	reviewHead   string
+}
+
+func takeSubmissionSnapshot(repo repository.Repo, r *review.Review) (*submissionSnapshot, error) {
+	snapshot := &submissionSnapshot{
+		revision:     r.Revision,
+		requestNotes: repo.GetNotes(request.Ref, r.Revision),
+		commentNotes: repo.GetNotes(comment.Ref, r.Revision),
+	}
+	// Only branches are rebased in place, so other review refs are left alone.
+	if strings.HasPrefix(r.Request.ReviewRef, "refs/heads/") {
+		if hasRef, err := repo.HasRef(r.Request.ReviewRef); err != nil {
+			return nil, err
+		} else if hasRef {
+			reviewHead, err := repo.GetCommitHash(r.Request.ReviewRef)
+			if err != nil {
+				return nil, err
+			}
+			snapshot.reviewRef = r.Request.ReviewRef
+			snapshot.reviewHead = reviewHead
+		}
+	}
+	return snapshot, nil
+}
+
+// restore puts the notes and review ref of the review back as they were
+// when the snapshot was taken.
+func (s *submissionSnapshot) restore(repo repository.Repo) error {
+	if err := repo.SetNotes(request.Ref, s.revision, s.requestNotes); err != nil {
+		return err
+	}
+	if err := repo.SetNotes(comment.Ref, s.revision, s.commentNotes); err != nil {
+		return err
+	}
+	if s.reviewRef == "" {
+		return nil
+	}
+	current, err := repo.GetCommitHash(s.reviewRef)
+	if err != nil || current == s.reviewHead {
+		return err
+	}
+	return repo.SetRef(s.reviewRef, s.reviewHead, current)
+}
+
+// pushSubmission pushes the submitted target ref, along with the review
+// notes and archives, to the given remote in a single atomic push.
+//
+// The push only succeeds if the remote target ref still points to the
+// commit that the submission was made on top of. Otherwise, the submission
+// is rolled back by resetting the local target ref to that commit, and by
+// restoring the review's notes and review ref from the given snapshot.
+func pushSubmission(repo repository.Repo, remote, target, previousTarget string, snapshot *submissionSnapshot) error {
+	leases := map[string]string{target: previousTarget}
+	err := repo.PushAtomic(remote, leases,
+		target+":"+target,
+		notesRefPattern+":"+notesRefPattern,
+		archiveRefPattern+":"+archiveRefPattern)
+	if err == nil {
+		return nil
+	}
+	if resetErr := repo.ResetCurrentRef(previousTarget); resetErr != nil {
+		return fmt.Errorf("%v\nFailed to roll back %q to %.12s: %v", err, target, previousTarget, resetErr)
+	}
+	if restoreErr := snapshot.restore(repo); restoreErr != nil {
+		return fmt.Errorf("%v\nFailed to roll back the review %.12s: %v", err, snapshot.revision, restoreErr)
+	}
+	return fmt.Errorf("%v\nThe submission has been rolled back; pull the latest changes from %q and try again.", err, remote)
+}
+
 // submitCmd defines the "submit" subcommand.
 var submitCmd = &Command{
 	Usage: func(arg0 string) {
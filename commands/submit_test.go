@@ -0,0 +1,145 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+func TestSubmitRolledBackWhenPushRejected(t *testing.T) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	// Move the target back to a commit that the pending review builds upon,
+	// so that the review can be submitted.
+	if err := repo.SetRef(repository.TestTargetRef, repository.TestCommitF, repository.TestCommitJ); err != nil {
+		t.Fatal(err)
+	}
+	requestNotes := repo.GetNotes(request.Ref, repository.TestCommitG)
+	commentNotes := repo.GetNotes(comment.Ref, repository.TestCommitG)
+	repo.RejectPushes(errors.New("stale info"))
+
+	err := submitReview(repo, []string{"-tbr", "-rebase", "-push", "origin", repository.TestCommitG})
+	if err == nil {
+		t.Fatal("Unexpected success submitting with a rejected push")
+	}
+	if target, err := repo.GetCommitHash(repository.TestTargetRef); err != nil || target != repository.TestCommitF {
+		t.Errorf("Unexpected target after a rejected push: %q, %v", target, err)
+	}
+	if head, err := repo.GetCommitHash(repository.TestReviewRef); err != nil || head != repository.TestCommitI {
+		t.Errorf("Unexpected review ref after a rejected push: %q, %v", head, err)
+	}
+	if notes := repo.GetNotes(request.Ref, repository.TestCommitG); !reflect.DeepEqual(notes, requestNotes) {
+		t.Errorf("Unexpected request notes after a rejected push: %q", notes)
+	}
+	if notes := repo.GetNotes(comment.Ref, repository.TestCommitG); !reflect.DeepEqual(notes, commentNotes) {
+		t.Errorf("Unexpected comment notes after a rejected push: %q", notes)
+	}
+}
+
+func TestSubmitUnfinishedWhenRebaseStops(t *testing.T) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	repo.SetDataDir(t.TempDir())
+	if err := repo.SetRef(repository.TestTargetRef, repository.TestCommitF, repository.TestCommitJ); err != nil {
+		t.Fatal(err)
+	}
+	repo.StopRebases(true)
+
+	// The flags persist between tests, so the -push flag is cleared explicitly.
+	err := submitReview(repo, []string{"-tbr", "-rebase", "-push", "", repository.TestCommitG})
+	if err == nil || !strings.Contains(err.Error(), "has not been submitted") {
+		t.Fatalf("Unexpected result of submitting when the rebase stops: %v", err)
+	}
+	if target, err := repo.GetCommitHash(repository.TestTargetRef); err != nil || target != repository.TestCommitF {
+		t.Errorf("Unexpected target after a rebase stopped: %q, %v", target, err)
+	}
+	if inProgress, err := review.IsRebaseInProgress(repo); err != nil || !inProgress {
+		t.Errorf("Failed to record the stopped rebase: %v, %v", inProgress, err)
+	}
+}
+
+func TestSubmitRecordsOverrideOnlyOnceSubmitted(t *testing.T) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	repo.SetDataDir(t.TempDir())
+	repo.SetConfigValues("appraise.requireCI", "true")
+	if err := repo.SetRef(repository.TestTargetRef, repository.TestCommitF, repository.TestCommitJ); err != nil {
+		t.Fatal(err)
+	}
+	commentNotes := repo.GetNotes(comment.Ref, repository.TestCommitG)
+
+	repo.StopRebases(true)
+	if err := submitReview(repo, []string{"-tbr", "-override", "-rebase", "-push", "", repository.TestCommitG}); err == nil {
+		t.Fatal("Unexpected success submitting when the rebase stops")
+	}
+	if notes := repo.GetNotes(comment.Ref, repository.TestCommitG); !reflect.DeepEqual(notes, commentNotes) {
+		t.Errorf("Unexpected comments after a failed submission: %q", notes)
+	}
+	if err := review.AbortRebase(repo); err != nil {
+		t.Fatal(err)
+	}
+	repo.StopRebases(false)
+
+	if err := submitReview(repo, []string{"-tbr", "-override", "-rebase=false", "-fast-forward", repository.TestCommitG}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := review.Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Submitted || len(r.Comments) != 1 || !strings.Contains(r.Comments[0].Comment.Description, "there is no CI report") {
+		t.Errorf("Unexpected override comment after submitting: %+v", r.Comments)
+	}
+}
+
+func TestSubmitRefusedWithUnsubmittedDependency(t *testing.T) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	stackedCommit, err := repo.CreateCommit(&repository.CommitDetails{
+		Summary: "Stacked commit",
+		Time:    "0000000010",
+		Parents: []string{repository.TestCommitI},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stackedRef := "refs/heads/ojarjur/stacked"
+	if err := repo.SetRef(stackedRef, stackedCommit, ""); err != nil {
+		t.Fatal(err)
+	}
+	stackedRequest := request.New("ojarjur", []string{"ojarjur"}, stackedRef, repository.TestTargetRef, "Stacked")
+	stackedRequest.DependsOn = []string{repository.TestCommitG}
+	note, err := stackedRequest.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AppendNote(request.Ref, stackedCommit, note); err != nil {
+		t.Fatal(err)
+	}
+
+	err = submitReview(repo, []string{"-tbr", "-rebase=false", "-push", "", stackedCommit})
+	if err == nil || !strings.Contains(err.Error(), "depends on the unsubmitted review "+repository.TestCommitG) {
+		t.Fatalf("Unexpected result of submitting a review with an unsubmitted dependency: %v", err)
+	}
+	if target, err := repo.GetCommitHash(repository.TestTargetRef); err != nil || target != repository.TestCommitJ {
+		t.Fatalf("Unexpected target after a refused submission: %q, %v", target, err)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/KoviRobi/git-appraise/repository"
+)
+
+var unresolveFlagSet = flag.NewFlagSet("unresolve", flag.ExitOnError)
+
+var (
+	unresolveMessageFile = unresolveFlagSet.String("F", "", "Take the comment from the given file. Use - to read the message from the standard input")
+	unresolveMessage     = unresolveFlagSet.String("m", "Unresolved.", "Message to attach to the reopened comment")
+	unresolveSign        = unresolveFlagSet.Bool("S", false, "Sign the contents of the comment")
+)
+
+// unresolveCmd defines the "unresolve" subcommand.
+var unresolveCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s unresolve [<option>...] <comment-hash> [<review-hash>]\n\nOptions:\n", arg0)
+		unresolveFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		unresolveFlagSet.Parse(args)
+		return setThreadResolved(repo, unresolveFlagSet.Args(), false, *unresolveMessage, *unresolveMessageFile, *unresolveSign)
+	},
+}
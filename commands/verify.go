@@ -0,0 +1,146 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+)
+
+var verifyFlagSet = flag.NewFlagSet("verify", flag.ExitOnError)
+
+var (
+	verifyAll    = verifyFlagSet.Bool("all", false, "Verify the signatures of every review")
+	verifyMerges = verifyFlagSet.Bool("merges", false, "Also require the merge commits of submitted reviews to be signed")
+	verifyJSON   = verifyFlagSet.Bool("json", false, "Format the output as JSON, including the signatures without problems")
+)
+
+// loadReviewsToVerify loads the reviews named by the given arguments, all of
+// the reviews, or the current review if there are neither.
+func loadReviewsToVerify(repo repository.Repo, args []string) ([]*review.Review, error) {
+	if *verifyAll {
+		if len(args) > 0 {
+			return nil, errors.New("The -all flag can not be combined with the names of reviews.")
+		}
+		var reviews []*review.Review
+		for _, summary := range review.ListAll(repo) {
+			summary := summary
+			r, err := summary.Details()
+			if err != nil {
+				return nil, err
+			}
+			reviews = append(reviews, r)
+		}
+		return reviews, nil
+	}
+	if len(args) == 0 {
+		r, err := review.GetCurrent(repo)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the review: %v\n", err)
+		}
+		if r == nil {
+			return nil, errors.New("There is no matching review.")
+		}
+		return []*review.Review{r}, nil
+	}
+	var reviews []*review.Review
+	for _, arg := range args {
+		r, err := review.Get(repo, arg)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the review: %v\n", err)
+		}
+		if r == nil {
+			return nil, fmt.Errorf("There is no review matching %q.", arg)
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, nil
+}
+
+// verifySignatures checks the signatures on everything written for the given
+// reviews, and reports those that are not acceptable.
+func verifySignatures(repo repository.Repo, args []string) error {
+	verifyFlagSet.Parse(args)
+	args = verifyFlagSet.Args()
+
+	reviews, err := loadReviewsToVerify(repo, args)
+	if err != nil {
+		return err
+	}
+	auditor, err := review.NewAuditor(repo)
+	if err != nil {
+		return err
+	}
+	auditor.CheckMerges = *verifyMerges
+
+	var checks, problems []review.SignatureCheck
+	for _, r := range reviews {
+		reviewChecks, err := auditor.Audit(r)
+		if err != nil {
+			return fmt.Errorf("Failed to verify the review %.12s: %v", r.Revision, err)
+		}
+		for _, check := range reviewChecks {
+			checks = append(checks, check)
+			if check.Problem != "" {
+				problems = append(problems, check)
+			}
+		}
+	}
+
+	if *verifyJSON {
+		b, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		if len(problems) > 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "review\tkind\tid\tauthor\tproblem")
+			for _, check := range problems {
+				fmt.Fprintf(w, "%.12s\t%s\t%.12s\t%s\t%s\n",
+					check.Review, check.Kind, check.ID, check.Author, check.Problem)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Checked %d signatures in %d reviews.\n", len(checks), len(reviews))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of the signatures are missing or not trusted.", len(problems))
+	}
+	return nil
+}
+
+// verifyCmd defines the "verify" subcommand.
+var verifyCmd = &Command{
+	Usage: func(arg0 string) {
+		fmt.Printf("Usage: %s verify [<option>...] [-all | <review-hash>...]\n\nOptions:\n", arg0)
+		verifyFlagSet.PrintDefaults()
+	},
+	RunMethod: func(repo repository.Repo, args []string) error {
+		return verifySignatures(repo, args)
+	},
+}
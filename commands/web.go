@@ -76,6 +76,16 @@ func webGenerateStatic(repoDetails *web.RepoDetails) error {
 		}
 	}
 
+	for _, discussion := range repoDetails.Discussions {
+		discussionFile, err := os.Create(paths.Discussion(discussion.Revision))
+		if err != nil {
+			return err
+		}
+		if err := repoDetails.WriteDiscussionTemplate(discussion.Revision, paths, discussionFile); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -91,11 +101,17 @@ func webServe(repoDetails *web.RepoDetails) error {
 	repo, _, _       := strings.Cut(paths.Repo(), "?")
 	branch, _, _     := strings.Cut(paths.Branch(0), "?")
 	review, _, _     := strings.Cut(paths.Review(""), "?")
+	search, _, _     := strings.Cut(paths.Search(""), "?")
+	dashboard, _, _  := strings.Cut(paths.Dashboard(""), "?")
+	discussion, _, _ := strings.Cut(paths.Discussion(""), "?")
 
 	http.HandleFunc("/" + stylesheet, web.ServeStyleSheet)
 	http.HandleFunc("/" + repo, repoDetails.ServeRepoTemplate)
 	http.HandleFunc("/" + branch, repoDetails.ServeBranchTemplate)
 	http.HandleFunc("/" + review, repoDetails.ServeReviewTemplate)
+	http.HandleFunc("/" + search, repoDetails.ServeSearchTemplate)
+	http.HandleFunc("/" + dashboard, repoDetails.ServeDashboardTemplate)
+	http.HandleFunc("/" + discussion, repoDetails.ServeDiscussionTemplate)
 	http.HandleFunc("/", repoDetails.ServeEntryPointRedirect)
 
 	return http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
@@ -8,12 +8,20 @@ import (
 	"html/template"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/KoviRobi/git-appraise/commands/output"
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/checklist"
+	"github.com/KoviRobi/git-appraise/review/ci"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/inbox"
+	"github.com/KoviRobi/git-appraise/review/query"
+	"github.com/KoviRobi/git-appraise/review/readstate"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/html"
@@ -23,8 +31,9 @@ import (
 )
 
 const (
-	// SHA1 produces 160 bit hashes, so a hex-encoded hash should be no more than 40 characters.
-	maxHashLength = 40
+	// Review names are usually hashes or ref names, neither of which should
+	// be anywhere near this long.
+	maxReviewNameLength = 256
 )
 
 var (
@@ -39,15 +48,27 @@ var (
 
 	//go:embed review.html
 	review_html string
+
+	//go:embed search.html
+	search_html string
+
+	//go:embed dashboard.html
+	dashboard_html string
+
+	//go:embed discussion.html
+	discussion_html string
 )
 
-func checkStringLooksLikeHash(s string) error {
-	if len(s) > maxHashLength {
-		return errors.New("Invalid hash parameter")
+// checkStringLooksLikeReviewName checks that the given string is one of the
+// names of a review accepted by review.Resolve, e.g. an abbreviated hash, a
+// numeric alias, or a ref name.
+func checkStringLooksLikeReviewName(s string) error {
+	if len(s) > maxReviewNameLength || strings.HasPrefix(s, "-") {
+		return errors.New("Invalid review parameter")
 	}
 	for _, c := range s {
-		if ((c < 'a') || (c > 'f')) && ((c < '0') || (c > '9')) {
-			return errors.New("Invalid hash character")
+		if ((c < 'a') || (c > 'z')) && ((c < 'A') || (c > 'Z')) && ((c < '0') || (c > '9')) && !strings.ContainsRune("#._/@{}~^-", c) {
+			return errors.New("Invalid review character")
 		}
 	}
 	return nil
@@ -58,6 +79,14 @@ type Paths interface {
 	Repo() string
 	Branch(branch uint64) string
 	Review(review string) string
+	// Search returns the path of the search results for the given query, or
+	// the empty string if searching is not supported (e.g. for static output).
+	Search(query string) string
+	// Dashboard returns the path of the dashboard for the given user, or the
+	// empty string if dashboards are not supported (e.g. for static output).
+	Dashboard(user string) string
+	// Discussion returns the path of the given repository-wide discussion.
+	Discussion(discussion string) string
 }
 
 type ServePaths struct {}
@@ -70,6 +99,15 @@ func (ServePaths) Branch(branch uint64) string {
 func (ServePaths) Review(review string) string {
 	return fmt.Sprintf("review.html?review=%s", review)
 }
+func (ServePaths) Search(query string) string {
+	return "search.html?q=" + url.QueryEscape(query)
+}
+func (ServePaths) Dashboard(user string) string {
+	return "dashboard.html?user=" + url.QueryEscape(user)
+}
+func (ServePaths) Discussion(discussion string) string {
+	return fmt.Sprintf("discussion.html?discussion=%s", discussion)
+}
 
 type StaticPaths struct {}
 
@@ -81,6 +119,11 @@ func (StaticPaths) Branch(branch uint64) string {
 func (StaticPaths) Review(review string) string {
 	return fmt.Sprintf("review_%s.html", review)
 }
+func (StaticPaths) Search(query string) string { return "" }
+func (StaticPaths) Dashboard(user string) string { return "" }
+func (StaticPaths) Discussion(discussion string) string {
+	return fmt.Sprintf("discussion_%s.html", discussion)
+}
 
 func mdToHTML(md []byte) []byte {
 	// create markdown parser with extensions
@@ -99,7 +142,7 @@ func mdToHTML(md []byte) []byte {
 	return html
 }
 
-func ServeTemplate(v interface{}, p Paths, w io.Writer, name string, templ string) error {
+func ServeTemplate(v interface{}, p Paths, w io.Writer, name string, templ string, extraFuncs ...template.FuncMap) error {
 	tmpl := template.New(name)
 	tmpl = tmpl.Funcs(map[string]any{
 		"u64": func(i int) uint64 { return uint64(i) },
@@ -120,8 +163,22 @@ func ServeTemplate(v interface{}, p Paths, w io.Writer, name string, templ strin
 			return op == repository.OpContext || op == repository.OpAdd
 		},
 		"mdToHTML": func(s string) template.HTML { return template.HTML(mdToHTML([]byte(s))) },
+		"formatTimestamp": func(timestamp string) string {
+			t, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return timestamp
+			}
+			return time.Unix(t, 0).UTC().Format(time.RFC1123)
+		},
 		"paths": func() Paths { return p },
+		// Overridden for templates which know what the user has already seen.
+		"isUnread": func(hash string) bool { return false },
+		// Overridden for templates which know the people in the repository.
+		"displayName": func(email string) string { return email },
 	})
+	for _, funcs := range extraFuncs {
+		tmpl = tmpl.Funcs(funcs)
+	}
 	tmpl, err := tmpl.Parse(templ)
 	if err != nil {
 		return err
@@ -220,7 +277,154 @@ func (repoDetails *RepoDetails) WriteBranchTemplate(branch uint64, p Paths, w io
 		BranchNum: branch,
 		BranchDetails: repoDetails.Branches[branch],
 	}
-	return ServeTemplate(args, p, w, "branch", branch_html)
+	return ServeTemplate(args, p, w, "branch", branch_html, template.FuncMap{
+		"ciStatus": func(r review.Summary) string { return r.GetCIStatus() },
+	})
+}
+
+// Shows the reviews matching a query
+// The query is given by the 'q' URL parameter.
+func (repoDetails *RepoDetails) ServeSearchTemplate(w http.ResponseWriter, r *http.Request) {
+	repoDetails.ServeSearchTemplateWith(ServePaths{}, w, r)
+}
+
+func (repoDetails *RepoDetails) ServeSearchTemplateWith(p Paths, w http.ResponseWriter, r *http.Request) {
+	if err := repoDetails.Update(); err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	var writer bytes.Buffer
+	if err := repoDetails.WriteSearchTemplate(r.URL.Query().Get("q"), p, &writer); err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(writer.Bytes())
+}
+
+func (repoDetails *RepoDetails) WriteSearchTemplate(queryText string, p Paths, w io.Writer) error {
+	type templateArgs struct {
+		RepoDetails *RepoDetails
+		Query       string
+		Results     []review.Summary
+		Error       error
+	}
+	args := templateArgs{
+		RepoDetails: repoDetails,
+		Query:       queryText,
+	}
+	// The "me" user is only meaningful when serving a single local repository.
+	userEmail, _ := repoDetails.Repo.GetUserEmail()
+	q, err := query.Parse(queryText, repoDetails.People.Canonical(userEmail), repoDetails.People)
+	if err != nil {
+		args.Error = err
+	} else {
+		args.Results = q.Filter(repoDetails.AllReviews())
+	}
+	return ServeTemplate(args, p, w, "search", search_html)
+}
+
+// Shows the open reviews that need the attention of a user
+// The user is given by the 'user' URL parameter, and defaults to the git user.
+func (repoDetails *RepoDetails) ServeDashboardTemplate(w http.ResponseWriter, r *http.Request) {
+	repoDetails.ServeDashboardTemplateWith(ServePaths{}, w, r)
+}
+
+func (repoDetails *RepoDetails) ServeDashboardTemplateWith(p Paths, w http.ResponseWriter, r *http.Request) {
+	if err := repoDetails.Update(); err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	var writer bytes.Buffer
+	if err := repoDetails.WriteDashboardTemplate(r.URL.Query().Get("user"), p, &writer); err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(writer.Bytes())
+}
+
+func (repoDetails *RepoDetails) WriteDashboardTemplate(user string, p Paths, w io.Writer) error {
+	if user == "" {
+		user, _ = repoDetails.Repo.GetUserEmail()
+	}
+	user = repoDetails.People.Canonical(user)
+	var openReviews []review.Summary
+	for _, branch := range repoDetails.Branches {
+		openReviews = append(openReviews, branch.OpenReviews...)
+	}
+	userInbox, err := inbox.Build(openReviews, repoDetails.People, user, func(r *review.Summary) (string, error) {
+		details, err := r.Details()
+		if err != nil {
+			return "", err
+		}
+		return details.GetHeadCommit()
+	})
+	if err != nil {
+		return err
+	}
+	type templateArgs struct {
+		RepoDetails *RepoDetails
+		Inbox       *inbox.Inbox
+	}
+	args := templateArgs{
+		RepoDetails: repoDetails,
+		Inbox:       userInbox,
+	}
+	return ServeTemplate(args, p, w, "dashboard", dashboard_html, template.FuncMap{
+		"displayName": repoDetails.People.DisplayName,
+	})
+}
+
+// Shows a discussion topic along with the replies to it
+// The discussion is given by the 'discussion' URL parameter.
+func (repoDetails *RepoDetails) ServeDiscussionTemplate(w http.ResponseWriter, r *http.Request) {
+	repoDetails.ServeDiscussionTemplateWith(ServePaths{}, w, r)
+}
+
+func (repoDetails *RepoDetails) ServeDiscussionTemplateWith(p Paths, w http.ResponseWriter, r *http.Request) {
+	if err := repoDetails.Update(); err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	discussionParam := r.URL.Query().Get("discussion")
+	if discussionParam == "" {
+		ServeErrorTemplate(errors.New("No discussion specified"), http.StatusBadRequest, w)
+		return
+	}
+	var writer bytes.Buffer
+	if err := repoDetails.WriteDiscussionTemplate(discussionParam, p, &writer); err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(writer.Bytes())
+}
+
+func (repoDetails *RepoDetails) WriteDiscussionTemplate(name string, p Paths, w io.Writer) error {
+	d, err := review.GetDiscussion(repoDetails.Repo, name)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return fmt.Errorf("There is no discussion matching %q", name)
+	}
+	d.CheckSignatures()
+	d.Comments = review.HideRetracted(d.Comments)
+	type templateArgs struct {
+		RepoDetails *RepoDetails
+		Discussion  *review.Discussion
+	}
+	args := templateArgs{
+		RepoDetails: repoDetails,
+		Discussion:  d,
+	}
+	return ServeTemplate(args, p, w, "discussion", discussion_html, template.FuncMap{
+		"displayName": repoDetails.People.DisplayName,
+		"signatureBadge": func(result *gpg.Result) string {
+			return output.SignatureBadge(repoDetails.People, result)
+		},
+	})
 }
 
 // Show a review with inline diff
@@ -240,12 +444,37 @@ func (repoDetails *RepoDetails) ServeReviewTemplateWith(p Paths, w http.Response
 		ServeErrorTemplate(errors.New("No review specified"), http.StatusBadRequest, w)
 		return
 	}
-	if err := checkStringLooksLikeHash(reviewParam); err != nil {
+	if err := checkStringLooksLikeReviewName(reviewParam); err != nil {
 		ServeErrorTemplate(err, http.StatusBadRequest, w)
 		return
 	}
+	// Highlight the comments that are new since the user last marked the
+	// review as read. Viewing the page does not change the read state, as
+	// the visitor need not be the user; that is left to "mark-read".
+	//
+	// The user is given by the 'user' URL parameter, and defaults to the git user.
+	readState, err := readstate.Load(repoDetails.Repo)
+	if err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	userEmail := r.URL.Query().Get("user")
+	if userEmail == "" {
+		userEmail, _ = repoDetails.Repo.GetUserEmail()
+	}
+	userEmail = repoDetails.People.Canonical(userEmail)
+	reviewDetails, err := review.Get(repoDetails.Repo, reviewParam)
+	if err != nil {
+		ServeErrorTemplate(err, http.StatusInternalServerError, w)
+		return
+	}
+	if reviewDetails == nil {
+		ServeErrorTemplate(fmt.Errorf("No review matches %q", reviewParam), http.StatusNotFound, w)
+		return
+	}
+	unread := readState.UnreadThreads(userEmail, reviewDetails.Summary)
 	var writer bytes.Buffer
-	if err := repoDetails.WriteReviewTemplate(reviewParam, p, &writer); err != nil {
+	if err := repoDetails.writeReviewTemplate(reviewDetails, unread, p, &writer); err != nil {
 		ServeErrorTemplate(err, http.StatusInternalServerError, w)
 		return
 	}
@@ -258,6 +487,19 @@ func (repoDetails *RepoDetails) WriteReviewTemplate(reviewRev string, p Paths, w
 	if err != nil {
 		return err
 	}
+	if reviewDetails == nil {
+		return fmt.Errorf("No review matches %q", reviewRev)
+	}
+	return repoDetails.writeReviewTemplate(reviewDetails, nil, p, w)
+}
+
+// writeReviewTemplate writes the review, highlighting the comment threads
+// whose hashes are in the 'unread' set.
+func (repoDetails *RepoDetails) writeReviewTemplate(reviewDetails *review.Review, unread map[string]bool, p Paths, w io.Writer) error {
+	if err := reviewDetails.CheckSignatures(); err != nil {
+		return err
+	}
+	reviewRev := reviewDetails.Summary.Revision
 	commit := reviewDetails.Summary.Revision
 	commitDetails, err := repoDetails.Repo.GetCommitDetails(commit)
 	if err != nil {
@@ -308,7 +550,8 @@ func (repoDetails *RepoDetails) WriteReviewTemplate(reviewRev string, p Paths, w
 
 	var commitThreads = make(map[uint32][]review.CommentThread)
 	var lineThreads = make(map[string]map[uint32][]review.CommentThread)
-	output.SeparateComments(reviewDetails.Summary.Comments, commitThreads, lineThreads)
+	var oldLineThreads = make(map[string]map[uint32][]review.CommentThread)
+	output.SeparateComments(review.HideRetracted(reviewDetails.Summary.Comments), commitThreads, lineThreads, oldLineThreads)
 
 	type templateArgs struct {
 		RepoDetails *RepoDetails
@@ -320,10 +563,17 @@ func (repoDetails *RepoDetails) WriteReviewTemplate(reviewRev string, p Paths, w
 		CommitThreads map[uint32][]review.CommentThread
 		ReviewDetails *review.Review
 		LineThreads map[string]map[uint32][]review.CommentThread
+		OldLineThreads map[string]map[uint32][]review.CommentThread
 		Diffs []repository.FileDiff
+		Timeline []review.Event
+		Checklist []checklist.Item
+		CIMatrix []ci.Report
+		CIStatus string
 		Previous *ReviewNavigation
 		Next *ReviewNavigation
 	}
+	// Malformed CI reports are left out of the page rather than failing it.
+	ciMatrix, _ := reviewDetails.GetCIMatrix()
 	args := templateArgs{
 		RepoDetails: repoDetails,
 		BranchNum: uint64(reviewIndex.Branch),
@@ -334,12 +584,25 @@ func (repoDetails *RepoDetails) WriteReviewTemplate(reviewRev string, p Paths, w
 		CommitThreads: commitThreads,
 		ReviewDetails: reviewDetails,
 		LineThreads: lineThreads,
+		OldLineThreads: oldLineThreads,
 		Diffs: diffs,
+		Timeline: reviewDetails.Timeline(),
+		Checklist: reviewDetails.GetChecklist(),
+		CIMatrix: ciMatrix,
+		CIStatus: ci.AggregateStatus(ciMatrix),
 		Previous: previousReview,
 		Next: nextReview,
 	}
 
-	return ServeTemplate(args, p, w, "review", review_html)
+	return ServeTemplate(args, p, w, "review", review_html, template.FuncMap{
+		"displayName": repoDetails.People.DisplayName,
+		"isUnread": func(hash string) bool { return unread[hash] },
+		"signatureBadge": func(result *gpg.Result) string {
+			return output.SignatureBadge(repoDetails.People, result)
+		},
+		// The URLs of reports from local checks are the hashes of their logs.
+		"isLink": func(url string) bool { return strings.Contains(url, "://") },
+	})
 }
 
 // ServeEntryPointRedirect writes the main redirect response to the given writer.
@@ -9,6 +9,7 @@ import (
 
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/identity"
 )
 
 type ReviewType int
@@ -53,6 +54,8 @@ type RepoDetails struct {
 	Branches           BranchList
 	AbandonedReviews   []review.Summary
 	ReviewMap          map[string]ReviewIndex
+	Discussions        []review.Discussion
+	People             *identity.Registry
 }
 
 func (reviewIndex *ReviewIndex) GetBranchTitle(repoDetails *RepoDetails) string {
@@ -163,6 +166,16 @@ func (repoDetails *RepoDetails) GetBranchDetails(branch string) *BranchDetails {
 	return details
 }
 
+// AllReviews returns every review in the repository, as of the last update.
+func (repoDetails *RepoDetails) AllReviews() []review.Summary {
+	var reviews []review.Summary
+	for _, branch := range repoDetails.Branches {
+		reviews = append(reviews, branch.OpenReviews...)
+		reviews = append(reviews, branch.ClosedReviews...)
+	}
+	return append(reviews, repoDetails.AbandonedReviews...)
+}
+
 func (repoDetails *RepoDetails) Update() error {
 	stateHash, err := repoDetails.Repo.GetRepoStateHash()
 	if err != nil {
@@ -173,6 +186,8 @@ func (repoDetails *RepoDetails) Update() error {
 	}
 
 	repoDetails.UpdateRepoDescription()
+	// A malformed people file just means that emails are shown as they are.
+	repoDetails.People, _ = identity.Load(repoDetails.Repo)
 
 	branchesSet := make(map[string]*BranchDetails)
 	allReviews := review.ListAll(repoDetails.Repo)
@@ -235,10 +250,16 @@ func (repoDetails *RepoDetails) Update() error {
 		}
 	}
 
+	discussions, err := review.ListDiscussions(repoDetails.Repo)
+	if err != nil {
+		return err
+	}
+
 	repoDetails.Branches         = branches
 	repoDetails.AbandonedReviews = abandonedReviews
 	repoDetails.RepoHash         = stateHash
 	repoDetails.ReviewMap        = reviewMap
+	repoDetails.Discussions      = discussions
 
 	return nil
 }
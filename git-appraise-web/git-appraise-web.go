@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -35,6 +36,15 @@ func (ServeMultiPaths) Branch(branch uint64) string {
 func (ServeMultiPaths) Review(review string) string {
 	return fmt.Sprintf("review.html?review=%s", review)
 }
+func (ServeMultiPaths) Search(query string) string {
+	return "search.html?q=" + url.QueryEscape(query)
+}
+func (ServeMultiPaths) Dashboard(user string) string {
+	return "dashboard.html?user=" + url.QueryEscape(user)
+}
+func (ServeMultiPaths) Discussion(discussion string) string {
+	return fmt.Sprintf("discussion.html?discussion=%s", discussion)
+}
 
 type reposMap map[string]*web.RepoDetails
 type Repos atomic.Pointer[reposMap]
@@ -121,6 +131,33 @@ func (repos *Repos) ServeReviewTemplate(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (repos *Repos) ServeSearchTemplate(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("repo")
+	if repoDetails, found := repos.Load()[repo]; found {
+		repoDetails.ServeSearchTemplateWith(ServeMultiPaths{}, w, r)
+	} else {
+		http.Error(w, "Repository " + repo + " not found!", http.StatusNotFound)
+	}
+}
+
+func (repos *Repos) ServeDashboardTemplate(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("repo")
+	if repoDetails, found := repos.Load()[repo]; found {
+		repoDetails.ServeDashboardTemplateWith(ServeMultiPaths{}, w, r)
+	} else {
+		http.Error(w, "Repository " + repo + " not found!", http.StatusNotFound)
+	}
+}
+
+func (repos *Repos) ServeDiscussionTemplate(w http.ResponseWriter, r *http.Request) {
+	repo := r.PathValue("repo")
+	if repoDetails, found := repos.Load()[repo]; found {
+		repoDetails.ServeDiscussionTemplateWith(ServeMultiPaths{}, w, r)
+	} else {
+		http.Error(w, "Repository " + repo + " not found!", http.StatusNotFound)
+	}
+}
+
 func (repos *Repos) ServeReposTemplate(w http.ResponseWriter, r *http.Request) {
 	type ReposInfo struct {
 		Repos  reposMap
@@ -182,12 +219,18 @@ func webServe() {
 	repo, _, _       := strings.Cut(paths.Repo(), "?")
 	branch, _, _     := strings.Cut(paths.Branch(0), "?")
 	review, _, _     := strings.Cut(paths.Review(""), "?")
+	search, _, _     := strings.Cut(paths.Search(""), "?")
+	dashboard, _, _  := strings.Cut(paths.Dashboard(""), "?")
+	discussion, _, _ := strings.Cut(paths.Discussion(""), "?")
 
 	http.HandleFunc("/repos.html", repos.ServeReposTemplate)
 	http.HandleFunc(stylesheet, repos.ServeStyleSheet)
 	http.HandleFunc("/{repo}/" + repo, repos.ServeRepoTemplate)
 	http.HandleFunc("/{repo}/" + branch, repos.ServeBranchTemplate)
 	http.HandleFunc("/{repo}/" + review, repos.ServeReviewTemplate)
+	http.HandleFunc("/{repo}/" + search, repos.ServeSearchTemplate)
+	http.HandleFunc("/{repo}/" + dashboard, repos.ServeDashboardTemplate)
+	http.HandleFunc("/{repo}/" + discussion, repos.ServeDiscussionTemplate)
 	http.HandleFunc("/", repos.ServeEntryPointRedirect)
 
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", *port), nil); err != nil {
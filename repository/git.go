@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strconv"
@@ -181,6 +182,22 @@ func (repo *GitRepo) GetSubmitStrategy() (string, error) {
 	return submitStrategy, nil
 }
 
+// GetConfigValues returns all of the values of the given git config
+// setting, or nothing if it is not set.
+func (repo *GitRepo) GetConfigValues(name string) ([]string, error) {
+	out, _, err := repo.runGitCommandRaw("config", "--get-all", name)
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		// The setting does not exist.
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
 // HasUncommittedChanges returns true if there are local, uncommitted changes.
 func (repo *GitRepo) HasUncommittedChanges() (bool, error) {
 	out, err := repo.runGitCommand("status", "--porcelain")
@@ -217,6 +234,22 @@ func (repo *GitRepo) GetHeadRef() (string, error) {
 	return repo.runGitCommand("symbolic-ref", "HEAD")
 }
 
+// GetRefName returns the full name of the ref with the given name, which may
+// be abbreviated (e.g. "master") or relative (e.g. "HEAD" or "@{-1}").
+//
+// If the name does not refer to a ref (e.g. because it is a commit hash),
+// then the empty string is returned.
+func (repo *GitRepo) GetRefName(name string) (string, error) {
+	if strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid ref name %q", name)
+	}
+	out, err := repo.runGitCommand("rev-parse", "--verify", "-q", "--symbolic-full-name", name)
+	if err != nil {
+		return "", nil
+	}
+	return out, nil
+}
+
 // GetCommitHash returns the hash of the commit pointed to by the given ref.
 func (repo *GitRepo) GetCommitHash(ref string) (string, error) {
 	return repo.runGitCommand("show", "-s", "--format=%H", ref, "--")
@@ -415,6 +448,51 @@ func (repo *GitRepo) Show(commit, path string) (string, error) {
 	return repo.runGitCommand("show", fmt.Sprintf("%s:%s", commit, path), "--")
 }
 
+// GetPathType returns the type of the object at the given path in the
+// given commit; i.e. "blob" for a file, or "tree" for a directory.
+func (repo *GitRepo) GetPathType(commit, path string) (string, error) {
+	return repo.runGitCommand("cat-file", "-t", fmt.Sprintf("%s:%s", commit, path))
+}
+
+// GetPathHistory returns the paths that the given file has had in the
+// history of the given commit, following renames, with the most recent first.
+func (repo *GitRepo) GetPathHistory(commit, path string) ([]string, error) {
+	out, err := repo.runGitCommand("log", "--follow", "--name-only", "--format=", commit, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		if line != "" && !seen[line] {
+			seen[line] = true
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+// ListRenames returns the files renamed in the history of the given
+// commit, as a map from the name of each file before it was renamed to
+// the name it was given. Only the most recent rename of a path is kept.
+func (repo *GitRepo) ListRenames(commit string) (map[string]string, error) {
+	out, err := repo.runGitCommand("log", "-M", "--diff-filter=R", "--name-status", "--format=", commit, "--")
+	if err != nil {
+		return nil, err
+	}
+	renames := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || !strings.HasPrefix(fields[0], "R") {
+			continue
+		}
+		if _, ok := renames[fields[1]]; !ok {
+			renames[fields[1]] = fields[2]
+		}
+	}
+	return renames, nil
+}
+
 // SwitchToRef changes the currently-checked-out ref.
 func (repo *GitRepo) SwitchToRef(ref string) error {
 	// If the ref starts with "refs/heads/", then we have to trim that prefix,
@@ -426,6 +504,20 @@ func (repo *GitRepo) SwitchToRef(ref string) error {
 	return err
 }
 
+// AddWorktree checks out the given commit, with a detached HEAD, in a new
+// working tree at the given path.
+func (repo *GitRepo) AddWorktree(path, commit string) error {
+	_, err := repo.runGitCommand("worktree", "add", "--detach", path, commit)
+	return err
+}
+
+// RemoveWorktree removes the working tree at the given path, even if it
+// has been modified.
+func (repo *GitRepo) RemoveWorktree(path string) error {
+	_, err := repo.runGitCommand("worktree", "remove", "--force", path)
+	return err
+}
+
 // mergeArchives merges two archive refs.
 func (repo *GitRepo) mergeArchives(archive, remoteArchive string) error {
 	hasRemote, err := repo.HasRef(remoteArchive)
@@ -581,6 +673,71 @@ func (repo *GitRepo) RebaseAndSignRef(ref string) error {
 	return repo.runGitCommandInline("rebase", "-S", "-i", ref)
 }
 
+// RebaseRefOnto rebases the commits of the current ref that are not
+// reachable from 'upstream' onto the 'onto' commit, without prompting
+// the user.
+func (repo *GitRepo) RebaseRefOnto(onto, upstream string) error {
+	return repo.runGitCommandWithIO(nil, os.Stdout, os.Stderr, "rebase", "--onto", onto, upstream)
+}
+
+// CreateBranch creates a new branch starting at the given commit, and
+// switches to it.
+func (repo *GitRepo) CreateBranch(branch, startPoint string) error {
+	_, err := repo.runGitCommand("checkout", "-b", strings.TrimPrefix(branch, branchRefPrefix), startPoint)
+	return err
+}
+
+// RevertCommits creates a commit reverting each of the given commits,
+// in the given order, on top of the current ref.
+func (repo *GitRepo) RevertCommits(commits ...string) error {
+	args := append([]string{"revert", "--no-edit"}, commits...)
+	return repo.runGitCommandInline(args...)
+}
+
+// RebaseRefNonInteractive rebases the current ref onto the given one
+// without prompting the user, optionally signing the result.
+func (repo *GitRepo) RebaseRefNonInteractive(ref string, sign bool) error {
+	args := []string{"rebase"}
+	if sign {
+		args = append(args, "-S")
+	}
+	args = append(args, ref)
+	return repo.runGitCommandWithIO(nil, os.Stdout, os.Stderr, args...)
+}
+
+// IsRebaseInProgress returns whether or not a rebase has been started
+// but has not yet been completed or aborted.
+func (repo *GitRepo) IsRebaseInProgress() (bool, error) {
+	for _, stateDir := range []string{"rebase-merge", "rebase-apply"} {
+		path, err := repo.runGitCommand("rev-parse", "--git-path", stateDir)
+		if err != nil {
+			return false, err
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(repo.Path, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// ContinueRebase continues a rebase that stopped, e.g. due to conflicts,
+// without prompting the user to edit any commit messages.
+func (repo *GitRepo) ContinueRebase() error {
+	env := append(os.Environ(), "GIT_EDITOR=true")
+	return repo.runGitCommandWithIOAndEnv(nil, os.Stdout, os.Stderr, env, "rebase", "--continue")
+}
+
+// AbortRebase aborts a rebase that stopped, restoring the original ref.
+func (repo *GitRepo) AbortRebase() error {
+	_, err := repo.runGitCommand("rebase", "--abort")
+	return err
+}
+
 // ListCommits returns the list of commits reachable from the given ref.
 //
 // The generated list is in chronological order (with the oldest commit first).
@@ -626,6 +783,49 @@ func (repo *GitRepo) ListCommitsBetween(from, to string) ([]string, error) {
 	return strings.Split(out, "\n"), nil
 }
 
+// FindMergeCommit returns the merge commit in the history of the given ref
+// that merged in the given commit, or the empty string if there is no such
+// merge commit (e.g. because the commit was fast-forwarded onto the ref).
+//
+// A merge only merged in the commit if the commit is reachable from one of
+// its other parents, but not from its first parent; later merges, which
+// have the commit in the history of their first parent, are ignored.
+func (repo *GitRepo) FindMergeCommit(commit, ref string) (string, error) {
+	out, err := repo.runGitCommand("rev-list", "--ancestry-path", "--merges", "--topo-order", "--reverse", commit+".."+ref)
+	if err != nil {
+		return "", err
+	}
+	if out == "" {
+		return "", nil
+	}
+	for _, merge := range strings.Split(out, "\n") {
+		details, err := repo.GetCommitDetails(merge)
+		if err != nil {
+			return "", err
+		}
+		if merged, err := repo.IsAncestor(commit, details.Parents[0]); err != nil {
+			return "", err
+		} else if !merged {
+			// The merge is on the ancestry path from the commit, so it
+			// must be reachable from one of the other parents.
+			return merge, nil
+		}
+	}
+	return "", nil
+}
+
+// GetCommitSignature returns the status of the signature on the given
+// commit, as one of the letters of git's "%G?" format (e.g. "G" for a
+// good signature, or "N" for no signature), and the key that made it.
+func (repo *GitRepo) GetCommitSignature(commit string) (string, string, error) {
+	out, err := repo.runGitCommand("show", "-s", "--format=%G?%n%GK", commit, "--")
+	if err != nil {
+		return "", "", err
+	}
+	status, key, _ := strings.Cut(out, "\n")
+	return status, strings.TrimSpace(key), nil
+}
+
 // StoreBlob writes the given file to the repository and returns its hash.
 func (repo *GitRepo) StoreBlob(contents string) (string, error) {
 	stdin := strings.NewReader(contents)
@@ -757,6 +957,38 @@ func (repo *GitRepo) CreateCommitWithTree(details *CommitDetails, t *Tree) (stri
 	return repo.CreateCommit(details)
 }
 
+// RewriteCommit creates a copy of the given commit, with the same tree
+// and author, but with the given parents and message, and returns the
+// hash of the copy. The copy is optionally signed.
+func (repo *GitRepo) RewriteCommit(commit string, parents []string, message string, sign bool) (string, error) {
+	authorship, err := repo.runGitCommand("show", "-s", "--date=raw", "--format=%T%x00%an%x00%ae%x00%ad", commit, "--")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Split(authorship, "\x00")
+	if len(fields) != 4 {
+		return "", fmt.Errorf("malformed details for the commit %q", commit)
+	}
+	args := []string{"commit-tree", fields[0]}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+	if sign {
+		args = append(args, "-S")
+	}
+	args = append(args, "-F", "-")
+	env := append(os.Environ(),
+		"GIT_AUTHOR_NAME="+fields[1],
+		"GIT_AUTHOR_EMAIL="+fields[2],
+		"GIT_AUTHOR_DATE="+fields[3])
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	if err := repo.runGitCommandWithIOAndEnv(strings.NewReader(message), &stdout, &stderr, env, args...); err != nil {
+		return "", fmt.Errorf("failure rewriting the commit %q: %s", commit, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 // SetRef sets the commit pointed to by the specified ref to `newCommitHash`,
 // iff the ref currently points `previousCommitHash`.
 func (repo *GitRepo) SetRef(ref, newCommitHash, previousCommitHash string) error {
@@ -1026,6 +1258,25 @@ func (repo *GitRepo) AppendNote(notesRef, revision string, note Note) error {
 	return err
 }
 
+// SetNotes replaces the notes of a revision under the given ref, removing
+// them if there are none.
+func (repo *GitRepo) SetNotes(notesRef, revision string, notes []Note) error {
+	if len(notes) == 0 {
+		_, err := repo.runGitCommand("notes", "--ref", notesRef, "remove", "--ignore-missing", revision)
+		return err
+	}
+	var lines []string
+	for _, note := range notes {
+		lines = append(lines, string(note))
+	}
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader(strings.Join(lines, "\n"))
+	if err := repo.runGitCommandWithIO(stdin, &stdout, &stderr, "notes", "--ref", notesRef, "add", "-f", "-F", "-", revision); err != nil {
+		return fmt.Errorf("failure setting the notes for %q in %q: %v: %q", revision, notesRef, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
 // ListNotedRevisions returns the collection of revisions that are annotated by notes in the given ref.
 func (repo *GitRepo) ListNotedRevisions(notesRef string) []string {
 	var revisions []string
@@ -1131,6 +1382,12 @@ func getRemoteNotesRef(remote, localNotesRef string) string {
 	return notesRefPrefix + "remotes/" + remote + "/" + relativeNotesRef
 }
 
+// RemoteNotesRef returns the ref into which the given notes ref is fetched
+// from the given remote.
+func RemoteNotesRef(remote, notesRef string) string {
+	return getRemoteNotesRef(remote, notesRef)
+}
+
 func getLocalNotesRef(remote, remoteNotesRef string) string {
 	relativeNotesRef := strings.TrimPrefix(remoteNotesRef, notesRefPrefix+"remotes/"+remote+"/")
 	return notesRefPrefix + relativeNotesRef
@@ -1297,3 +1554,35 @@ func (repo *GitRepo) Push(remote string, refSpecs ...string) error {
 	}
 	return nil
 }
+
+// PushAtomic pushes the given refs to a remote repo, such that either
+// all of them are updated or none are.
+//
+// The leases argument maps from remote refs to the values they are
+// expected to have; if any of them has a different value, then the push
+// is rejected. The refs with leases are allowed to be forced.
+func (repo *GitRepo) PushAtomic(remote string, leases map[string]string, refSpecs ...string) error {
+	pushArgs := []string{"push", "--atomic"}
+	var leasedRefs []string
+	for ref := range leases {
+		leasedRefs = append(leasedRefs, ref)
+	}
+	sort.Strings(leasedRefs)
+	for _, ref := range leasedRefs {
+		pushArgs = append(pushArgs, fmt.Sprintf("--force-with-lease=%s:%s", ref, leases[ref]))
+	}
+	pushArgs = append(pushArgs, remote)
+	pushArgs = append(pushArgs, refSpecs...)
+	err := repo.runGitCommandInline(pushArgs...)
+	if err != nil {
+		return fmt.Errorf("Failed to push the local refs to the remote '%s': %v", remote, err)
+	}
+	return nil
+}
+
+// ResetCurrentRef points the current ref, and the working tree, at the
+// given commit, while keeping any local changes.
+func (repo *GitRepo) ResetCurrentRef(commit string) error {
+	_, err := repo.runGitCommand("reset", "--keep", commit)
+	return err
+}
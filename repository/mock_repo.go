@@ -78,6 +78,79 @@ type mockRepoForTest struct {
 	Refs    map[string]string            `json:"refs,omitempty"`
 	Commits map[string]mockCommit        `json:"commits,omitempty"`
 	Notes   map[string]map[string]string `json:"notes,omitempty"`
+	// Files holds the contents of the files in those commits whose files
+	// have been set by a test, keyed by commit and then by path.
+	Files map[string]map[string]string `json:"files,omitempty"`
+	// pushError is the error returned by every push, if any.
+	pushError error
+	// stopRebases causes rebases to stop, as if they had conflicts.
+	stopRebases bool
+	// stoppedRebase is the ref onto which the stopped rebase, if any, is rebasing.
+	stoppedRebase string
+	// dataDir overrides the data directory of the repository, if set.
+	dataDir string
+	// config holds the values of the git config settings set by tests.
+	config map[string][]string
+}
+
+// MockRepo is the mock Repo returned by NewMockRepoForTest, along with the
+// methods that tests can use to change its state.
+type MockRepo interface {
+	Repo
+
+	// SetFile sets the contents of the file at the given path in the given
+	// commit. Once any file has been set for a commit, only the files that
+	// have been set for it exist in it.
+	SetFile(commit, path, contents string)
+
+	// RejectPushes makes every subsequent push fail with the given error,
+	// or succeed again if it is nil.
+	RejectPushes(err error)
+
+	// StopRebases makes every subsequent rebase stop before completing,
+	// as if it had conflicts, or complete again if stop is false.
+	StopRebases(stop bool)
+
+	// SetDataDir sets the data directory of the repository, so that
+	// tests can give it one that exists.
+	SetDataDir(dir string)
+
+	// SetConfigValues sets the values of the given git config setting.
+	SetConfigValues(name string, values ...string)
+}
+
+// SetFile sets the contents of the file at the given path in the given commit.
+func (r *mockRepoForTest) SetFile(commit, path, contents string) {
+	if r.Files == nil {
+		r.Files = make(map[string]map[string]string)
+	}
+	if r.Files[commit] == nil {
+		r.Files[commit] = make(map[string]string)
+	}
+	r.Files[commit][path] = contents
+}
+
+// RejectPushes makes every subsequent push fail with the given error.
+func (r *mockRepoForTest) RejectPushes(err error) {
+	r.pushError = err
+}
+
+// StopRebases makes every subsequent rebase stop before completing.
+func (r *mockRepoForTest) StopRebases(stop bool) {
+	r.stopRebases = stop
+}
+
+// SetDataDir sets the data directory of the repository.
+func (r *mockRepoForTest) SetDataDir(dir string) {
+	r.dataDir = dir
+}
+
+// SetConfigValues sets the values of the given git config setting.
+func (r *mockRepoForTest) SetConfigValues(name string, values ...string) {
+	if r.config == nil {
+		r.config = make(map[string][]string)
+	}
+	r.config[name] = values
 }
 
 func (r *mockRepoForTest) createCommit(message string, time string, parents []string) (string, error) {
@@ -184,7 +257,12 @@ func NewMockRepoForTest() Repo {
 func (r *mockRepoForTest) GetPath() string { return "~/mockRepo/" }
 
 // GetPath returns the path to the repo.
-func (r *mockRepoForTest) GetDataDir() (string, error) { return "~/mockRepo/.git", nil }
+func (r *mockRepoForTest) GetDataDir() (string, error) {
+	if r.dataDir != "" {
+		return r.dataDir, nil
+	}
+	return "~/mockRepo/.git", nil
+}
 
 // GetRepoStateHash returns a hash which embodies the entire current state of a repository.
 func (r *mockRepoForTest) GetRepoStateHash() (string, error) {
@@ -210,6 +288,10 @@ func (r *mockRepoForTest) GetCoreEditor() (string, error) { return "vi", nil }
 // GetSubmitStrategy returns the way in which a review is submitted
 func (r *mockRepoForTest) GetSubmitStrategy() (string, error) { return "merge", nil }
 
+// GetConfigValues returns all of the values of the given git config
+// setting, or nothing if it is not set.
+func (r *mockRepoForTest) GetConfigValues(name string) ([]string, error) { return r.config[name], nil }
+
 // HasUncommittedChanges returns true if there are local, uncommitted changes.
 func (r *mockRepoForTest) HasUncommittedChanges() (bool, error) { return false, nil }
 
@@ -256,6 +338,19 @@ func (r *mockRepoForTest) VerifyGitRef(ref string) error {
 // GetHeadRef returns the ref that is the current HEAD.
 func (r *mockRepoForTest) GetHeadRef() (string, error) { return r.Head, nil }
 
+// GetRefName returns the full name of the ref with the given name.
+func (r *mockRepoForTest) GetRefName(name string) (string, error) {
+	if name == "HEAD" {
+		return r.Head, nil
+	}
+	for _, ref := range []string{name, "refs/heads/" + name} {
+		if _, ok := r.Refs[ref]; ok {
+			return ref, nil
+		}
+	}
+	return "", nil
+}
+
 // GetCommitHash returns the hash of the commit pointed to by the given ref.
 func (r *mockRepoForTest) GetCommitHash(ref string) (string, error) {
 	err := r.VerifyGitRef(ref)
@@ -433,15 +528,59 @@ func (r *mockRepoForTest) ParsedDiff1(commit string, diffArgs ...string) ([]File
 
 // Show returns the contents of the given file at the given commit.
 func (r *mockRepoForTest) Show(commit, path string) (string, error) {
+	if resolved, err := r.resolveLocalRef(commit); err == nil && r.Files[resolved] != nil {
+		contents, ok := r.Files[resolved][path]
+		if !ok {
+			return "", fmt.Errorf("The path %q does not exist in %q", path, commit)
+		}
+		return contents, nil
+	}
 	return fmt.Sprintf("%s:%s", commit, path), nil
 }
 
+// GetPathType returns the type of the object at the given path in the given commit.
+//
+// Paths ending in a '/' are treated as directories, and all others as files.
+func (r *mockRepoForTest) GetPathType(commit, path string) (string, error) {
+	if strings.HasSuffix(path, "/") {
+		return "tree", nil
+	}
+	return "blob", nil
+}
+
+// GetPathHistory returns the paths that the given file has had in the
+// history of the given commit; files are never renamed in the mock repo.
+func (r *mockRepoForTest) GetPathHistory(commit, path string) ([]string, error) {
+	return []string{path}, nil
+}
+
+// ListRenames returns the files renamed in the history of the given commit;
+// files are never renamed in the mock repo.
+func (r *mockRepoForTest) ListRenames(commit string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
 // SwitchToRef changes the currently-checked-out ref.
 func (r *mockRepoForTest) SwitchToRef(ref string) error {
 	r.Head = ref
 	return nil
 }
 
+// AddWorktree checks out the given commit, with a detached HEAD, in a new
+// working tree at the given path.
+func (r *mockRepoForTest) AddWorktree(path, commit string) error {
+	if _, err := r.resolveLocalRef(commit); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveWorktree removes the working tree at the given path, even if it
+// has been modified.
+func (r *mockRepoForTest) RemoveWorktree(path string) error {
+	return nil
+}
+
 // ArchiveRef adds the current commit pointed to by the 'ref' argument
 // under the ref specified in the 'archive' argument.
 //
@@ -512,6 +651,14 @@ func (r *mockRepoForTest) MergeAndSignRef(ref string, fastForward bool,
 
 // RebaseRef rebases the current ref onto the given one.
 func (r *mockRepoForTest) RebaseRef(ref string) error {
+	if r.stopRebases {
+		r.stoppedRebase = ref
+		return fmt.Errorf("could not rebase onto %q due to conflicts", ref)
+	}
+	return r.rebaseCurrentRef(ref)
+}
+
+func (r *mockRepoForTest) rebaseCurrentRef(ref string) error {
 	parentHash := r.Refs[ref]
 	origCommit, err := r.getCommit(r.Head)
 	if err != nil {
@@ -535,6 +682,104 @@ func (r *mockRepoForTest) RebaseRef(ref string) error {
 // result.
 func (r *mockRepoForTest) RebaseAndSignRef(ref string) error { return nil }
 
+// RebaseRefOnto rebases the commits of the current ref that are not
+// reachable from 'upstream' onto the 'onto' commit, without prompting
+// the user.
+func (r *mockRepoForTest) RebaseRefOnto(onto, upstream string) error {
+	if r.stopRebases {
+		r.stoppedRebase = onto
+		return fmt.Errorf("could not rebase onto %q due to conflicts", onto)
+	}
+	parentHash, err := r.resolveLocalRef(onto)
+	if err != nil {
+		return err
+	}
+	origCommit, err := r.getCommit(r.Head)
+	if err != nil {
+		return err
+	}
+	newCommitHash, err := r.createCommit(origCommit.Message, origCommit.Time, []string{parentHash})
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(r.Head, "refs/heads/") {
+		r.Refs[r.Head] = newCommitHash
+	} else {
+		r.Head = newCommitHash
+	}
+	return nil
+}
+
+// CreateBranch creates a new branch starting at the given commit, and
+// switches to it.
+func (r *mockRepoForTest) CreateBranch(branch, startPoint string) error {
+	commit, err := r.resolveLocalRef(startPoint)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(branch, "refs/heads/") {
+		branch = "refs/heads/" + branch
+	}
+	r.Refs[branch] = commit
+	r.Head = branch
+	return nil
+}
+
+// RevertCommits creates a commit reverting each of the given commits,
+// in the given order, on top of the current ref.
+func (r *mockRepoForTest) RevertCommits(commits ...string) error {
+	for _, commit := range commits {
+		head, err := r.resolveLocalRef(r.Head)
+		if err != nil {
+			return err
+		}
+		origCommit, err := r.getCommit(commit)
+		if err != nil {
+			return err
+		}
+		newCommitHash, err := r.createCommit("Revert \""+origCommit.Message+"\"", origCommit.Time, []string{head})
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(r.Head, "refs/heads/") {
+			r.Refs[r.Head] = newCommitHash
+		} else {
+			r.Head = newCommitHash
+		}
+	}
+	return nil
+}
+
+// RebaseRefNonInteractive rebases the current ref onto the given one
+// without prompting the user, optionally signing the result.
+func (r *mockRepoForTest) RebaseRefNonInteractive(ref string, sign bool) error {
+	return r.RebaseRef(ref)
+}
+
+// IsRebaseInProgress returns whether or not a rebase has been started
+// but has not yet been completed or aborted.
+func (r *mockRepoForTest) IsRebaseInProgress() (bool, error) { return r.stoppedRebase != "", nil }
+
+// ContinueRebase continues a rebase that stopped, e.g. due to conflicts,
+// without prompting the user to edit any commit messages.
+func (r *mockRepoForTest) ContinueRebase() error {
+	if r.stoppedRebase == "" {
+		return errors.New("no rebase in progress")
+	}
+	ref := r.stoppedRebase
+	r.stoppedRebase = ""
+	return r.rebaseCurrentRef(ref)
+}
+
+// AbortRebase aborts a rebase that stopped, restoring the original ref.
+func (r *mockRepoForTest) AbortRebase() error {
+	if r.stoppedRebase == "" {
+		return errors.New("no rebase in progress")
+	}
+	r.stoppedRebase = ""
+	return nil
+}
+
 // ListCommits returns the list of commits reachable from the given ref.
 //
 // The generated list is in chronological order (with the oldest commit first).
@@ -557,6 +802,10 @@ func (r *mockRepoForTest) ListCommits(ref string) []string { return nil }
 //
 // The generated list is in chronological order (with the oldest commit first).
 func (r *mockRepoForTest) ListCommitsBetween(from, to string) ([]string, error) {
+	to, err := r.resolveLocalRef(to)
+	if err != nil {
+		return nil, err
+	}
 	commits := []string{to}
 	potentialCommits, _ := r.ancestors(to)
 	for _, commit := range potentialCommits {
@@ -571,6 +820,51 @@ func (r *mockRepoForTest) ListCommitsBetween(from, to string) ([]string, error)
 	return commits, nil
 }
 
+// FindMergeCommit returns the merge commit in the history of the given ref
+// that merged in the given commit, or the empty string if there is none.
+func (r *mockRepoForTest) FindMergeCommit(commit, ref string) (string, error) {
+	commit, err := r.resolveLocalRef(commit)
+	if err != nil {
+		return "", err
+	}
+	head, err := r.resolveLocalRef(ref)
+	if err != nil {
+		return "", err
+	}
+	ancestors, err := r.ancestors(head)
+	if err != nil {
+		return "", err
+	}
+	merge := ""
+	for _, candidate := range append([]string{head}, ancestors...) {
+		parents := r.Commits[candidate].Parents
+		if candidate == commit || len(parents) < 2 {
+			continue
+		}
+		// Only a merge that brought in the commit through one of its other
+		// parents merged it; any later merge already has it in its first parent.
+		if merged, err := r.IsAncestor(commit, parents[0]); err != nil || merged {
+			continue
+		}
+		if descends, err := r.IsAncestor(commit, candidate); err != nil || !descends {
+			continue
+		}
+		if merge == "" || r.Commits[candidate].Time < r.Commits[merge].Time {
+			merge = candidate
+		}
+	}
+	return merge, nil
+}
+
+// GetCommitSignature returns the status of the signature on the given
+// commit; the commits of the mock repository are never signed.
+func (r *mockRepoForTest) GetCommitSignature(commit string) (string, string, error) {
+	if _, err := r.resolveLocalRef(commit); err != nil {
+		return "", "", err
+	}
+	return "N", "", nil
+}
+
 // StoreBlob writes the given file to the repository and returns its hash.
 func (r *mockRepoForTest) StoreBlob(contents string) (string, error) {
 	return "", fmt.Errorf("not implemented")
@@ -588,18 +882,36 @@ func (r *mockRepoForTest) ReadTree(ref string) (*Tree, error) {
 
 // CreateCommit creates a commit object and returns its hash.
 func (r *mockRepoForTest) CreateCommit(details *CommitDetails) (string, error) {
-	return "", fmt.Errorf("not implemented")
+	return r.createCommit(details.Summary, details.Time, details.Parents)
+}
+
+// RewriteCommit creates a copy of the given commit, with the same tree
+// and author, but with the given parents and message, and returns the
+// hash of the copy. The copy is optionally signed.
+func (r *mockRepoForTest) RewriteCommit(commit string, parents []string, message string, sign bool) (string, error) {
+	origCommit, err := r.getCommit(commit)
+	if err != nil {
+		return "", err
+	}
+	return r.createCommit(message, origCommit.Time, parents)
 }
 
 // CreateCommitWithTree creates a commit object with the given tree and returns its hash.
 func (r *mockRepoForTest) CreateCommitWithTree(details *CommitDetails, t *Tree) (string, error) {
-	return "", fmt.Errorf("not implemented")
+	return r.createCommit(details.Summary, details.Time, details.Parents)
 }
 
 // SetRef sets the commit pointed to by the specified ref to `newCommitHash`,
 // iff the ref currently points `previousCommitHash`.
 func (r *mockRepoForTest) SetRef(ref, newCommitHash, previousCommitHash string) error {
-	return fmt.Errorf("not implemented")
+	if previousCommitHash != "" && r.Refs[ref] != previousCommitHash {
+		return fmt.Errorf("the ref %q does not point to %q", ref, previousCommitHash)
+	}
+	if _, err := r.getCommit(newCommitHash); err != nil {
+		return err
+	}
+	r.Refs[ref] = newCommitHash
+	return nil
 }
 
 // GetNotes reads the notes from the given ref that annotate the given revision.
@@ -627,12 +939,33 @@ func (r *mockRepoForTest) GetAllNotes(notesRef string) (map[string][]Note, error
 
 // AppendNote appends a note to a revision under the given ref.
 func (r *mockRepoForTest) AppendNote(ref, revision string, note Note) error {
+	if r.Notes[ref] == nil {
+		r.Notes[ref] = make(map[string]string)
+	}
 	existingNotes := r.Notes[ref][revision]
 	newNotes := existingNotes + "\n" + string(note)
 	r.Notes[ref][revision] = newNotes
 	return nil
 }
 
+// SetNotes replaces the notes of a revision under the given ref, removing
+// them if there are none.
+func (r *mockRepoForTest) SetNotes(ref, revision string, notes []Note) error {
+	if len(notes) == 0 {
+		delete(r.Notes[ref], revision)
+		return nil
+	}
+	var lines []string
+	for _, note := range notes {
+		lines = append(lines, string(note))
+	}
+	if r.Notes[ref] == nil {
+		r.Notes[ref] = make(map[string]string)
+	}
+	r.Notes[ref][revision] = strings.Join(lines, "\n")
+	return nil
+}
+
 // ListNotedRevisions returns the collection of revisions that are annotated by notes in the given ref.
 func (r *mockRepoForTest) ListNotedRevisions(notesRef string) []string {
 	var revisions []string
@@ -653,7 +986,7 @@ func (r *mockRepoForTest) Remotes() ([]string, error) {
 func (r *mockRepoForTest) Fetch(remote string, refspecs ...string) error { return nil }
 
 // PushNotes pushes git notes to a remote repo.
-func (r *mockRepoForTest) PushNotes(remote, notesRefPattern string) error { return nil }
+func (r *mockRepoForTest) PushNotes(remote, notesRefPattern string) error { return r.pushError }
 
 // PullNotes fetches the contents of the given notes ref from a remote repo,
 // and then merges them with the corresponding local notes using the
@@ -662,7 +995,7 @@ func (r *mockRepoForTest) PullNotes(remote, notesRefPattern string) error { retu
 
 // PushNotesAndArchive pushes the given notes and archive refs to a remote repo.
 func (r *mockRepoForTest) PushNotesAndArchive(remote, notesRefPattern, archiveRefPattern string) error {
-	return nil
+	return r.pushError
 }
 
 // PullNotesAndArchive fetches the contents of the notes and archives refs from
@@ -706,5 +1039,22 @@ func (r *mockRepoForTest) FetchAndReturnNewReviewHashes(remote, notesRefPattern
 
 // Push pushes the given refs to a remote repo.
 func (r *mockRepoForTest) Push(remote string, refPattern ...string) error {
+	return r.pushError
+}
+
+// PushAtomic pushes the given refs to a remote repo, such that either
+// all of them are updated or none are.
+func (r *mockRepoForTest) PushAtomic(remote string, leases map[string]string, refSpecs ...string) error {
+	return r.pushError
+}
+
+// ResetCurrentRef points the current ref, and the working tree, at the
+// given commit, while keeping any local changes.
+func (r *mockRepoForTest) ResetCurrentRef(commit string) error {
+	if strings.HasPrefix(r.Head, "refs/heads/") {
+		r.Refs[r.Head] = commit
+	} else {
+		r.Head = commit
+	}
 	return nil
 }
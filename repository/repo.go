@@ -213,6 +213,10 @@ type Repo interface {
 	// GetSubmitStrategy returns the way in which a review is submitted
 	GetSubmitStrategy() (string, error)
 
+	// GetConfigValues returns all of the values of the given git config
+	// setting, or nothing if it is not set.
+	GetConfigValues(name string) ([]string, error)
+
 	// HasUncommittedChanges returns true if there are local, uncommitted changes.
 	HasUncommittedChanges() (bool, error)
 
@@ -231,6 +235,12 @@ type Repo interface {
 	// GetHeadRef returns the ref that is the current HEAD.
 	GetHeadRef() (string, error)
 
+	// GetRefName returns the full name of the ref with the given name, which
+	// may be abbreviated (e.g. "master") or relative (e.g. "HEAD" or "@{-1}").
+	//
+	// If the name does not refer to a ref, then the empty string is returned.
+	GetRefName(name string) (string, error)
+
 	// GetCommitHash returns the hash of the commit pointed to by the given ref.
 	GetCommitHash(ref string) (string, error)
 
@@ -278,9 +288,30 @@ type Repo interface {
 	// Show returns the contents of the given file at the given commit.
 	Show(commit, path string) (string, error)
 
+	// GetPathType returns the type of the object at the given path in the
+	// given commit; i.e. "blob" for a file, or "tree" for a directory.
+	GetPathType(commit, path string) (string, error)
+
+	// GetPathHistory returns the paths that the given file has had in the
+	// history of the given commit, following renames, with the most recent first.
+	GetPathHistory(commit, path string) ([]string, error)
+
+	// ListRenames returns the files renamed in the history of the given
+	// commit, as a map from the name of each file before it was renamed to
+	// the name it was given. Only the most recent rename of a path is kept.
+	ListRenames(commit string) (map[string]string, error)
+
 	// SwitchToRef changes the currently-checked-out ref.
 	SwitchToRef(ref string) error
 
+	// AddWorktree checks out the given commit, with a detached HEAD, in a new
+	// working tree at the given path.
+	AddWorktree(path, commit string) error
+
+	// RemoveWorktree removes the working tree at the given path, even if it
+	// has been modified.
+	RemoveWorktree(path string) error
+
 	// ArchiveRef adds the current commit pointed to by the 'ref' argument
 	// under the ref specified in the 'archive' argument.
 	//
@@ -316,6 +347,34 @@ type Repo interface {
 	// the result.
 	RebaseAndSignRef(ref string) error
 
+	// RebaseRefOnto rebases the commits of the current ref that are not
+	// reachable from 'upstream' onto the 'onto' commit, without prompting
+	// the user.
+	RebaseRefOnto(onto, upstream string) error
+
+	// CreateBranch creates a new branch starting at the given commit, and
+	// switches to it.
+	CreateBranch(branch, startPoint string) error
+
+	// RevertCommits creates a commit reverting each of the given commits,
+	// in the given order, on top of the current ref.
+	RevertCommits(commits ...string) error
+
+	// RebaseRefNonInteractive rebases the current ref onto the given one
+	// without prompting the user, optionally signing the result.
+	RebaseRefNonInteractive(ref string, sign bool) error
+
+	// IsRebaseInProgress returns whether or not a rebase has been started
+	// but has not yet been completed or aborted.
+	IsRebaseInProgress() (bool, error)
+
+	// ContinueRebase continues a rebase that stopped, e.g. due to conflicts,
+	// without prompting the user to edit any commit messages.
+	ContinueRebase() error
+
+	// AbortRebase aborts a rebase that stopped, restoring the original ref.
+	AbortRebase() error
+
 	// ListCommits returns the list of commits reachable from the given ref.
 	//
 	// The generated list is in chronological order (with the oldest commit first).
@@ -339,6 +398,17 @@ type Repo interface {
 	// The generated list is in chronological order (with the oldest commit first).
 	ListCommitsBetween(from, to string) ([]string, error)
 
+	// FindMergeCommit returns the merge commit in the history of the given
+	// ref that merged in the given commit, i.e. that has the commit in the
+	// history of one of its other parents but not its first parent, or the
+	// empty string if there is no such merge commit.
+	FindMergeCommit(commit, ref string) (string, error)
+
+	// GetCommitSignature returns the status of the signature on the given
+	// commit, as one of the letters of git's "%G?" format (e.g. "G" for a
+	// good signature, or "N" for no signature), and the key that made it.
+	GetCommitSignature(commit string) (status, key string, err error)
+
 	// StoreBlob writes the given file contents to the repository and returns its hash.
 	StoreBlob(contents string) (string, error)
 
@@ -354,6 +424,11 @@ type Repo interface {
 	// CreateCommitWithTree creates a commit object with the given tree and returns its hash.
 	CreateCommitWithTree(details *CommitDetails, t *Tree) (string, error)
 
+	// RewriteCommit creates a copy of the given commit, with the same tree
+	// and author, but with the given parents and message, and returns the
+	// hash of the copy. The copy is optionally signed.
+	RewriteCommit(commit string, parents []string, message string, sign bool) (string, error)
+
 	// SetRef sets the commit pointed to by the specified ref to `newCommitHash`,
 	// iff the ref currently points `previousCommitHash`.
 	SetRef(ref, newCommitHash, previousCommitHash string) error
@@ -371,6 +446,10 @@ type Repo interface {
 	// AppendNote appends a note to a revision under the given ref.
 	AppendNote(ref, revision string, note Note) error
 
+	// SetNotes replaces the notes of a revision under the given ref, removing
+	// them if there are none.
+	SetNotes(ref, revision string, notes []Note) error
+
 	// ListNotedRevisions returns the collection of revisions that are annotated by notes in the given ref.
 	ListNotedRevisions(notesRef string) []string
 
@@ -424,4 +503,16 @@ type Repo interface {
 
 	// Push pushes the given refs to a remote repo.
 	Push(remote string, refPattern ...string) error
+
+	// PushAtomic pushes the given refs to a remote repo, such that either
+	// all of them are updated or none are.
+	//
+	// The leases argument maps from remote refs to the values they are
+	// expected to have; if any of them has a different value, then the push
+	// is rejected. The refs with leases are allowed to be forced.
+	PushAtomic(remote string, leases map[string]string, refSpecs ...string) error
+
+	// ResetCurrentRef points the current ref, and the working tree, at the
+	// given commit, while keeping any local changes.
+	ResetCurrentRef(commit string) error
 }
@@ -21,7 +21,6 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
-	"sort"
 	"strconv"
 
 	"github.com/KoviRobi/git-appraise/repository"
@@ -128,22 +127,20 @@ func Parse(note repository.Note) (Report, error) {
 
 // GetLatestAnalysesReport takes a collection of analysis reports, and returns the one with the most recent timestamp.
 func GetLatestAnalysesReport(reports []Report) (*Report, error) {
-	timestampReportMap := make(map[int]*Report)
-	var timestamps []int
-
-	for _, report := range reports {
-		timestamp, err := strconv.Atoi(report.Timestamp)
+	var latest *Report
+	var latestTimestamp int
+	for i := range reports {
+		timestamp, err := strconv.Atoi(reports[i].Timestamp)
 		if err != nil {
 			return nil, err
 		}
-		timestamps = append(timestamps, timestamp)
-		timestampReportMap[timestamp] = &report
-	}
-	if len(timestamps) == 0 {
-		return nil, nil
+		// Reports with the same timestamp are ordered by their position.
+		if latest == nil || timestamp >= latestTimestamp {
+			latest = &reports[i]
+			latestTimestamp = timestamp
+		}
 	}
-	sort.Sort(sort.Reverse(sort.IntSlice(timestamps)))
-	return timestampReportMap[timestamps[0]], nil
+	return latest, nil
 }
 
 // ParseAllValid takes collection of git notes and tries to parse a analyses report
@@ -0,0 +1,214 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package check runs the local checks configured for a repository, so that
+// their results can be recorded as CI reports.
+//
+// The checks are described by the ".appraise/checks" file, which uses git
+// config syntax, with one section per check, keyed by its name:
+//
+//	[check "test"]
+//		command = go test ./...
+//		timeout = 5m
+//
+// The "command" entry is run using "sh -c" at the top of a checkout of the
+// revision being checked, and the optional "timeout" entry is a Go duration
+// (e.g. "90s" or "1h") after which the command is killed.
+package check
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/review/ci"
+	exec "golang.org/x/sys/execabs"
+)
+
+const (
+	// ConfigPath is the path of the checks file within the repository.
+	ConfigPath = ".appraise/checks"
+
+	// DefaultTimeout is the timeout of checks that do not specify one.
+	DefaultTimeout = 10 * time.Minute
+
+	// localAgentPrefix is the prefix of the CI agents that report the results
+	// of checks run locally, which are named "local/<user>/<check>".
+	localAgentPrefix = "local/"
+
+	// killDelay is how long to wait for the output of a check to be closed
+	// after it has been killed, as any processes it started may still hold
+	// it open.
+	killDelay = 5 * time.Second
+)
+
+var (
+	sectionRegexp = regexp.MustCompile(`^\[\s*([A-Za-z0-9.-]+)(?:\s+"((?:[^"\\]|\\.)*)")?\s*\]$`)
+	nameRegexp    = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// Check is a single named command that checks a revision.
+type Check struct {
+	Name    string
+	Command string
+	// Timeout is how long the command may run for, or zero for the default.
+	Timeout time.Duration
+}
+
+// Agent returns the name of the CI agent that reports the results of the
+// check when it is run locally by the given user.
+//
+// The name is namespaced by the user so that the reports of a local check
+// cannot be mistaken for those of a CI server, or of another user.
+func (c Check) Agent(user string) string {
+	return localAgentPrefix + user + "/" + c.Name
+}
+
+// Result is the outcome of running a check.
+type Result struct {
+	Check Check
+	// Status is the CI status of the check: a success, a failure, or an
+	// error if the command could not be run at all.
+	Status string
+	// Log is the combined output of the command, along with a description
+	// of how it finished.
+	Log      string
+	Duration time.Duration
+}
+
+// Parse parses the contents of a checks file.
+//
+// The checks are returned in the order in which they are first described.
+func Parse(contents string) ([]Check, error) {
+	var checks []*Check
+	byName := make(map[string]*Check)
+	var c *Check
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			match := sectionRegexp.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("line %d: malformed section header %q", i+1, line)
+			}
+			c = nil
+			if strings.ToLower(match[1]) != "check" {
+				continue
+			}
+			name := match[2]
+			if !nameRegexp.MatchString(name) {
+				return nil, fmt.Errorf("line %d: invalid check name %q", i+1, name)
+			}
+			if c = byName[name]; c == nil {
+				c = &Check{Name: name}
+				byName[name] = c
+				checks = append(checks, c)
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a \"name = value\" entry, found %q", i+1, line)
+		}
+		if c == nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "command":
+			c.Command = value
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("line %d: invalid timeout %q for the check %q", i+1, value, c.Name)
+			}
+			c.Timeout = timeout
+		}
+	}
+	var result []Check
+	for _, c := range checks {
+		if c.Command == "" {
+			return nil, fmt.Errorf("the check %q does not have a command", c.Name)
+		}
+		result = append(result, *c)
+	}
+	return result, nil
+}
+
+// Run runs the given check in the given directory.
+//
+// The defaultTimeout is used if the check does not specify a timeout.
+func Run(c Check, dir string, defaultTimeout time.Duration) Result {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var log bytes.Buffer
+	fmt.Fprintf(&log, "$ %s\n", c.Command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Dir = dir
+	cmd.Stdout = &log
+	cmd.Stderr = &log
+	killProcessGroup(cmd)
+	cmd.WaitDelay = killDelay
+	start := time.Now()
+	err := cmd.Run()
+	result := Result{
+		Check:    c,
+		Status:   ci.StatusSuccess,
+		Duration: time.Since(start),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Status = ci.StatusFailure
+		fmt.Fprintf(&log, "\ntimed out after %s\n", timeout)
+	} else if _, ok := err.(*exec.ExitError); ok {
+		result.Status = ci.StatusFailure
+		fmt.Fprintf(&log, "\n%s\n", err)
+	} else if err != nil {
+		result.Status = ci.StatusError
+		fmt.Fprintf(&log, "\nfailed to run the check: %s\n", err)
+	}
+	result.Log = log.String()
+	return result
+}
+
+// RunAll runs the given checks in parallel in the given directory, and
+// returns their results in the same order as the checks.
+func RunAll(checks []Check, dir string, defaultTimeout time.Duration) []Result {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			results[i] = Run(c, dir, defaultTimeout)
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
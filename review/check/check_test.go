@@ -0,0 +1,92 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package check
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KoviRobi/git-appraise/review/ci"
+)
+
+const testChecks = `# Checks run by "git appraise check".
+[check "build"]
+	command = make
+[person "jane@example.com"]
+	name = Jane Doe
+[check "test"]
+	command = "make test"
+	timeout = 90s
+`
+
+func TestParse(t *testing.T) {
+	checks, err := Parse(testChecks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Check{
+		{Name: "build", Command: "make"},
+		{Name: "test", Command: "make test", Timeout: 90 * time.Second},
+	}
+	if len(checks) != len(expected) || checks[0] != expected[0] || checks[1] != expected[1] {
+		t.Errorf("Unexpected checks: %+v", checks)
+	}
+	for _, invalid := range []string{
+		"[check \"build\"]\n\ttimeout = 1m\n",
+		"[check \"build\"]\n\tcommand = make\n\ttimeout = soon\n",
+		"[check \"../build\"]\n\tcommand = make\n",
+		"[check \"build\"\n\tcommand = make\n",
+	} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Invalid checks %q were not rejected", invalid)
+		}
+	}
+}
+
+func TestAgent(t *testing.T) {
+	c := Check{Name: "build", Command: "make"}
+	if agent := c.Agent("jane@example.com"); agent != "local/jane@example.com/build" {
+		t.Fatalf("Unexpected agent for a local check: %q", agent)
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	checks := []Check{
+		{Name: "pass", Command: "echo passed"},
+		{Name: "fail", Command: "echo failed >&2; exit 1"},
+		{Name: "slow", Command: "sleep 10", Timeout: 100 * time.Millisecond},
+	}
+	start := time.Now()
+	results := RunAll(checks, t.TempDir(), time.Minute)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("The checks were not stopped when they timed out; they took %s", elapsed)
+	}
+	expected := []struct {
+		status string
+		log    string
+	}{
+		{ci.StatusSuccess, "passed"},
+		{ci.StatusFailure, "failed"},
+		{ci.StatusFailure, "timed out"},
+	}
+	for i, result := range results {
+		if result.Check != checks[i] || result.Status != expected[i].status || !strings.Contains(result.Log, expected[i].log) {
+			t.Errorf("Unexpected result for the check %q: %+v", checks[i].Name, result)
+		}
+	}
+}
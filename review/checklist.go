@@ -0,0 +1,38 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"github.com/KoviRobi/git-appraise/review/checklist"
+)
+
+// GetChecklist returns the checklist in the description of the review,
+// with the items that have since been checked or unchecked updated.
+func (r *Summary) GetChecklist() []checklist.Item {
+	marks := checklist.ParseAllValid(r.Repo.GetNotes(checklist.Ref, r.Revision))
+	return checklist.Apply(checklist.Parse(r.Request.Description), marks)
+}
+
+// MarkChecklistItem records that an item of the checklist of the review has
+// been checked or unchecked.
+func (r *Summary) MarkChecklistItem(mark checklist.Mark) error {
+	note, err := mark.Write()
+	if err != nil {
+		return err
+	}
+	return r.Repo.AppendNote(checklist.Ref, r.Revision, note)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package checklist defines the internal representation of the checklist
+// of a review, which is made up of the markdown task list items (e.g.
+// "- [ ] Update the docs") in the description of the review.
+package checklist
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+)
+
+// Ref defines the git-notes ref that we expect to contain checklist marks.
+const Ref = "refs/notes/devtools/checklist"
+
+// FormatVersion defines the latest version of the checklist format supported by the tool.
+const FormatVersion = 0
+
+// TemplatePath is the path of the file, on the target branch of a review,
+// that is used to pre-populate the description of new reviews.
+const TemplatePath = ".appraise/request-template.md"
+
+// taskPattern matches a markdown task list item, capturing its check mark and text.
+var taskPattern = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*?)\s*$`)
+
+// Item represents a single item of the checklist.
+type Item struct {
+	// Number is the position of the item in the checklist, starting from 1.
+	Number  int    `json:"number"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+	// CheckedBy and Timestamp describe the latest mark for the item, if any.
+	CheckedBy string `json:"checkedBy,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// Mark records that an item of the checklist has been checked or unchecked.
+//
+// Items are identified by their text, so that a mark still applies if the
+// description is edited to add or remove other items. The number of the item
+// is used to choose between items with the same text, and if its text is not
+// found.
+type Mark struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Item      int    `json:"item"`
+	Text      string `json:"text,omitempty"`
+	Checked   bool   `json:"checked"`
+	// Version represents the version of the metadata format.
+	Version int `json:"v,omitempty"`
+
+	gpg.Sig
+}
+
+// Parse returns the checklist in the given description.
+//
+// Task list items within fenced code blocks are ignored.
+func Parse(description string) []Item {
+	var items []Item
+	inCodeBlock := false
+	for _, line := range strings.Split(description, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+		if match := taskPattern.FindStringSubmatch(line); match != nil {
+			items = append(items, Item{
+				Number:  len(items) + 1,
+				Text:    match[2],
+				Checked: match[1] != " ",
+			})
+		}
+	}
+	return items
+}
+
+// New returns a new mark for the given item.
+func New(author string, item Item, checked bool) Mark {
+	return Mark{
+		Author:  author,
+		Item:    item.Number,
+		Text:    item.Text,
+		Checked: checked,
+	}
+}
+
+// Apply updates the given checklist items with the given marks, in
+// chronological order, and returns the result.
+func Apply(items []Item, marks []Mark) []Item {
+	sort.SliceStable(marks, func(i, j int) bool {
+		return marks[i].Timestamp < marks[j].Timestamp
+	})
+	result := append([]Item(nil), items...)
+	for _, mark := range marks {
+		index := -1
+		for i, item := range result {
+			if item.Text != mark.Text {
+				continue
+			}
+			if index < 0 || item.Number == mark.Item {
+				index = i
+			}
+		}
+		if index < 0 && mark.Text == "" && mark.Item > 0 && mark.Item <= len(result) {
+			index = mark.Item - 1
+		}
+		if index < 0 {
+			continue
+		}
+		result[index].Checked = mark.Checked
+		result[index].CheckedBy = mark.Author
+		result[index].Timestamp = mark.Timestamp
+	}
+	return result
+}
+
+// Remaining returns the items of the checklist that have not been checked.
+func Remaining(items []Item) []Item {
+	var remaining []Item
+	for _, item := range items {
+		if !item.Checked {
+			remaining = append(remaining, item)
+		}
+	}
+	return remaining
+}
+
+// ParseMark parses a checklist mark from a git note.
+func ParseMark(note repository.Note) (Mark, error) {
+	bytes := []byte(note)
+	var mark Mark
+	err := json.Unmarshal(bytes, &mark)
+	return mark, err
+}
+
+// ParseAllValid takes collection of git notes and tries to parse a checklist
+// mark from each one. Any notes that are not valid marks get ignored.
+func ParseAllValid(notes []repository.Note) []Mark {
+	var marks []Mark
+	for _, note := range notes {
+		mark, err := ParseMark(note)
+		if err == nil && mark.Version == FormatVersion && (mark.Item > 0 || mark.Text != "") {
+			marks = append(marks, mark)
+		}
+	}
+	return marks
+}
+
+// Write writes a checklist mark as a JSON-formatted git note.
+func (mark *Mark) Write() (repository.Note, error) {
+	bytes, err := json.Marshal(mark)
+	return repository.Note(bytes), err
+}
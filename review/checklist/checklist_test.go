@@ -0,0 +1,78 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checklist
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testDescription = "Add a feature\n\n" +
+	"- [ ] Tests added\n" +
+	"* [x] Docs updated\n" +
+	"  + [ ] Changelog\n" +
+	"- [] Not an item\n" +
+	"```\n- [ ] Not an item either\n```\n"
+
+func TestParse(t *testing.T) {
+	expected := []Item{
+		{Number: 1, Text: "Tests added"},
+		{Number: 2, Text: "Docs updated", Checked: true},
+		{Number: 3, Text: "Changelog"},
+	}
+	if items := Parse(testDescription); !reflect.DeepEqual(items, expected) {
+		t.Errorf("Unexpected checklist: %+v", items)
+	}
+}
+
+func TestApply(t *testing.T) {
+	items := Parse(testDescription)
+	marks := []Mark{
+		{Timestamp: "0000000002", Author: "bob", Item: 1, Text: "Changelog", Checked: true},
+		{Timestamp: "0000000001", Author: "alice", Item: 3, Text: "Changelog", Checked: false},
+		{Timestamp: "0000000003", Author: "alice", Item: 2, Checked: false},
+		{Timestamp: "0000000004", Author: "alice", Item: 1, Text: "Removed item", Checked: true},
+	}
+	applied := Apply(items, marks)
+	if !applied[2].Checked || applied[2].CheckedBy != "bob" {
+		t.Errorf("The latest mark for an item was not applied: %+v", applied[2])
+	}
+	if applied[1].Checked {
+		t.Errorf("A mark without text was not applied by number: %+v", applied[1])
+	}
+	if applied[0].Checked {
+		t.Errorf("A mark for a removed item was applied: %+v", applied[0])
+	}
+	if remaining := Remaining(applied); len(remaining) != 2 {
+		t.Errorf("Unexpected remaining items: %+v", remaining)
+	}
+	if items[2].Checked {
+		t.Error("Applying the marks modified the original items")
+	}
+}
+
+func TestApplyDuplicateText(t *testing.T) {
+	items := Parse("- [ ] Tests added\n- [ ] Docs updated\n- [ ] Tests added\n")
+	applied := Apply(items, []Mark{New("alice", items[2], true)})
+	if applied[0].Checked || !applied[2].Checked {
+		t.Fatalf("A mark for the second of two items with the same text was not applied to it: %+v", applied)
+	}
+	applied = Apply(items, []Mark{New("alice", items[0], true), New("alice", items[2], true)})
+	if remaining := Remaining(applied); len(remaining) != 1 || remaining[0].Number != 2 {
+		t.Fatalf("Unexpected remaining items after checking both items with the same text: %+v", remaining)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/check"
+)
+
+// checksArchiveRef is the ref that keeps the logs of local checks from being
+// garbage collected, and that shares them when the archives are pushed.
+const checksArchiveRef = "refs/devtools/archives/checks"
+
+// StoreCheckLogs stores the logs of the given results of checking the given
+// commit, and returns the hash of the blob holding each log, keyed by the
+// name of its check.
+//
+// The logs are kept in a commit, whose parent is the checked commit, that
+// is added to the checks archive ref.
+func StoreCheckLogs(repo repository.Repo, commit string, results []check.Result) (map[string]string, error) {
+	hashes := make(map[string]string)
+	contents := make(map[string]repository.TreeChild)
+	for _, result := range results {
+		blob := repository.NewBlob(result.Log)
+		hash, err := blob.Store(repo)
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			return nil, fmt.Errorf("Failure storing the log of the check %q", result.Check.Name)
+		}
+		hashes[result.Check.Name] = hash
+		contents[result.Check.Name+".log"] = blob
+	}
+	details := &repository.CommitDetails{
+		Summary: fmt.Sprintf("Check logs for %s", commit),
+		Parents: []string{commit},
+	}
+	logsCommit, err := repo.CreateCommitWithTree(details, repository.NewTree(contents))
+	if err != nil {
+		return nil, fmt.Errorf("Failure creating the commit for the check logs: %v", err)
+	}
+	if err := repo.ArchiveRef(logsCommit, checksArchiveRef); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
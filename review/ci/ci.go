@@ -19,7 +19,10 @@ package ci
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"regexp"
 	"sort"
 	"strconv"
 )
@@ -32,6 +35,16 @@ const (
 	StatusSuccess = "success"
 	// StatusFailure is the status string representing that a build and/or test failed.
 	StatusFailure = "failure"
+	// StatusPending is the status string representing that a build and/or test has not yet started.
+	StatusPending = "pending"
+	// StatusRunning is the status string representing that a build and/or test is in progress.
+	StatusRunning = "running"
+	// StatusCancelled is the status string representing that a build and/or test was stopped before it finished.
+	StatusCancelled = "cancelled"
+	// StatusSkipped is the status string representing that an agent decided not to build and/or test the revision.
+	StatusSkipped = "skipped"
+	// StatusError is the status string representing that a build and/or test could not be run, e.g. due to an infrastructure problem.
+	StatusError = "error"
 
 	// FormatVersion defines the latest version of the request format supported by the tool.
 	FormatVersion = 0
@@ -47,6 +60,77 @@ type Report struct {
 	Agent     string `json:"agent,omitempty"`
 	// Version represents the version of the metadata format.
 	Version int `json:"v,omitempty"`
+
+	gpg.Sig
+}
+
+// timestampPattern matches the timestamps allowed by schema/ci.json.
+var timestampPattern = regexp.MustCompile(`^[0-9]{10}$`)
+
+// New returns a new CI report from the given agent with the given status.
+func New(agent, status, url string) Report {
+	return Report{
+		Agent:   agent,
+		Status:  status,
+		URL:     url,
+		Version: FormatVersion,
+	}
+}
+
+// Statuses lists every status that may be reported, in the order of
+// precedence used when aggregating the statuses of multiple agents.
+var Statuses = []string{
+	StatusFailure,
+	StatusError,
+	StatusRunning,
+	StatusPending,
+	StatusCancelled,
+	StatusSuccess,
+	StatusSkipped,
+}
+
+// IsValidStatus returns whether or not the given status may be reported.
+func IsValidStatus(status string) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRank returns the position of the given status in Statuses, or the
+// number of statuses if it is not one of them (e.g. if it is empty).
+func statusRank(status string) int {
+	for i, s := range Statuses {
+		if s == status {
+			return i
+		}
+	}
+	return len(Statuses)
+}
+
+// Validate checks that the report conforms to the schema in schema/ci.json.
+func (report *Report) Validate() error {
+	if !timestampPattern.MatchString(report.Timestamp) {
+		return fmt.Errorf("invalid timestamp %q; it must be the number of seconds since the Unix epoch, as 10 digits", report.Timestamp)
+	}
+	if report.Agent == "" {
+		return fmt.Errorf("the agent is required")
+	}
+	if report.Status != "" && !IsValidStatus(report.Status) {
+		return fmt.Errorf("invalid status %q; it must be one of %q", report.Status, Statuses)
+	}
+	if report.Version != FormatVersion {
+		return fmt.Errorf("unsupported version %d", report.Version)
+	}
+	return nil
+}
+
+// Write writes a CI report as a JSON-formatted git note.
+func (report *Report) Write() (repository.Note, error) {
+	bytes, err := json.Marshal(report)
+	return repository.Note(bytes), err
 }
 
 // Parse parses a CI report from a git note.
@@ -58,23 +142,68 @@ func Parse(note repository.Note) (Report, error) {
 }
 
 // GetLatestCIReport takes the collection of reports and returns the one with the most recent timestamp.
+//
+// Of the reports with the same timestamp, the one whose status comes first in
+// Statuses is returned, as the order of the reports is not meaningful once
+// the notes holding them have been merged.
 func GetLatestCIReport(reports []Report) (*Report, error) {
-	timestampReportMap := make(map[int]*Report)
-	var timestamps []int
+	var latest *Report
+	var latestTimestamp int
+	for i := range reports {
+		timestamp, err := strconv.Atoi(reports[i].Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || timestamp > latestTimestamp ||
+			(timestamp == latestTimestamp && statusRank(reports[i].Status) < statusRank(latest.Status)) {
+			latest = &reports[i]
+			latestTimestamp = timestamp
+		}
+	}
+	return latest, nil
+}
 
+// GetLatestReportPerAgent takes the collection of reports and returns the
+// one with the most recent timestamp from each agent, sorted by agent.
+//
+// This forms the matrix of the current CI status of a revision.
+func GetLatestReportPerAgent(reports []Report) ([]Report, error) {
+	reportsByAgent := make(map[string][]Report)
+	var agents []string
 	for _, report := range reports {
-		timestamp, err := strconv.Atoi(report.Timestamp)
+		if _, ok := reportsByAgent[report.Agent]; !ok {
+			agents = append(agents, report.Agent)
+		}
+		reportsByAgent[report.Agent] = append(reportsByAgent[report.Agent], report)
+	}
+	sort.Strings(agents)
+	var matrix []Report
+	for _, agent := range agents {
+		latest, err := GetLatestCIReport(reportsByAgent[agent])
 		if err != nil {
 			return nil, err
 		}
-		timestamps = append(timestamps, timestamp)
-		timestampReportMap[timestamp] = &report
+		matrix = append(matrix, *latest)
 	}
-	if len(timestamps) == 0 {
-		return nil, nil
+	return matrix, nil
+}
+
+// AggregateStatus combines the statuses of the given reports, which should be
+// the latest from each agent, into a single status.
+//
+// The aggregate is the status that comes first in Statuses, so any failure
+// makes the aggregate a failure, and the aggregate is only a success if every
+// agent either succeeded or skipped the revision. Reports without a status
+// are ignored, and the aggregate of no statuses is the empty string.
+func AggregateStatus(reports []Report) string {
+	aggregate := ""
+	rank := len(Statuses)
+	for _, report := range reports {
+		if r := statusRank(report.Status); r < rank {
+			aggregate, rank = report.Status, r
+		}
 	}
-	sort.Sort(sort.Reverse(sort.IntSlice(timestamps)))
-	return timestampReportMap[timestamps[0]], nil
+	return aggregate
 }
 
 // ParseAllValid takes collection of git notes and tries to parse a CI report
@@ -86,7 +215,7 @@ func ParseAllValid(notes []repository.Note) []Report {
 	for _, note := range notes {
 		report, err := Parse(note)
 		if err == nil && report.Version == FormatVersion {
-			if report.Status == "" || report.Status == StatusSuccess || report.Status == StatusFailure {
+			if report.Status == "" || IsValidStatus(report.Status) {
 				reports = append(reports, report)
 			}
 		}
@@ -83,3 +83,72 @@ func TestCIReport(t *testing.T) {
 		t.Fatal("This is not the latest ", latestReport)
 	}
 }
+
+func TestLatestCIReportWithSameTimestamp(t *testing.T) {
+	pending := Report{Timestamp: "0000000010", Agent: "ci", Status: StatusPending}
+	failure := Report{Timestamp: "0000000010", Agent: "ci", Status: StatusFailure}
+	for _, reports := range [][]Report{
+		{pending, failure},
+		{failure, pending},
+	} {
+		latest, err := GetLatestCIReport(reports)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest.Status != StatusFailure {
+			t.Errorf("A %q report hid a failure reported at the same time: %+v", latest.Status, reports)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	report := New("ci@example.com", StatusPending, "https://ci.example.com/1")
+	report.Timestamp = "1234567890"
+	if err := report.Validate(); err != nil {
+		t.Errorf("Unexpected error validating %+v: %v", report, err)
+	}
+	invalid := []Report{
+		{Timestamp: "16", Agent: "ci@example.com", Status: StatusSuccess},
+		{Timestamp: "1234567890", Status: StatusSuccess},
+		{Timestamp: "1234567890", Agent: "ci@example.com", Status: "something else"},
+		{Timestamp: "1234567890", Agent: "ci@example.com", Version: FormatVersion + 1},
+	}
+	for _, report := range invalid {
+		if err := report.Validate(); err == nil {
+			t.Errorf("Invalid report %+v was not rejected", report)
+		}
+	}
+}
+
+func TestLatestReportPerAgent(t *testing.T) {
+	reports := []Report{
+		{Timestamp: "0000000003", Agent: "b", Status: StatusRunning},
+		{Timestamp: "0000000001", Agent: "a", Status: StatusFailure},
+		{Timestamp: "0000000004", Agent: "b", Status: StatusSuccess},
+		{Timestamp: "0000000002", Agent: "a", Status: StatusPending},
+		{Timestamp: "0000000005", Agent: "c", Status: StatusSkipped},
+	}
+	matrix, err := GetLatestReportPerAgent(reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matrix) != 3 || matrix[0] != reports[3] || matrix[1] != reports[2] || matrix[2] != reports[4] {
+		t.Fatalf("Unexpected matrix: %+v", matrix)
+	}
+	if aggregate := AggregateStatus(matrix); aggregate != StatusPending {
+		t.Errorf("Unexpected aggregate status %q", aggregate)
+	}
+	if aggregate := AggregateStatus(matrix[1:]); aggregate != StatusSuccess {
+		t.Errorf("Unexpected aggregate status %q", aggregate)
+	}
+	if aggregate := AggregateStatus(matrix[2:]); aggregate != StatusSkipped {
+		t.Errorf("Unexpected aggregate status %q", aggregate)
+	}
+	matrix[0].Status = StatusError
+	if aggregate := AggregateStatus(matrix); aggregate != StatusError {
+		t.Errorf("Unexpected aggregate status %q", aggregate)
+	}
+	if aggregate := AggregateStatus(nil); aggregate != "" {
+		t.Errorf("Unexpected aggregate status %q for no reports", aggregate)
+	}
+}
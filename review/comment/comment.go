@@ -35,6 +35,17 @@ const Ref = "refs/notes/devtools/discuss"
 // FormatVersion defines the latest version of the comment format supported by the tool.
 const FormatVersion = 0
 
+// SideOld and SideNew identify which side of a diff a location refers to.
+//
+// The old side is the base of the review (the left hand side of the diff),
+// and is used for commenting on deleted lines or on files prior to a rename.
+// The new side is the head of the review, and is the default if no side is
+// specified.
+const (
+	SideOld = "old"
+	SideNew = "new"
+)
+
 // ErrInvalidRange inidcates an error during parsing of a user-defined file
 // range
 var ErrInvalidRange = errors.New("invalid file location range. The required form is StartLine[+StartColumn][:EndLine[+EndColumn]]. The first line in a file is considered to be line 1")
@@ -54,11 +65,26 @@ type Location struct {
 	Path string `json:"path,omitempty"`
 	// If the range is omitted, then the location represents an entire file.
 	Range *Range `json:"range,omitempty"`
+	// If the side is omitted, then the location is on the new side of the diff.
+	Side string `json:"side,omitempty"`
+}
+
+// IsOldSide returns whether or not the location refers to the old (base) side of a diff.
+func (location *Location) IsOldSide() bool {
+	return location.Side == SideOld
 }
 
 // Check verifies that this location is valid in the provided
 // repository.
 func (location *Location) Check(repo repository.Repo) error {
+	if location.Side != "" && location.Side != SideOld && location.Side != SideNew {
+		return fmt.Errorf("Unknown diff side %q; expected %q or %q", location.Side, SideOld, SideNew)
+	}
+	if location.Path != "" && location.Range != nil && *location.Range != (Range{}) {
+		if pathType, err := repo.GetPathType(location.Commit, location.Path); err == nil && pathType == "tree" {
+			return fmt.Errorf("%q is a directory, so no line range can be given for it", location.Path)
+		}
+	}
 	contents, err := repo.Show(location.Commit, location.Path)
 	if err != nil {
 		return err
@@ -116,6 +142,10 @@ type Comment struct {
 	// has been addressed. Otherwise, the parent is the commit, and this means that the
 	// change has been accepted. If the resolved bit is unset, then the comment is only an FYI.
 	Resolved *bool `json:"resolved,omitempty"`
+	// The retracted bit marks an edit that withdraws the original comment.
+	// Retracted comments are kept, so that their replies still make sense,
+	// but are hidden by default.
+	Retracted bool `json:"retracted,omitempty"`
 	// Version represents the version of the metadata format.
 	Version int `json:"v,omitempty"`
 
@@ -0,0 +1,135 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/comment"
+)
+
+// CleanDetachedPath returns the canonical form of a path for detached
+// comments, so that e.g. "./src/" and "src" refer to the same directory.
+func CleanDetachedPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// isUnderDirectory returns whether or not the given path is the given
+// directory, or is within it. Every path is within the empty directory.
+func isUnderDirectory(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// GetDetachedCommentsAt returns the detached comments on the given file or
+// directory, including those made on the file under the names it had
+// before being renamed in the history of the given commit.
+func GetDetachedCommentsAt(repo repository.Repo, commit, p string) ([]CommentThread, error) {
+	paths := []string{CleanDetachedPath(p)}
+	// Comments may have been made using the path as it was given.
+	if p != paths[0] {
+		paths = append(paths, p)
+	}
+	p = paths[0]
+	// Only the history of files is followed, as "git log --follow" would
+	// otherwise list the files within a directory.
+	if pathType, err := repo.GetPathType(commit, p); err == nil && pathType == "blob" {
+		history, err := repo.GetPathHistory(commit, p)
+		if err != nil {
+			return nil, fmt.Errorf("Failure following the history of %q: %v", p, err)
+		}
+		for _, previous := range history {
+			if previous != p {
+				paths = append(paths, previous)
+			}
+		}
+	}
+	var threads []CommentThread
+	for _, previous := range paths {
+		wellKnownCommit, err := wellKnownCommitForPath(repo, previous, false)
+		if err != nil {
+			return nil, fmt.Errorf("Failure finding the well-known commit for detached comments on %q: %v", previous, err)
+		}
+		comments, err := GetComments(repo, wellKnownCommit)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, comments...)
+	}
+	sort.Stable(byTimestamp(threads))
+	return threads, nil
+}
+
+// currentPath returns the name that the given path was last renamed to,
+// according to the given renames, or the path itself if it was not renamed.
+func currentPath(p string, renames map[string]string) string {
+	seen := map[string]bool{p: true}
+	for {
+		renamed, ok := renames[p]
+		if !ok || seen[renamed] {
+			return p
+		}
+		seen[renamed] = true
+		p = renamed
+	}
+}
+
+// ListDetachedComments returns all of the detached comments on the given
+// directory and on the files and directories within it, keyed by the path
+// that they were made on.
+//
+// Comments made on files that were later renamed into the directory, in the
+// history of HEAD, are included as well.
+func ListDetachedComments(repo repository.Repo, dir string) (map[string][]CommentThread, error) {
+	dir = CleanDetachedPath(dir)
+	notesMap, err := repo.GetAllNotes(comment.Ref)
+	if err != nil {
+		return nil, err
+	}
+	renames, err := repo.ListRenames("HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("Failure listing the renamed files: %v", err)
+	}
+	wellKnownCommits := make(map[string]string)
+	result := make(map[string][]CommentThread)
+	for revision, notes := range notesMap {
+		threads, _ := getCommentsFromNotes(repo, revision, notes)
+		if len(threads) == 0 || threads[0].Comment.Location == nil {
+			continue
+		}
+		p := threads[0].Comment.Location.Path
+		if p == "" || !(isUnderDirectory(p, dir) || isUnderDirectory(currentPath(p, renames), dir)) {
+			continue
+		}
+		// Comments on reviews also have paths, so only the comments on the
+		// well-known commit for their path are detached ones.
+		if _, ok := wellKnownCommits[p]; !ok {
+			wellKnownCommit, err := wellKnownCommitForPath(repo, p, false)
+			if err != nil {
+				return nil, fmt.Errorf("Failure finding the well-known commit for detached comments on %q: %v", p, err)
+			}
+			wellKnownCommits[p] = wellKnownCommit
+		}
+		if wellKnownCommits[p] == revision {
+			result[p] = threads
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package discussion defines the internal representation of a repository-wide
+// discussion topic, such as a design discussion or an RFC.
+package discussion
+
+import (
+	"encoding/json"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+)
+
+// Ref defines the git-notes ref that we expect to contain discussion topics.
+const Ref = "refs/notes/devtools/discussions"
+
+// FormatVersion defines the latest version of the discussion format supported by the tool.
+const FormatVersion = 0
+
+// The states that a discussion can be in.
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// Discussion represents the title and state of a discussion topic.
+//
+// A topic is updated (e.g. closed) by writing a new version of it, and the
+// version with the latest timestamp is treated as the current one.
+type Discussion struct {
+	Timestamp   string   `json:"timestamp,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	State       string   `json:"state,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	// Version represents the version of the metadata format.
+	Version int `json:"v,omitempty"`
+
+	gpg.Sig
+}
+
+// New returns a new, open, discussion topic.
+func New(author, title, description string, labels []string) Discussion {
+	return Discussion{
+		Author:      author,
+		Title:       title,
+		Description: description,
+		State:       StateOpen,
+		Labels:      labels,
+	}
+}
+
+// IsOpen returns whether or not the discussion is still open.
+//
+// Topics without a state are treated as open.
+func (d *Discussion) IsOpen() bool {
+	return d.State != StateClosed
+}
+
+// HasLabel returns whether or not the discussion has the given label.
+func (d *Discussion) HasLabel(label string) bool {
+	for _, l := range d.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a discussion topic from a git note.
+func Parse(note repository.Note) (Discussion, error) {
+	bytes := []byte(note)
+	var discussion Discussion
+	err := json.Unmarshal(bytes, &discussion)
+	return discussion, err
+}
+
+// ParseAllValid takes collection of git notes and tries to parse a discussion
+// topic from each one. Any notes that are not valid discussion topics get
+// ignored.
+func ParseAllValid(notes []repository.Note) []Discussion {
+	var discussions []Discussion
+	for _, note := range notes {
+		discussion, err := Parse(note)
+		if err == nil && discussion.Version == FormatVersion && discussion.Title != "" {
+			discussions = append(discussions, discussion)
+		}
+	}
+	return discussions
+}
+
+// Write writes a discussion topic as a JSON-formatted git note.
+func (discussion *Discussion) Write() (repository.Note, error) {
+	bytes, err := json.Marshal(discussion)
+	return repository.Note(bytes), err
+}
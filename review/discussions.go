@@ -0,0 +1,271 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/discussion"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+// discussionsArchiveRef is the ref that keeps the commits that discussion
+// topics are attached to from being garbage collected.
+const discussionsArchiveRef = "refs/devtools/archives/discussions"
+
+// Discussion represents a repository-wide discussion topic, along with the
+// threads of replies to it.
+//
+// Each topic is attached to a commit with an empty tree that is created just
+// for it, so its title and state are stored as notes in the discussion.Ref
+// ref, and its replies as comments in the comment.Ref ref, in the same way
+// as those of a review.
+type Discussion struct {
+	Repo     repository.Repo `json:"-"`
+	Revision string          `json:"revision"`
+	// Number is the numeric alias of the discussion; topics are numbered
+	// from 1 in the order in which they were started.
+	Number      int                     `json:"number,omitempty"`
+	Topic       discussion.Discussion   `json:"topic"`
+	AllVersions []discussion.Discussion `json:"-"`
+	Comments    []CommentThread         `json:"comments,omitempty"`
+	// People is the registry used to display the identities in the discussion.
+	People *identity.Registry `json:"-"`
+	// Signature is the result of checking the signature on the current
+	// version of the topic; it is only set once the signatures of the
+	// discussion have been checked.
+	Signature *gpg.Result `json:"signature,omitempty"`
+}
+
+type discussionsByTimestamp []discussion.Discussion
+
+// Interface methods for sorting versions of a discussion topic by timestamp
+func (ds discussionsByTimestamp) Len() int      { return len(ds) }
+func (ds discussionsByTimestamp) Swap(i, j int) { ds[i], ds[j] = ds[j], ds[i] }
+func (ds discussionsByTimestamp) Less(i, j int) bool {
+	return ds[i].Timestamp < ds[j].Timestamp
+}
+
+// getDiscussionFromNotes builds a discussion out of the notes attached to its commit.
+func getDiscussionFromNotes(repo repository.Repo, revision string, topicNotes, commentNotes []repository.Note) (*Discussion, error) {
+	versions := discussion.ParseAllValid(topicNotes)
+	if versions == nil {
+		return nil, fmt.Errorf("Could not find a discussion topic for %q", revision)
+	}
+	sort.Stable(discussionsByTimestamp(versions))
+	comments, _ := getCommentsFromNotes(repo, revision, commentNotes)
+	return &Discussion{
+		Repo:        repo,
+		Revision:    revision,
+		Topic:       versions[len(versions)-1],
+		AllVersions: versions,
+		Comments:    comments,
+	}, nil
+}
+
+// started returns the timestamp of the first version of the topic.
+func (d *Discussion) started() string {
+	return d.AllVersions[0].Timestamp
+}
+
+// IsOpen returns whether or not the discussion is still open.
+func (d *Discussion) IsOpen() bool {
+	return d.Topic.IsOpen()
+}
+
+// LastActivity returns the timestamp of the latest update or reply to the discussion.
+func (d *Discussion) LastActivity() string {
+	latest := d.Topic.Timestamp
+	var visit func(threads []CommentThread)
+	visit = func(threads []CommentThread) {
+		for _, thread := range threads {
+			if thread.Comment.Timestamp > latest {
+				latest = thread.Comment.Timestamp
+			}
+			visit(thread.Children)
+		}
+	}
+	visit(d.Comments)
+	return latest
+}
+
+// Replies returns the number of replies to the discussion, including replies to replies.
+func (d *Discussion) Replies() int {
+	var count func(threads []CommentThread) int
+	count = func(threads []CommentThread) int {
+		n := len(threads)
+		for _, thread := range threads {
+			n += count(thread.Children)
+		}
+		return n
+	}
+	return count(d.Comments)
+}
+
+// ListDiscussions returns all of the discussion topics in the repository,
+// with the most recently started ones first.
+func ListDiscussions(repo repository.Repo) ([]Discussion, error) {
+	topicNotesMap, err := repo.GetAllNotes(discussion.Ref)
+	if err != nil {
+		return nil, err
+	}
+	commentNotesMap, err := repo.GetAllNotes(comment.Ref)
+	if err != nil {
+		return nil, err
+	}
+	// A malformed people file should not prevent discussions from being
+	// listed, so the identities are just shown as they are in that case.
+	people, _ := identity.Load(repo)
+	var discussions []Discussion
+	for commit, notes := range topicNotesMap {
+		d, err := getDiscussionFromNotes(repo, commit, notes, commentNotesMap[commit])
+		if err != nil {
+			continue
+		}
+		d.People = people
+		discussions = append(discussions, *d)
+	}
+	sort.SliceStable(discussions, func(i, j int) bool {
+		if discussions[i].started() != discussions[j].started() {
+			return discussions[i].started() < discussions[j].started()
+		}
+		return discussions[i].Revision < discussions[j].Revision
+	})
+	for i := range discussions {
+		discussions[i].Number = i + 1
+	}
+	sort.SliceStable(discussions, func(i, j int) bool {
+		return discussions[i].Number > discussions[j].Number
+	})
+	return discussions, nil
+}
+
+// GetDiscussion returns the discussion identified by the given name, or nil
+// if there is none.
+//
+// The name is either the "#<n>" number of the discussion (with or without the
+// '#'), or an unambiguous prefix of the hash of its commit.
+func GetDiscussion(repo repository.Repo, name string) (*Discussion, error) {
+	discussions, err := ListDiscussions(repo)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Discussion
+	for i := range discussions {
+		d := &discussions[i]
+		if number, err := strconv.Atoi(strings.TrimPrefix(name, "#")); err == nil && d.Number == number {
+			matches = append(matches, d)
+		} else if len(name) >= minimumHashPrefixLength && isHash(name) && strings.HasPrefix(d.Revision, name) {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matches[0], nil
+	}
+	var candidates []string
+	for _, d := range matches {
+		candidates = append(candidates, fmt.Sprintf("#%d %.12s %s", d.Number, d.Revision, d.Topic.Title))
+	}
+	return nil, fmt.Errorf("The discussion name %q is ambiguous; it matches:\n  %s", name, strings.Join(candidates, "\n  "))
+}
+
+// StartDiscussion creates a new discussion topic, and returns the hash of the
+// commit that it is attached to.
+func StartDiscussion(repo repository.Repo, topic discussion.Discussion) (string, error) {
+	// The commit is unique to the topic, since it records who started the
+	// topic, when, and with what title and description.
+	message := "Discussion: " + topic.Title
+	if topic.Description != "" {
+		message += "\n\n" + topic.Description
+	}
+	details := &repository.CommitDetails{
+		Author:         topic.Author,
+		AuthorEmail:    topic.Author,
+		AuthorTime:     topic.Timestamp + " +0000",
+		Committer:      topic.Author,
+		CommitterEmail: topic.Author,
+		Time:           topic.Timestamp + " +0000",
+		Summary:        message,
+	}
+	revision, err := repo.CreateCommitWithTree(details, emptyTree)
+	if err != nil {
+		return "", fmt.Errorf("Failure creating the commit for the discussion %q: %v", topic.Title, err)
+	}
+	if err := repo.ArchiveRef(revision, discussionsArchiveRef); err != nil {
+		return "", err
+	}
+	note, err := topic.Write()
+	if err != nil {
+		return "", err
+	}
+	if err := repo.AppendNote(discussion.Ref, revision, note); err != nil {
+		return "", err
+	}
+	return revision, nil
+}
+
+// Update records a new version of the discussion topic, e.g. to close it.
+func (d *Discussion) Update(topic discussion.Discussion) error {
+	note, err := topic.Write()
+	if err != nil {
+		return err
+	}
+	return d.Repo.AppendNote(discussion.Ref, d.Revision, note)
+}
+
+// AddComment adds the given reply to the discussion.
+func (d *Discussion) AddComment(c comment.Comment) error {
+	note, err := c.Write()
+	if err != nil {
+		return err
+	}
+	return d.Repo.AppendNote(comment.Ref, d.Revision, note)
+}
+
+// CheckSignatures checks the signatures on the current version of the topic
+// and on every reply to it, and records the results in their Signature fields.
+//
+// The keys that are trusted to sign for each person are taken from the
+// people file on the trusted ref; if that cannot be read, no keys are trusted.
+func (d *Discussion) CheckSignatures() {
+	policy, err := identity.LoadTrusted(d.Repo)
+	if err != nil {
+		policy = identity.New()
+	}
+	v := gpg.LoadConfig(d.Repo)
+	d.Signature = gpg.Check(v, policy, d.Topic.Author, &d.Topic)
+	checkThreadSignatures(v, policy, d.Comments)
+}
+
+// GetJSON returns the pretty printed JSON for a discussion.
+func (d *Discussion) GetJSON() (string, error) {
+	jsonBytes, err := json.Marshal(*d)
+	if err != nil {
+		return "", err
+	}
+	return prettyPrintJSON(jsonBytes)
+}
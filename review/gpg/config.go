@@ -0,0 +1,94 @@
+package gpg
+
+import (
+	"github.com/KoviRobi/git-appraise/repository"
+)
+
+// The signature formats supported, as named by git's "gpg.format" setting.
+const (
+	FormatOpenPGP = "openpgp"
+	FormatSSH     = "ssh"
+)
+
+// The git config settings used to configure signing and verification.
+const (
+	formatConfig             = "gpg.format"
+	programConfig            = "gpg.program"
+	openPGPProgramConfig     = "gpg.openpgp.program"
+	sshProgramConfig         = "gpg.ssh.program"
+	allowedSignersFileConfig = "gpg.ssh.allowedSignersFile"
+)
+
+// Config holds the settings used to sign and verify requests and comments.
+//
+// These mirror the settings that git uses for signing commits, so that
+// whatever works for "git commit -S" also works for "git appraise ... -S".
+type Config struct {
+	// Format is either FormatOpenPGP (the default) or FormatSSH.
+	Format string
+	// Key is the signing key. For GPG this is a key ID, and for SSH it is
+	// either the path to a key file, or a public key (optionally prefixed
+	// with "key::") whose private key is held by ssh-agent.
+	Key string
+	// GPGProgram and SSHProgram override the "gpg" and "ssh-keygen" programs.
+	GPGProgram string
+	SSHProgram string
+	// AllowedSignersFile lists the SSH keys that are trusted for verifying
+	// SSH signatures, in the format described in ssh-keygen(1).
+	AllowedSignersFile string
+}
+
+func lastConfigValue(repo repository.Repo, name string) string {
+	values, err := repo.GetConfigValues(name)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// LoadConfig reads the settings used to verify signatures from the given repository.
+func LoadConfig(repo repository.Repo) *Config {
+	c := &Config{
+		Format:             lastConfigValue(repo, formatConfig),
+		GPGProgram:         lastConfigValue(repo, openPGPProgramConfig),
+		SSHProgram:         lastConfigValue(repo, sshProgramConfig),
+		AllowedSignersFile: lastConfigValue(repo, allowedSignersFileConfig),
+	}
+	if c.Format == "" {
+		c.Format = FormatOpenPGP
+	}
+	if c.GPGProgram == "" {
+		c.GPGProgram = lastConfigValue(repo, programConfig)
+	}
+	return c
+}
+
+// LoadSigningConfig reads the settings used to sign with the user's key from
+// the given repository.
+//
+// Unlike LoadConfig, this requires that a signing key be configured.
+func LoadSigningConfig(repo repository.Repo) (*Config, error) {
+	c := LoadConfig(repo)
+	key, err := repo.GetUserSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	c.Key = key
+	return c, nil
+}
+
+// gpgProgram returns the program used to sign or verify GPG signatures.
+func (c *Config) gpgProgram() string {
+	if c.GPGProgram != "" {
+		return c.GPGProgram
+	}
+	return "gpg"
+}
+
+// sshProgram returns the program used to sign or verify SSH signatures.
+func (c *Config) sshProgram() string {
+	if c.SSHProgram != "" {
+		return c.SSHProgram
+	}
+	return "ssh-keygen"
+}
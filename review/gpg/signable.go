@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	exec "golang.org/x/sys/execabs"
 )
@@ -41,6 +42,13 @@ func (s *Sig) Signature() *string {
 // Sign uses gpg to sign the contents of a request and deposit it into the
 // signature key of the request.
 func Sign(key string, s Signable) error {
+	c := Config{Format: FormatOpenPGP, Key: key}
+	return c.Sign(s)
+}
+
+// Sign signs the contents of a request, using the configured format and key,
+// and deposits the signature into the signature key of the request.
+func (c *Config) Sign(s Signable) error {
 	// First we retrieve the pointer and write `placeholder` as its value.
 	sigPtr := s.Signature()
 	*sigPtr = placeholder
@@ -50,7 +58,12 @@ func Sign(key string, s Signable) error {
 	if err != nil {
 		return err
 	}
-	sig, err := signContent(key, content)
+	var sig *bytes.Buffer
+	if c.Format == FormatSSH {
+		sig, err = c.sshSignContent(content)
+	} else {
+		sig, err = c.signContent(content)
+	}
 	if err != nil {
 		return err
 	}
@@ -60,10 +73,10 @@ func Sign(key string, s Signable) error {
 	return nil
 }
 
-func signContent(key string, content []byte) (*bytes.Buffer,
+func (c *Config) signContent(content []byte) (*bytes.Buffer,
 	error) {
 	var stdout, stderr bytes.Buffer
-	cmd := exec.Command("gpg", "-u", key, "--detach-sign", "--armor")
+	cmd := exec.Command(c.gpgProgram(), "-u", c.Key, "--detach-sign", "--armor")
 	cmd.Stdin = bytes.NewReader(content)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -74,6 +87,20 @@ func signContent(key string, content []byte) (*bytes.Buffer,
 // Verify verifies the signatures on the request and its comments with the
 // given key.
 func Verify(s Signable) error {
+	var c Config
+	_, _, err := c.Verify(s)
+	return err
+}
+
+// Verify verifies the signature on the given request or comment, and returns
+// the key that made it: the fingerprint of a GPG key, or an SSH public key.
+// For SSH keys, the principals that the allowed signers file lets the key
+// sign for are also returned.
+//
+// The format of the signature is detected from its contents, so signatures
+// made using either GPG or SSH keys can be verified regardless of the
+// configured signing format.
+func (c *Config) Verify(s Signable) (string, []string, error) {
 	// Retrieve the pointer.
 	sigPtr := s.Signature()
 	// Copy its contents.
@@ -85,46 +112,76 @@ func Verify(s Signable) error {
 
 	// 1. Marshal the content into JSON.
 	// 2. Write the signature and the content to temp files.
-	// 3. Use gpg to verify the signature.
+	// 3. Use gpg or ssh-keygen to verify the signature.
 	content, err := json.Marshal(s)
 	if err != nil {
-		return err
-	}
-	sigFile, err := ioutil.TempFile("", "sig")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(sigFile.Name())
-	_, err = sigFile.Write([]byte(sig))
-	if err != nil {
-		return err
+		return "", nil, err
 	}
-	err = sigFile.Close()
+	sigFile, err := writeTempFile("sig", []byte(sig))
 	if err != nil {
-		return err
+		return "", nil, err
 	}
+	defer os.Remove(sigFile)
 
-	contentFile, err := ioutil.TempFile("", "content")
-	if err != nil {
-		return err
+	if isSSHSignature(sig) {
+		key, err := sshSignatureKey(sig)
+		if err != nil {
+			return "", nil, err
+		}
+		principals, err := c.sshVerify(sigFile, content)
+		return key, principals, err
 	}
-	defer os.Remove(contentFile.Name())
-	_, err = contentFile.Write(content)
-	if err != nil {
-		return err
-	}
-	err = contentFile.Close()
+
+	contentFile, err := writeTempFile("content", content)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
+	defer os.Remove(contentFile)
 
 	var stdout, stderr bytes.Buffer
-	cmd := exec.Command("gpg", "--verify", sigFile.Name(), contentFile.Name())
+	cmd := exec.Command(c.gpgProgram(), "--status-fd=1", "--verify", sigFile, contentFile)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	err = cmd.Run()
 	if err != nil {
-		return fmt.Errorf("%s", stderr.String())
+		return "", nil, fmt.Errorf("%s", stderr.String())
 	}
-	return nil
+	return gpgSignatureKey(stdout.String()), nil, nil
+}
+
+// gpgSignatureKey returns the fingerprint of the key that made a signature,
+// as reported in the given gpg status output.
+//
+// This is the fingerprint of the primary key, even if a subkey was used.
+func gpgSignatureKey(status string) string {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "[GNUPG:]" || fields[1] != "VALIDSIG" {
+			continue
+		}
+		// The primary key fingerprint is only included by newer versions of gpg.
+		if len(fields) >= 12 {
+			return fields[11]
+		}
+		return fields[2]
+	}
+	return ""
+}
+
+// writeTempFile writes the given contents to a new temporary file, and returns its name.
+func writeTempFile(prefix string, contents []byte) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
 }
@@ -0,0 +1,153 @@
+package gpg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	exec "golang.org/x/sys/execabs"
+)
+
+// sshNamespace is the namespace that SSH signatures are made in, which keeps
+// them from being mistaken for signatures of other kinds of data.
+const sshNamespace = "git-appraise"
+
+const (
+	sshSignatureHeader = "-----BEGIN SSH SIGNATURE-----"
+	sshSignatureFooter = "-----END SSH SIGNATURE-----"
+	sshSignatureMagic  = "SSHSIG"
+)
+
+// isSSHSignature returns whether the given signature was made using an SSH key.
+func isSSHSignature(sig string) bool {
+	return strings.HasPrefix(strings.TrimSpace(sig), sshSignatureHeader)
+}
+
+// sshKeyFile returns the path to the file holding the configured SSH key, and
+// a function to clean up that file if it had to be created.
+//
+// Like git, the key can either be a path, or a literal public key, in which
+// case the private key must be held by ssh-agent.
+func (c *Config) sshKeyFile() (string, func(), error) {
+	key := strings.TrimPrefix(c.Key, "key::")
+	if key != c.Key || strings.HasPrefix(key, "ssh-") || strings.HasPrefix(key, "ecdsa-") || strings.HasPrefix(key, "sk-") {
+		name, err := writeTempFile("key", []byte(key+"\n"))
+		if err != nil {
+			return "", nil, err
+		}
+		return name, func() { os.Remove(name) }, nil
+	}
+	return expandHome(key), func() {}, nil
+}
+
+// expandHome expands a leading "~/" in the given path to the user's home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+func (c *Config) sshSignContent(content []byte) (*bytes.Buffer, error) {
+	keyFile, cleanup, err := c.sshKeyFile()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.sshProgram(), "-Y", "sign", "-n", sshNamespace, "-f", keyFile)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to sign with the SSH key %q: %s", c.Key, strings.TrimSpace(stderr.String()))
+	}
+	return &stdout, nil
+}
+
+// sshPrincipals returns the principals in the allowed signers file whose
+// keys match the key that made the given signature.
+func (c *Config) sshPrincipals(sigFile string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.sshProgram(), "-Y", "find-principals", "-f", expandHome(c.AllowedSignersFile), "-s", sigFile)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("the SSH key that made the signature is not in %q", c.AllowedSignersFile)
+	}
+	return strings.Fields(stdout.String()), nil
+}
+
+// sshVerify verifies the SSH signature in the given file against the allowed
+// signers file, and returns the principals that the key which made it is
+// allowed to sign for.
+func (c *Config) sshVerify(sigFile string, content []byte) ([]string, error) {
+	if c.AllowedSignersFile == "" {
+		return nil, errors.New("SSH signatures can only be verified once gpg.ssh.allowedSignersFile is configured")
+	}
+	principals, err := c.sshPrincipals(sigFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(principals) == 0 {
+		return nil, fmt.Errorf("the SSH key that made the signature is not in %q", c.AllowedSignersFile)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.sshProgram(), "-Y", "verify", "-f", expandHome(c.AllowedSignersFile),
+		"-I", principals[0], "-n", sshNamespace, "-s", sigFile)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+	return principals, nil
+}
+
+// sshSignatureKey returns the public key, in the authorized_keys format, that
+// made the given SSH signature.
+//
+// The signature is not verified; this merely reads the key recorded in it.
+func sshSignatureKey(sig string) (string, error) {
+	sig = strings.TrimSpace(sig)
+	sig = strings.TrimPrefix(sig, sshSignatureHeader)
+	sig = strings.TrimSuffix(sig, sshSignatureFooter)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(sig), ""))
+	if err != nil {
+		return "", fmt.Errorf("malformed SSH signature: %v", err)
+	}
+	// The signature starts with a magic preamble and a version, followed by the public key.
+	if !bytes.HasPrefix(blob, []byte(sshSignatureMagic)) || len(blob) < len(sshSignatureMagic)+4 {
+		return "", errors.New("malformed SSH signature")
+	}
+	publicKey, _, ok := readSSHString(blob[len(sshSignatureMagic)+4:])
+	if !ok {
+		return "", errors.New("malformed SSH signature")
+	}
+	keyType, _, ok := readSSHString(publicKey)
+	if !ok {
+		return "", errors.New("malformed SSH public key in signature")
+	}
+	return string(keyType) + " " + base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+// readSSHString reads a length-prefixed string in the SSH wire format, and
+// returns it along with the rest of the data.
+func readSSHString(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 4 {
+		return nil, nil, false
+	}
+	length := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if uint32(len(data)) < length {
+		return nil, nil, false
+	}
+	return data[:length], data[length:], true
+}
@@ -0,0 +1,96 @@
+package gpg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	exec "golang.org/x/sys/execabs"
+)
+
+// policyForTest trusts the keys in the map to sign for the corresponding identities.
+type policyForTest map[string]string
+
+func (p policyForTest) Owner(key string) string          { return p[key] }
+func (p policyForTest) Canonical(identity string) string { return identity }
+
+type signableForTest struct {
+	Sig
+	Content string `json:"content"`
+}
+
+func TestSSHSignAndVerify(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "", "-f", keyFile).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to generate an SSH key: %v: %s", err, out)
+	}
+	pub, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedSigners := filepath.Join(dir, "allowed_signers")
+	if err := os.WriteFile(allowedSigners, append([]byte("user@example.com "), pub...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{Format: FormatSSH, Key: keyFile, AllowedSignersFile: allowedSigners}
+	s := &signableForTest{Content: "Looks good to me"}
+	if err := c.Sign(s); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if result := Check(c, policyForTest{}, "user@example.com", &signableForTest{}); result.Status != StatusUnsigned {
+		t.Errorf("Unexpected result for an unsigned comment: %v", result)
+	}
+	if !isSSHSignature(s.Sig.Sig) {
+		t.Fatalf("Expected an SSH signature, got %q", s.Sig.Sig)
+	}
+	key, principals, err := c.Verify(s)
+	if err != nil {
+		t.Errorf("Failed to verify a valid signature: %v", err)
+	}
+	if fields := strings.Fields(string(pub)); key != fields[0]+" "+fields[1] {
+		t.Errorf("Unexpected signing key %q; expected %q", key, pub)
+	}
+	if len(principals) != 1 || principals[0] != "user@example.com" {
+		t.Errorf("Unexpected principals %q for the signing key", principals)
+	}
+	// The allowed signers file binds the key to its principal, whoever the
+	// people file claims that the key belongs to.
+	if result := Check(c, policyForTest{key: "other@example.com"}, "other@example.com", s); result.Status != StatusIdentityMismatch {
+		t.Errorf("Unexpected result for a signature by a key bound to someone else: %v", result)
+	}
+	policy := policyForTest{key: "user@example.com"}
+	if result := Check(c, policy, "User@example.com", s); result.Status != StatusValid {
+		t.Errorf("Unexpected result for a valid signature: %v", result)
+	}
+	if result := Check(c, policy, "other@example.com", s); result.Status != StatusIdentityMismatch {
+		t.Errorf("Unexpected result for a signature by someone else: %v", result)
+	}
+	if result := Check(c, policyForTest{}, "user@example.com", s); result.Status != StatusUntrustedKey {
+		t.Errorf("Unexpected result for a signature by an unknown key: %v", result)
+	}
+	if _, _, err := (&Config{}).Verify(s); err == nil {
+		t.Errorf("Unexpectedly verified an SSH signature without an allowed signers file")
+	}
+
+	s.Content = "Needs work"
+	if _, _, err := c.Verify(s); err == nil {
+		t.Errorf("Unexpectedly verified a signature over modified content")
+	}
+	if result := Check(c, policy, "user@example.com", s); result.Status != StatusInvalid {
+		t.Errorf("Unexpected result for a signature over modified content: %v", result)
+	}
+
+	if err := os.WriteFile(allowedSigners, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.Content = "Looks good to me"
+	if _, _, err := c.Verify(s); err == nil {
+		t.Errorf("Unexpectedly verified a signature from an untrusted key")
+	}
+}
@@ -0,0 +1,141 @@
+package gpg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signer signs requests and comments.
+type Signer interface {
+	Sign(s Signable) error
+}
+
+// Verifier checks the signatures on requests and comments.
+type Verifier interface {
+	// Verify checks that the signature on the given request or comment was
+	// made over its contents, and returns the key that made it.
+	//
+	// If the verifier's own configuration names the identities that the key
+	// may sign for, such as the principals of an SSH allowed signers file,
+	// then those are returned too.
+	Verify(s Signable) (key string, principals []string, err error)
+}
+
+// Policy decides which keys are trusted to sign on behalf of which identities.
+type Policy interface {
+	// Owner returns the identity that the given key is trusted to sign for,
+	// or the empty string if the key is not trusted at all.
+	Owner(key string) string
+	// Canonical returns the canonical form of the given identity.
+	Canonical(identity string) string
+}
+
+// Status describes the outcome of checking a signature.
+type Status string
+
+const (
+	// StatusValid means that the signature was made by a key trusted to
+	// sign on behalf of the stated author.
+	StatusValid Status = "valid"
+	// StatusUnsigned means that there is no signature.
+	StatusUnsigned Status = "unsigned"
+	// StatusInvalid means that the signature could not be verified.
+	StatusInvalid Status = "invalid"
+	// StatusUntrustedKey means that the signature was made by a key that the
+	// trust policy does not know about.
+	StatusUntrustedKey Status = "untrusted-key"
+	// StatusIdentityMismatch means that the signature was made by a trusted
+	// key, but one that belongs to someone other than the stated author.
+	StatusIdentityMismatch Status = "identity-mismatch"
+)
+
+// Result is the outcome of checking the signature on a request or comment.
+type Result struct {
+	Status Status `json:"status"`
+	// Key is the key that made the signature, if it could be verified.
+	Key string `json:"key,omitempty"`
+	// Signer is the identity that the key belongs to, if it is trusted.
+	Signer string `json:"signer,omitempty"`
+	// Message explains why a signature could not be verified.
+	Message string `json:"message,omitempty"`
+}
+
+// Check checks the signature on the given request or comment, which states
+// that it was written by the given identity.
+//
+// The verifier establishes which key made the signature, and the policy
+// establishes whether that key may sign on behalf of the stated identity.
+// A nil policy trusts no keys. Keys that the verifier restricts to signing
+// for specific principals, such as those in an SSH allowed signers file, are
+// only trusted to sign on behalf of those principals.
+func Check(v Verifier, p Policy, identity string, s Signable) *Result {
+	if *s.Signature() == "" {
+		return &Result{Status: StatusUnsigned}
+	}
+	key, principals, err := v.Verify(s)
+	if err != nil {
+		return &Result{Status: StatusInvalid, Message: err.Error()}
+	}
+	if !hasPrincipal(p, identity, principals) {
+		return &Result{Status: StatusIdentityMismatch, Key: key, Signer: principals[0]}
+	}
+	return Trust(p, identity, key)
+}
+
+// hasPrincipal returns whether the given identity is one of the principals
+// that the verifier allows a key to sign for, or if the verifier does not
+// restrict the key to any principals.
+func hasPrincipal(p Policy, identity string, principals []string) bool {
+	if len(principals) == 0 {
+		return true
+	}
+	canonical := identity
+	if p != nil {
+		canonical = p.Canonical(identity)
+	}
+	for _, principal := range principals {
+		if strings.EqualFold(principal, identity) || strings.EqualFold(principal, canonical) {
+			return true
+		}
+		if p != nil && strings.EqualFold(p.Canonical(principal), canonical) {
+			return true
+		}
+	}
+	return false
+}
+
+// Trust checks whether the given key, which made a valid signature, may sign
+// on behalf of the given identity.
+//
+// A nil policy trusts no keys.
+func Trust(p Policy, identity, key string) *Result {
+	result := &Result{Status: StatusUntrustedKey, Key: key}
+	if p == nil {
+		return result
+	}
+	result.Signer = p.Owner(key)
+	if result.Signer == "" {
+		return result
+	}
+	if !strings.EqualFold(result.Signer, p.Canonical(identity)) {
+		result.Status = StatusIdentityMismatch
+		return result
+	}
+	result.Status = StatusValid
+	return result
+}
+
+// String returns a human readable description of the result.
+func (r *Result) String() string {
+	switch r.Status {
+	case StatusValid:
+		return fmt.Sprintf("valid signature by %s", r.Signer)
+	case StatusUnsigned:
+		return "not signed"
+	case StatusUntrustedKey:
+		return fmt.Sprintf("signed by the untrusted key %q", r.Key)
+	case StatusIdentityMismatch:
+		return fmt.Sprintf("signed by a key belonging to %s", r.Signer)
+	}
+	return fmt.Sprintf("invalid signature: %s", r.Message)
+}
@@ -0,0 +1,486 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package identity maps the emails recorded in reviews to the people behind them.
+//
+// People are described by two optional files at the top of the repository:
+//
+// The ".mailmap" file uses the same format as git's, and maps the emails that
+// someone has used to their canonical email and display name.
+//
+// The ".appraise/people" file uses git config syntax, with one section per
+// person, keyed by their canonical email:
+//
+//	[person "jane@example.com"]
+//		name = Jane Doe
+//		email = jdoe@old.example.com
+//		key = 0123456789ABCDEF
+//		key = ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAA... jane@laptop
+//		team = core
+//
+// The "email" entries are other emails that the person has used, the "key"
+// entries are their GPG key IDs or SSH public keys (or their "SHA256:"
+// fingerprints), and the "team" entries are the teams that they belong to,
+// which can be used as "@core" wherever a list of reviewers is taken.
+//
+// The keys are also what decides whose signatures are trusted: a request or
+// comment is only considered validly signed if it was signed by one of the
+// keys of its author. As anyone can change these files on their own branch,
+// the keys that are trusted are only ever read from the versions of them on
+// the trusted ref (see LoadTrusted), and never from the working tree.
+package identity
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+)
+
+const (
+	// MailmapPath is the path of the git mailmap file within the repository.
+	MailmapPath = ".mailmap"
+	// PeoplePath is the path of the people file within the repository.
+	PeoplePath = ".appraise/people"
+
+	mailmapFileConfig = "mailmap.file"
+	trustedRefConfig  = "appraise.trustedRef"
+
+	// DefaultTrustedRef is the ref whose people file and mailmap decide whose
+	// signatures are trusted, unless "appraise.trustedRef" says otherwise.
+	DefaultTrustedRef = "refs/heads/master"
+
+	// TeamPrefix marks a name in a list of users as a team.
+	TeamPrefix = "@"
+)
+
+// Person represents a single person, who may have used multiple emails.
+type Person struct {
+	// Email is the canonical email of the person.
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+	// Emails are the other emails that the person has used.
+	Emails []string `json:"emails,omitempty"`
+	// Keys are the GPG key IDs and fingerprints, and the SSH public keys and
+	// fingerprints, of the person.
+	Keys  []string `json:"keys,omitempty"`
+	Teams []string `json:"teams,omitempty"`
+}
+
+// Registry is the collection of all of the known people.
+//
+// A nil registry is valid, and treats every email as canonical.
+type Registry struct {
+	people  map[string]*Person
+	aliases map[string]string
+	teams   map[string][]string
+}
+
+// New returns an empty registry.
+func New() *Registry {
+	return &Registry{
+		people:  make(map[string]*Person),
+		aliases: make(map[string]string),
+		teams:   make(map[string][]string),
+	}
+}
+
+func key(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// readFile reads the given file from the working tree, falling back to the
+// version committed at HEAD for repositories without one.
+func readFile(repo repository.Repo, path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		if contents, err := os.ReadFile(filepath.Join(repo.GetPath(), path)); err == nil {
+			return string(contents), true
+		}
+	} else if contents, err := os.ReadFile(path); err == nil {
+		return string(contents), true
+	}
+	if contents, err := repo.Show("HEAD", path); err == nil {
+		return contents, true
+	}
+	return "", false
+}
+
+// Load reads the registry of people for the given repository.
+func Load(repo repository.Repo) (*Registry, error) {
+	r := New()
+	mailmapPaths := []string{MailmapPath}
+	if paths, err := repo.GetConfigValues(mailmapFileConfig); err == nil {
+		mailmapPaths = append(mailmapPaths, paths...)
+	}
+	for _, path := range mailmapPaths {
+		if contents, ok := readFile(repo, path); ok {
+			r.AddMailmap(contents)
+		}
+	}
+	if contents, ok := readFile(repo, PeoplePath); ok {
+		if err := r.AddPeople(contents); err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", PeoplePath, err)
+		}
+	}
+	return r, nil
+}
+
+// TrustedRef returns the ref whose people file and mailmap decide whose
+// signatures are trusted.
+//
+// This is configured using the "appraise.trustedRef" setting, and should be
+// a ref that only changes once its changes have been reviewed.
+func TrustedRef(repo repository.Repo) string {
+	values, err := repo.GetConfigValues(trustedRefConfig)
+	if err != nil || len(values) == 0 || values[len(values)-1] == "" {
+		return DefaultTrustedRef
+	}
+	return values[len(values)-1]
+}
+
+// LoadTrusted reads the registry of people that decides whose signatures are
+// trusted.
+//
+// Unlike Load, this only reads the people file and mailmap committed to the
+// trusted ref, so that they cannot be changed by whoever's branch happens to
+// be checked out, including the branch of a review whose signatures are
+// being checked. If the trusted ref does not exist, then no keys are trusted.
+func LoadTrusted(repo repository.Repo) (*Registry, error) {
+	r := New()
+	ref := TrustedRef(repo)
+	if contents, err := repo.Show(ref, MailmapPath); err == nil {
+		r.AddMailmap(contents)
+	}
+	if contents, err := repo.Show(ref, PeoplePath); err == nil {
+		if err := r.AddPeople(contents); err != nil {
+			return nil, fmt.Errorf("failed to parse %q on %q: %v", PeoplePath, ref, err)
+		}
+	}
+	return r, nil
+}
+
+// person returns the person with the given canonical email, adding them if needed.
+func (r *Registry) person(email string) *Person {
+	p, ok := r.people[key(email)]
+	if !ok {
+		p = &Person{Email: email}
+		r.people[key(email)] = p
+	}
+	return p
+}
+
+// addAlias records that the given alias is another email of the person with
+// the given canonical email.
+func (r *Registry) addAlias(canonical, alias string) {
+	if key(alias) == key(canonical) {
+		return
+	}
+	r.aliases[key(alias)] = canonical
+	p := r.person(canonical)
+	for _, email := range p.Emails {
+		if key(email) == key(alias) {
+			return
+		}
+	}
+	p.Emails = append(p.Emails, alias)
+}
+
+var mailmapEntryRegexp = regexp.MustCompile(`^([^<]*)<([^>]*)>\s*(?:([^<]*)<([^>]*)>)?`)
+
+// AddMailmap adds the entries of the given mailmap file to the registry.
+//
+// Entries that only match a commit name, rather than an email, are ignored,
+// as reviews only record emails.
+func (r *Registry) AddMailmap(contents string) {
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		match := mailmapEntryRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		name, email, alias := strings.TrimSpace(match[1]), strings.TrimSpace(match[2]), strings.TrimSpace(match[4])
+		if email == "" {
+			continue
+		}
+		canonical := r.Canonical(email)
+		if alias != "" {
+			r.addAlias(canonical, alias)
+		}
+		if name != "" {
+			r.person(canonical).Name = name
+		}
+	}
+}
+
+var sectionRegexp = regexp.MustCompile(`^\[\s*([A-Za-z0-9-]+)\s+"([^"]*)"\s*\]$`)
+
+// AddPeople adds the entries of the given people file to the registry.
+func (r *Registry) AddPeople(contents string) error {
+	var p *Person
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			match := sectionRegexp.FindStringSubmatch(line)
+			if match == nil {
+				return fmt.Errorf("line %d: malformed section header %q", i+1, line)
+			}
+			p = nil
+			if strings.ToLower(match[1]) == "person" {
+				p = r.person(r.Canonical(match[2]))
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected a \"name = value\" entry, found %q", i+1, line)
+		}
+		if p == nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "name":
+			p.Name = value
+		case "email":
+			r.addAlias(p.Email, value)
+		case "key":
+			if isGPGKey(value) && gpgKeyID(value) == "" {
+				return fmt.Errorf("line %d: the GPG key %q is neither a fingerprint nor a long key ID of at least %d hex digits", i+1, value, minGPGKeyIDLength)
+			}
+			p.Keys = append(p.Keys, value)
+		case "team":
+			p.Teams = append(p.Teams, value)
+			r.teams[value] = append(r.teams[value], p.Email)
+		}
+	}
+	return nil
+}
+
+// Canonical returns the canonical email for the given email.
+func (r *Registry) Canonical(email string) string {
+	if r == nil {
+		return email
+	}
+	seen := make(map[string]bool)
+	for !seen[key(email)] {
+		seen[key(email)] = true
+		canonical, ok := r.aliases[key(email)]
+		if !ok {
+			break
+		}
+		email = canonical
+	}
+	return email
+}
+
+// Get returns the person who has used the given email, or nil if they are unknown.
+func (r *Registry) Get(email string) *Person {
+	if r == nil {
+		return nil
+	}
+	return r.people[key(r.Canonical(email))]
+}
+
+// Name returns the display name of the person who has used the given email,
+// or the empty string if it is unknown.
+func (r *Registry) Name(email string) string {
+	if p := r.Get(email); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// DisplayName returns the given email, prefixed by the name of its owner if that is known.
+func (r *Registry) DisplayName(email string) string {
+	if name := r.Name(email); name != "" {
+		return fmt.Sprintf("%s <%s>", name, email)
+	}
+	return email
+}
+
+// Emails returns the canonical emails of all of the known people, in sorted order.
+func (r *Registry) Emails() []string {
+	if r == nil {
+		return nil
+	}
+	var emails []string
+	for _, p := range r.people {
+		emails = append(emails, p.Email)
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+// Team returns the canonical emails of the members of the given team.
+func (r *Registry) Team(team string) []string {
+	if r == nil {
+		return nil
+	}
+	return r.teams[team]
+}
+
+// Expand returns the canonical emails of the given users.
+//
+// Users prefixed with "@" are treated as team names, and replaced by the
+// members of that team. Duplicates are removed.
+func (r *Registry) Expand(users []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	add := func(email string) {
+		if !seen[key(email)] {
+			seen[key(email)] = true
+			result = append(result, email)
+		}
+	}
+	for _, user := range users {
+		if team := strings.TrimPrefix(user, TeamPrefix); team != user {
+			members := r.Team(team)
+			if len(members) == 0 {
+				return nil, fmt.Errorf("Unknown team %q; teams are defined in %q.", team, PeoplePath)
+			}
+			for _, member := range members {
+				add(member)
+			}
+			continue
+		}
+		add(r.Canonical(user))
+	}
+	return result, nil
+}
+
+// FindByKey returns the person who owns the given GPG key ID or SSH public
+// key, or nil if it is unknown.
+//
+// GPG key IDs match if either is a suffix of the other, so that long key IDs
+// and fingerprints match each other. Short key IDs, which are easily forged,
+// never match. SSH keys match if their type and key
+// material match, regardless of their comment, or if one is the "SHA256:"
+// fingerprint of the other.
+func (r *Registry) FindByKey(k string) *Person {
+	if r == nil || k == "" {
+		return nil
+	}
+	for _, p := range r.people {
+		for _, known := range p.Keys {
+			if keysMatch(known, k) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// Owner returns the canonical email of the person who owns the given key, or
+// the empty string if it is unknown.
+//
+// This allows the registry to be used as the trust policy for signatures.
+func (r *Registry) Owner(k string) string {
+	if p := r.FindByKey(k); p != nil {
+		return p.Email
+	}
+	return ""
+}
+
+// HasKeys returns whether the key of anyone is known.
+func (r *Registry) HasKeys() bool {
+	if r == nil {
+		return false
+	}
+	for _, p := range r.people {
+		if len(p.Keys) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func keysMatch(a, b string) bool {
+	aFields, bFields := strings.Fields(a), strings.Fields(b)
+	if strings.HasPrefix(a, sshFingerprintPrefix) && len(bFields) >= 2 {
+		return a == sshFingerprint(bFields[1])
+	}
+	if strings.HasPrefix(b, sshFingerprintPrefix) && len(aFields) >= 2 {
+		return b == sshFingerprint(aFields[1])
+	}
+	if len(aFields) >= 2 || len(bFields) >= 2 {
+		return len(aFields) >= 2 && len(bFields) >= 2 &&
+			aFields[0] == bFields[0] && aFields[1] == bFields[1]
+	}
+	a, b = gpgKeyID(a), gpgKeyID(b)
+	return a != "" && b != "" && (strings.HasSuffix(a, b) || strings.HasSuffix(b, a))
+}
+
+// minGPGKeyIDLength is the number of hex digits in a long GPG key ID.
+const minGPGKeyIDLength = 16
+
+var hexRegexp = regexp.MustCompile(`^[0-9A-F]+$`)
+
+// isGPGKey returns whether the given key is a GPG key ID or fingerprint, as
+// opposed to an SSH public key or fingerprint.
+func isGPGKey(k string) bool {
+	return len(strings.Fields(k)) == 1 && !strings.HasPrefix(k, sshFingerprintPrefix)
+}
+
+// gpgKeyID returns the normalized form of the given GPG fingerprint or long
+// key ID, or the empty string if it is neither.
+func gpgKeyID(k string) string {
+	k = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(k), "0x"))
+	if len(k) < minGPGKeyIDLength || !hexRegexp.MatchString(k) {
+		return ""
+	}
+	return k
+}
+
+const sshFingerprintPrefix = "SHA256:"
+
+// sshFingerprint returns the fingerprint of the given base64 encoded SSH
+// public key, in the format printed by "ssh-keygen -l".
+func sshFingerprint(key string) string {
+	blob, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(blob)
+	return sshFingerprintPrefix + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// CurrentUser returns the canonical email of the current user.
+func CurrentUser(repo repository.Repo) (string, error) {
+	email, err := repo.GetUserEmail()
+	if err != nil {
+		return "", err
+	}
+	r, err := Load(repo)
+	if err != nil {
+		return "", err
+	}
+	return r.Canonical(email), nil
+}
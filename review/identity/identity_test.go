@@ -0,0 +1,124 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testMailmap = `
+# Comments and blank lines are ignored.
+Jane Doe <jane@example.com>
+<jane@example.com> <jdoe@old.example.com>
+Bob <bob@example.com> Robert <robert@example.com>
+`
+
+const testPeople = `
+[person "jane@example.com"]
+	name = Jane Q. Doe
+	email = jane@laptop
+	key = 0123456789ABCDEF
+	team = core
+
+; Aliases are resolved before adding people.
+[person "robert@example.com"]
+	key = ssh-ed25519 AAAAkey bob@laptop
+	team = core
+	team = docs
+`
+
+func TestRegistry(t *testing.T) {
+	r := New()
+	r.AddMailmap(testMailmap)
+	if err := r.AddPeople(testPeople); err != nil {
+		t.Fatal(err)
+	}
+
+	for email, expected := range map[string]string{
+		"jane@example.com":     "jane@example.com",
+		"JDoe@old.example.com": "jane@example.com",
+		"jane@laptop":          "jane@example.com",
+		"robert@example.com":   "bob@example.com",
+		"someone@example.com":  "someone@example.com",
+	} {
+		if canonical := r.Canonical(email); canonical != expected {
+			t.Errorf("Unexpected canonical email for %q: %q", email, canonical)
+		}
+	}
+	if name := r.DisplayName("jane@laptop"); name != "Jane Q. Doe <jane@laptop>" {
+		t.Errorf("Unexpected display name: %q", name)
+	}
+	if name := r.DisplayName("someone@example.com"); name != "someone@example.com" {
+		t.Errorf("Unexpected display name for an unknown user: %q", name)
+	}
+
+	expanded, err := r.Expand([]string{"robert@example.com", "@core", "other@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expanded, []string{"bob@example.com", "jane@example.com", "other@example.com"}) {
+		t.Errorf("Unexpected expansion: %v", expanded)
+	}
+	if _, err := r.Expand([]string{"@nobody"}); err == nil {
+		t.Error("Unexpected success expanding an unknown team")
+	}
+	if emails := r.Emails(); !reflect.DeepEqual(emails, []string{"bob@example.com", "jane@example.com"}) {
+		t.Errorf("Unexpected emails: %v", emails)
+	}
+
+	if p := r.FindByKey("FEDCBA98765432100123456789abcdef"); p == nil || p.Email != "jane@example.com" {
+		t.Errorf("Unexpected owner of a GPG key: %v", p)
+	}
+	if p := r.FindByKey("89abcdef"); p != nil {
+		t.Errorf("Unexpected owner of a short GPG key ID: %v", p)
+	}
+	if err := New().AddPeople("[person \"mallory@example.com\"]\n\tkey = 89ABCDEF\n"); err == nil {
+		t.Error("Unexpected success adding a person with a short GPG key ID")
+	}
+	if p := r.FindByKey("ssh-ed25519 AAAAkey"); p == nil || p.Email != "bob@example.com" {
+		t.Errorf("Unexpected owner of an SSH key: %v", p)
+	}
+	if p := r.FindByKey("ssh-rsa AAAAkey"); p != nil {
+		t.Errorf("Unexpected owner of an unknown key: %v", p)
+	}
+
+	fingerprints := New()
+	if err := fingerprints.AddPeople("[person \"carol@example.com\"]\n\tkey = SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU\n"); err != nil {
+		t.Fatal(err)
+	}
+	if owner := fingerprints.Owner("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"); owner != "carol@example.com" {
+		t.Errorf("Unexpected owner of an SSH key listed by its fingerprint: %q", owner)
+	}
+	if !fingerprints.HasKeys() || New().HasKeys() {
+		t.Error("Unexpected result from HasKeys")
+	}
+
+	var nilRegistry *Registry
+	if nilRegistry.Canonical("a@b") != "a@b" || nilRegistry.DisplayName("a@b") != "a@b" {
+		t.Error("Unexpected behavior of a nil registry")
+	}
+}
+
+func TestMalformedPeople(t *testing.T) {
+	if err := New().AddPeople("[person jane]\n"); err == nil {
+		t.Error("Unexpected success parsing a malformed section header")
+	}
+	if err := New().AddPeople("[person \"jane@example.com\"]\nname\n"); err == nil {
+		t.Error("Unexpected success parsing a malformed entry")
+	}
+}
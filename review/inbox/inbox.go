@@ -0,0 +1,211 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inbox classifies the open code reviews according to what, if
+// anything, they need from a given user.
+package inbox
+
+import (
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/mention"
+)
+
+// Mention represents a comment thread in which a user was mentioned.
+type Mention struct {
+	Review review.Summary       `json:"review"`
+	Thread review.CommentThread `json:"thread"`
+}
+
+// Inbox represents the open reviews that need the attention of a user.
+//
+// A single review may appear in more than one category.
+type Inbox struct {
+	User string `json:"user"`
+	// AwaitingReview holds the reviews for which the user is a reviewer, but
+	// has not yet commented upon the latest head commit.
+	AwaitingReview []review.Summary `json:"awaitingReview"`
+	// NewComments holds the user's own reviews which others have commented
+	// upon since the user last updated or commented upon them.
+	NewComments []review.Summary `json:"newComments"`
+	// ReadyToSubmit holds the user's own reviews which have been accepted.
+	ReadyToSubmit []review.Summary `json:"readyToSubmit"`
+	// Mentions holds the comment threads in which the user was mentioned by someone else.
+	Mentions []Mention `json:"mentions"`
+}
+
+// HeadCommitFunc returns the current head commit of a review.
+type HeadCommitFunc func(r *review.Summary) (string, error)
+
+// Get builds the inbox of the given user from the open reviews in the repository.
+func Get(repo repository.Repo, people *identity.Registry, userEmail string) (*Inbox, error) {
+	return Build(review.ListOpen(repo), people, userEmail, func(r *review.Summary) (string, error) {
+		details, err := r.Details()
+		if err != nil {
+			return "", err
+		}
+		return details.GetHeadCommit()
+	})
+}
+
+// Build builds the inbox of the given user from the given open reviews.
+//
+// Mentions are resolved against the people in the given registry and the
+// participants of each review, in the same way as when showing the review.
+func Build(reviews []review.Summary, people *identity.Registry, userEmail string, headCommit HeadCommitFunc) (*Inbox, error) {
+	inbox := &Inbox{
+		User:           userEmail,
+		AwaitingReview: []review.Summary{},
+		NewComments:    []review.Summary{},
+		ReadyToSubmit:  []review.Summary{},
+		Mentions:       []Mention{},
+	}
+	for _, r := range reviews {
+		if !r.IsOpen() {
+			continue
+		}
+		if isUser(r.Request.Requester, userEmail) {
+			if hasNewComments(&r, userEmail) {
+				inbox.NewComments = append(inbox.NewComments, r)
+			}
+			if r.Resolved != nil && *r.Resolved {
+				inbox.ReadyToSubmit = append(inbox.ReadyToSubmit, r)
+			}
+		} else if isReviewer(&r, userEmail) {
+			head, err := headCommit(&r)
+			if err != nil {
+				return nil, err
+			}
+			if !hasCommentedOn(r.Comments, userEmail, head) {
+				inbox.AwaitingReview = append(inbox.AwaitingReview, r)
+			}
+		}
+		identities := r.KnownIdentities(people)
+		for _, thread := range mentioningThreads(r.Comments, people, identities, userEmail) {
+			inbox.Mentions = append(inbox.Mentions, Mention{
+				Review: r,
+				Thread: thread,
+			})
+		}
+	}
+	return inbox, nil
+}
+
+func isUser(user, userEmail string) bool {
+	return userEmail != "" && strings.EqualFold(user, userEmail)
+}
+
+func isReviewer(r *review.Summary, userEmail string) bool {
+	for _, reviewer := range r.Request.Reviewers {
+		if isUser(reviewer, userEmail) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCommentedOn returns whether or not the user has commented upon the given commit.
+func hasCommentedOn(threads []review.CommentThread, userEmail, commit string) bool {
+	for _, thread := range threads {
+		c := thread.Comment
+		if isUser(c.Author, userEmail) && c.Location != nil && c.Location.Commit == commit {
+			return true
+		}
+		if hasCommentedOn(thread.Children, userEmail, commit) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastAction returns the timestamp of the user's latest request or comment on the review.
+func lastAction(r *review.Summary, userEmail string) string {
+	var latest string
+	for _, req := range r.AllRequests {
+		if isUser(req.Requester, userEmail) && req.LastModified() > latest {
+			latest = req.LastModified()
+		}
+	}
+	var visit func(threads []review.CommentThread)
+	visit = func(threads []review.CommentThread) {
+		for _, thread := range threads {
+			if isUser(thread.Comment.Author, userEmail) && thread.Comment.Timestamp > latest {
+				latest = thread.Comment.Timestamp
+			}
+			visit(thread.Children)
+		}
+	}
+	visit(r.Comments)
+	return latest
+}
+
+// hasNewComments returns whether or not someone other than the user has
+// commented upon the review since the user's last action on it.
+func hasNewComments(r *review.Summary, userEmail string) bool {
+	since := lastAction(r, userEmail)
+	var visit func(threads []review.CommentThread) bool
+	visit = func(threads []review.CommentThread) bool {
+		for _, thread := range threads {
+			if !isUser(thread.Comment.Author, userEmail) && thread.Comment.Timestamp > since {
+				return true
+			}
+			if visit(thread.Children) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(r.Comments)
+}
+
+// mentioningThreads returns the top-level threads containing a comment,
+// written by someone else, that mentions the user.
+func mentioningThreads(threads []review.CommentThread, people *identity.Registry, identities []string, userEmail string) []review.CommentThread {
+	var mentioned func(threads []review.CommentThread) bool
+	mentioned = func(threads []review.CommentThread) bool {
+		for _, thread := range threads {
+			if mentionedIn(&thread.Comment, people, identities, userEmail) || mentioned(thread.Children) {
+				return true
+			}
+		}
+		return false
+	}
+	var result []review.CommentThread
+	for _, thread := range threads {
+		if mentioned([]review.CommentThread{thread}) {
+			result = append(result, thread)
+		}
+	}
+	return result
+}
+
+// mentionedIn returns whether or not the comment, written by someone else,
+// mentions the user once its mentions are resolved against the given identities.
+func mentionedIn(c *comment.Comment, people *identity.Registry, identities []string, userEmail string) bool {
+	if isUser(c.Author, userEmail) {
+		return false
+	}
+	for _, m := range mention.ResolveAll(c.Description, identities) {
+		if isUser(people.Canonical(m), userEmail) {
+			return true
+		}
+	}
+	return false
+}
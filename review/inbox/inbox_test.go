@@ -0,0 +1,127 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inbox
+
+import (
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+const me = "me@example.com"
+
+func TestBuild(t *testing.T) {
+	accepted := true
+	reviews := []review.Summary{
+		// Awaiting my review, as I only commented on an older commit.
+		review.Summary{
+			Revision: "A",
+			Request:  request.Request{Requester: "alice@example.com", Reviewers: []string{me}, TargetRef: "refs/heads/master"},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment: comment.Comment{Author: me, Location: &comment.Location{Commit: "A"}},
+				},
+			},
+		},
+		// Already reviewed at the latest head.
+		review.Summary{
+			Revision: "B",
+			Request:  request.Request{Requester: "alice@example.com", Reviewers: []string{me}, TargetRef: "refs/heads/master"},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment: comment.Comment{Author: me, Location: &comment.Location{Commit: "B-head"}},
+				},
+			},
+		},
+		// My review, with a reply since my last comment, that has been accepted.
+		review.Summary{
+			Revision:    "C",
+			Request:     request.Request{Requester: me, TargetRef: "refs/heads/master"},
+			AllRequests: []request.Request{request.Request{Requester: me, Timestamp: "0000000010"}},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment: comment.Comment{Author: me, Timestamp: "0000000020"},
+					Children: []review.CommentThread{
+						review.CommentThread{
+							Comment: comment.Comment{Author: "bob@example.com", Timestamp: "0000000030", Resolved: &accepted},
+						},
+					},
+				},
+			},
+			Resolved: &accepted,
+		},
+		// My review, rebased since the last comment on it.
+		review.Summary{
+			Revision: "E",
+			Request:  request.Request{Requester: me, TargetRef: "refs/heads/master"},
+			AllRequests: []request.Request{
+				request.Request{Requester: me, Timestamp: "0000000010"},
+				request.Request{Requester: me, Timestamp: "0000000010", Modified: "0000000040", Alias: "E2"},
+			},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment: comment.Comment{Author: "bob@example.com", Timestamp: "0000000030"},
+				},
+			},
+		},
+		// Someone else's review that I was mentioned in.
+		review.Summary{
+			Revision: "D",
+			Request:  request.Request{Requester: "bob@example.com", TargetRef: "refs/heads/master"},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment: comment.Comment{Author: "bob@example.com", Description: "What do you think, @me?"},
+				},
+			},
+		},
+		// Someone else's review that mentions another user with my local part.
+		review.Summary{
+			Revision: "F",
+			Request:  request.Request{Requester: "bob@example.com", Reviewers: []string{"me@elsewhere.example.com"}, TargetRef: "refs/heads/master"},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment: comment.Comment{Author: "bob@example.com", Description: "What do you think, @me?"},
+				},
+			},
+		},
+	}
+	people := identity.New()
+	if err := people.AddPeople("[person \"" + me + "\"]\n\tname = Me\n"); err != nil {
+		t.Fatal(err)
+	}
+	inbox, err := Build(reviews, people, me, func(r *review.Summary) (string, error) {
+		return r.Revision + "-head", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inbox.AwaitingReview) != 1 || inbox.AwaitingReview[0].Revision != "A" {
+		t.Errorf("Unexpected reviews awaiting review: %v", inbox.AwaitingReview)
+	}
+	if len(inbox.NewComments) != 1 || inbox.NewComments[0].Revision != "C" {
+		t.Errorf("Unexpected reviews with new comments: %v", inbox.NewComments)
+	}
+	if len(inbox.ReadyToSubmit) != 1 || inbox.ReadyToSubmit[0].Revision != "C" {
+		t.Errorf("Unexpected reviews ready to submit: %v", inbox.ReadyToSubmit)
+	}
+	if len(inbox.Mentions) != 1 || inbox.Mentions[0].Review.Revision != "D" {
+		t.Errorf("Unexpected mentions: %v", inbox.Mentions)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mention parses "@" mentions of users out of free-form text, such
+// as the descriptions of review requests and comments.
+//
+// Users are mentioned with an "@" followed either by their full email
+// address (e.g. "@alice@example.com") or by its local part (e.g. "@alice").
+package mention
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mentionRe matches a mention at the start of a word.
+var mentionRe = regexp.MustCompile(`(?:^|[^\w.@])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// Parse returns the mentions in the given text, without the leading "@".
+func Parse(text string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+	for _, match := range mentionRe.FindAllStringSubmatch(text, -1) {
+		mention := strings.TrimRight(match[1], ".")
+		if mention != "" && !seen[mention] {
+			seen[mention] = true
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+// Matches returns whether or not the mention refers to the user with the given email.
+func Matches(mention, email string) bool {
+	if email == "" {
+		return false
+	}
+	if strings.EqualFold(mention, email) {
+		return true
+	}
+	localPart, _, _ := strings.Cut(email, "@")
+	return strings.EqualFold(mention, localPart)
+}
+
+// Resolve resolves a mention to the email of one of the known identities.
+//
+// Mentions of a full email address are returned as is. Mentions of a local
+// part are resolved only if exactly one known identity matches them, and
+// are otherwise returned as is, prefixed with an "@" to mark them as unresolved.
+func Resolve(mention string, identities []string) string {
+	if strings.Contains(mention, "@") {
+		return mention
+	}
+	var resolved string
+	for _, identity := range identities {
+		if Matches(mention, identity) {
+			if resolved != "" && !strings.EqualFold(resolved, identity) {
+				return "@" + mention
+			}
+			resolved = identity
+		}
+	}
+	if resolved == "" {
+		return "@" + mention
+	}
+	return resolved
+}
+
+// ResolveAll resolves each of the mentions in the given text.
+func ResolveAll(text string, identities []string) []string {
+	var resolved []string
+	for _, m := range Parse(text) {
+		resolved = append(resolved, Resolve(m, identities))
+	}
+	return resolved
+}
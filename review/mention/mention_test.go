@@ -0,0 +1,53 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mention
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for text, expected := range map[string][]string{
+		"Thoughts, @alice?":             {"alice"},
+		"cc @bob@example.com.":          {"bob@example.com"},
+		"(@carol.d) and @carol.d again": {"carol.d"},
+		"Mail me at dave@example.com":   nil,
+		"@erin, @frank and @erin":       {"erin", "frank"},
+		"No mentions here":              nil,
+		"trailing @":                    nil,
+	} {
+		if mentions := Parse(text); !reflect.DeepEqual(mentions, expected) {
+			t.Errorf("Unexpected mentions in %q: %v", text, mentions)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	identities := []string{"alice@example.com", "bob@example.com", "bob@example.org"}
+	for m, expected := range map[string]string{
+		"alice":             "alice@example.com",
+		"ALICE":             "alice@example.com",
+		"bob":               "@bob",
+		"carol":             "@carol",
+		"carol@example.com": "carol@example.com",
+	} {
+		if resolved := Resolve(m, identities); resolved != expected {
+			t.Errorf("Unexpected resolution of %q: %q", m, resolved)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/mention"
+)
+
+// Identities returns the emails of every user that has requested, reviewed,
+// or commented upon any of the given reviews.
+func Identities(reviews []Summary) []string {
+	seen := make(map[string]bool)
+	var identities []string
+	add := func(user string) {
+		if user != "" && !seen[strings.ToLower(user)] {
+			seen[strings.ToLower(user)] = true
+			identities = append(identities, user)
+		}
+	}
+	var visit func(threads []CommentThread)
+	visit = func(threads []CommentThread) {
+		for _, thread := range threads {
+			add(thread.Comment.Author)
+			visit(thread.Children)
+		}
+	}
+	for _, r := range reviews {
+		add(r.Request.Requester)
+		for _, reviewer := range r.Request.Reviewers {
+			add(reviewer)
+		}
+		visit(r.Comments)
+	}
+	sort.Strings(identities)
+	return identities
+}
+
+// KnownIdentities returns the emails that mentions in the review are
+// resolved against: those of the people in the given registry, and those of
+// the review's own participants.
+func (r *Summary) KnownIdentities(people *identity.Registry) []string {
+	return append(people.Emails(), Identities([]Summary{*r})...)
+}
+
+// GetMentions returns the users mentioned in the review's request and
+// comments, resolved against the given known identities.
+func (r *Summary) GetMentions(identities []string) []string {
+	seen := make(map[string]bool)
+	var mentions []string
+	add := func(text string) {
+		for _, m := range mention.ResolveAll(text, identities) {
+			if !seen[m] {
+				seen[m] = true
+				mentions = append(mentions, m)
+			}
+		}
+	}
+	add(r.Request.Description)
+	var visit func(threads []CommentThread)
+	visit = func(threads []CommentThread) {
+		for _, thread := range threads {
+			add(thread.Comment.Description)
+			visit(thread.Children)
+		}
+	}
+	visit(r.Comments)
+	return mentions
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// canonicalizeIdentities replaces the emails recorded in the review with the
+// canonical emails of the people that used them.
+//
+// This changes the requests and comments of the review, so it must not be
+// done for reviews whose signatures are yet to be verified.
+func (r *Summary) canonicalizeIdentities(people *identity.Registry) {
+	r.People = people
+	canonicalizeRequest := func(req *request.Request) {
+		req.Requester = people.Canonical(req.Requester)
+		var reviewers []string
+		for _, reviewer := range req.Reviewers {
+			reviewers = append(reviewers, people.Canonical(reviewer))
+		}
+		req.Reviewers = reviewers
+	}
+	canonicalizeRequest(&r.Request)
+	for i := range r.AllRequests {
+		canonicalizeRequest(&r.AllRequests[i])
+	}
+	var visit func(threads []CommentThread)
+	visit = func(threads []CommentThread) {
+		for i := range threads {
+			thread := &threads[i]
+			thread.Comment.Author = people.Canonical(thread.Comment.Author)
+			if thread.Original != nil {
+				thread.Original.Author = people.Canonical(thread.Original.Author)
+			}
+			for _, edit := range thread.Edits {
+				edit.Author = people.Canonical(edit.Author)
+			}
+			visit(thread.Children)
+		}
+	}
+	visit(r.Comments)
+}
+
+// DisplayName returns the given email, prefixed by the name of its owner if that is known.
+func (r *Summary) DisplayName(email string) string {
+	return r.People.DisplayName(email)
+}
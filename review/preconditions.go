@@ -0,0 +1,155 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/analyses"
+	"github.com/KoviRobi/git-appraise/review/checklist"
+	"github.com/KoviRobi/git-appraise/review/ci"
+)
+
+// The git config settings used to configure the submit preconditions.
+const (
+	requireCIConfig        = "appraise.requireCI"
+	requireCIAgentConfig   = "appraise.requireCIAgent"
+	requireAnalysesConfig  = "appraise.requireAnalyses"
+	requireChecklistConfig = "appraise.requireChecklist"
+)
+
+// Preconditions defines the automated checks that a review must pass before being submitted.
+type Preconditions struct {
+	// RequireCI requires that the latest CI reports from every agent for the
+	// head of the review together be a success.
+	RequireCI bool
+	// CIAgents, if specified, requires that the latest CI report from each
+	// of the given agents be a success, rather than just the latest overall.
+	CIAgents []string
+	// RequireAnalyses requires that the latest analysis report for the head
+	// of the review either be "lgtm" or "fyi".
+	RequireAnalyses bool
+	// RequireChecklist requires that every item of the checklist in the
+	// description of the review be checked.
+	RequireChecklist bool
+}
+
+func getConfigBool(repo repository.Repo, name string) (bool, error) {
+	values, err := repo.GetConfigValues(name)
+	if err != nil || len(values) == 0 {
+		return false, err
+	}
+	b, err := strconv.ParseBool(values[len(values)-1])
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %q: %v", name, err)
+	}
+	return b, nil
+}
+
+// GetPreconditions reads the submit preconditions configured for the repository.
+//
+// These are configured using the "appraise.requireCI",
+// "appraise.requireAnalyses" and "appraise.requireChecklist" boolean
+// settings, and the multi-valued
+// "appraise.requireCIAgent" setting. Requiring specific CI agents implies
+// requiring CI.
+func GetPreconditions(repo repository.Repo) (*Preconditions, error) {
+	var p Preconditions
+	var err error
+	if p.RequireCI, err = getConfigBool(repo, requireCIConfig); err != nil {
+		return nil, err
+	}
+	if p.RequireAnalyses, err = getConfigBool(repo, requireAnalysesConfig); err != nil {
+		return nil, err
+	}
+	if p.RequireChecklist, err = getConfigBool(repo, requireChecklistConfig); err != nil {
+		return nil, err
+	}
+	agents, err := repo.GetConfigValues(requireCIAgentConfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		for _, a := range strings.Split(agent, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				p.CIAgents = append(p.CIAgents, a)
+			}
+		}
+	}
+	if len(p.CIAgents) > 0 {
+		p.RequireCI = true
+	}
+	return &p, nil
+}
+
+// UnmetPreconditions returns a description of each of the given
+// preconditions that the review does not meet.
+func (r *Review) UnmetPreconditions(p *Preconditions) []string {
+	var unmet []string
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return []string{fmt.Sprintf("the head commit of the review is unknown: %v", err)}
+	}
+
+	if p.RequireCI && len(p.CIAgents) == 0 {
+		matrix, err := r.GetCIMatrix()
+		if err != nil {
+			unmet = append(unmet, fmt.Sprintf("the CI reports for %.12s are malformed: %v", head, err))
+		} else if len(matrix) == 0 {
+			unmet = append(unmet, fmt.Sprintf("there is no CI report for %.12s", head))
+		} else if status := ci.AggregateStatus(matrix); status != ci.StatusSuccess {
+			unmet = append(unmet, fmt.Sprintf("the CI status of %.12s, across the latest reports from each agent, is %q rather than %q", head, status, ci.StatusSuccess))
+		}
+	}
+	for _, agent := range p.CIAgents {
+		var agentReports []ci.Report
+		for _, report := range r.Reports {
+			if report.Agent == agent {
+				agentReports = append(agentReports, report)
+			}
+		}
+		latest, err := ci.GetLatestCIReport(agentReports)
+		if err != nil {
+			unmet = append(unmet, fmt.Sprintf("the CI reports from %q for %.12s are malformed: %v", agent, head, err))
+		} else if latest == nil {
+			unmet = append(unmet, fmt.Sprintf("there is no CI report from %q for %.12s", agent, head))
+		} else if latest.Status != ci.StatusSuccess {
+			unmet = append(unmet, fmt.Sprintf("the latest CI report from %q for %.12s is %q rather than %q", agent, head, latest.Status, ci.StatusSuccess))
+		}
+	}
+
+	if p.RequireAnalyses {
+		latest, err := analyses.GetLatestAnalysesReport(r.Analyses)
+		if err != nil {
+			unmet = append(unmet, fmt.Sprintf("the analysis reports for %.12s are malformed: %v", head, err))
+		} else if latest == nil {
+			unmet = append(unmet, fmt.Sprintf("there is no analysis report for %.12s", head))
+		} else if latest.Status != analyses.StatusLooksGoodToMe && latest.Status != analyses.StatusForYourInformation {
+			unmet = append(unmet, fmt.Sprintf("the latest analysis report for %.12s is %q rather than %q or %q", head, latest.Status, analyses.StatusLooksGoodToMe, analyses.StatusForYourInformation))
+		}
+	}
+
+	if p.RequireChecklist {
+		for _, item := range checklist.Remaining(r.GetChecklist()) {
+			unmet = append(unmet, fmt.Sprintf("the checklist item %d (%q) is not checked", item.Number, item.Text))
+		}
+	}
+	return unmet
+}
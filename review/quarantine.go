@@ -0,0 +1,240 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/checklist"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/discussion"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// quarantineRefPrefix is the prefix of the refs holding the notes that were
+// fetched from remotes, but not merged because they could not be verified.
+//
+// These are deliberately outside of "refs/notes/devtools/", so that they are
+// neither pushed nor pulled.
+const quarantineRefPrefix = "refs/notes/devtools-quarantine/"
+
+// quarantinedRefs are the notes refs whose contents are signed, and so may
+// be quarantined.
+var quarantinedRefs = []string{request.Ref, comment.Ref, discussion.Ref, checklist.Ref}
+
+// QuarantineRef returns the ref holding the notes quarantined from the given
+// remote for the given notes ref.
+func QuarantineRef(remote, notesRef string) string {
+	return quarantineRefPrefix + remote + "/" + strings.TrimPrefix(notesRef, "refs/notes/devtools/")
+}
+
+// QuarantinedReview identifies the notes of a review, or of a discussion,
+// that were fetched from a remote, but held back because their signatures
+// could not be verified.
+type QuarantinedReview struct {
+	Remote   string `json:"remote"`
+	Revision string `json:"revision"`
+}
+
+// verifyNotes verifies the signatures on the requests, comments, discussion
+// topics, and checklist marks for the given revision, where the notes for
+// each of those are read from the ref returned by notesRef.
+//
+// Revisions that have comments but no review request, such as those holding
+// detached comments or discussions, only have their comments verified along
+// with any topics and marks.
+func verifyNotes(repo repository.Repo, notesRef func(ref string) string, revision string) error {
+	people, err := identity.LoadTrusted(repo)
+	if err != nil {
+		return err
+	}
+	v := gpg.LoadConfig(repo)
+	requireTrusted := people.HasKeys()
+	for _, topic := range discussion.ParseAllValid(repo.GetNotes(notesRef(discussion.Ref), revision)) {
+		if err := signatureError(gpg.Check(v, people, topic.Author, &topic), requireTrusted); err != nil {
+			return fmt.Errorf("verification of the discussion topic from %s failed: %s", topic.Timestamp, err)
+		}
+	}
+	for _, mark := range checklist.ParseAllValid(repo.GetNotes(notesRef(checklist.Ref), revision)) {
+		if err := signatureError(gpg.Check(v, people, mark.Author, &mark), requireTrusted); err != nil {
+			return fmt.Errorf("verification of the checklist mark from %s failed: %s", mark.Timestamp, err)
+		}
+	}
+	if len(repo.GetNotes(notesRef(request.Ref), revision)) == 0 {
+		threads, _ := getCommentsFromNotes(repo, revision, repo.GetNotes(notesRef(comment.Ref), revision))
+		checkThreadSignatures(v, people, threads)
+		return verifyThreads(threads, requireTrusted)
+	}
+	summary, err := GetSummaryViaRefs(repo, notesRef(request.Ref), notesRef(comment.Ref), revision)
+	if err != nil {
+		return err
+	}
+	return summary.Verify()
+}
+
+// VerifyFetched verifies the signatures on the notes for the given revision
+// that were fetched from the given remote.
+func VerifyFetched(repo repository.Repo, remote, revision string) error {
+	return verifyNotes(repo, func(ref string) string {
+		return repository.RemoteNotesRef(remote, ref)
+	}, revision)
+}
+
+// unionNotes returns the distinct, non-empty, notes of both lists, sorted in
+// the same way as by the "cat_sort_uniq" strategy for merging notes.
+func unionNotes(a, b []repository.Note) []repository.Note {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, note := range append(append([]repository.Note(nil), a...), b...) {
+		line := string(note)
+		if strings.TrimSpace(line) == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	var notes []repository.Note
+	for _, line := range lines {
+		notes = append(notes, repository.Note(line))
+	}
+	return notes
+}
+
+// Quarantine moves the requests and comments for the given revision that were
+// fetched from the given remote into quarantine, so that merging the fetched
+// notes leaves the local notes for the revision unchanged.
+func Quarantine(repo repository.Repo, remote, revision string) error {
+	for _, ref := range quarantinedRefs {
+		fetchedRef := repository.RemoteNotesRef(remote, ref)
+		fetched := repo.GetNotes(fetchedRef, revision)
+		if len(fetched) == 0 {
+			continue
+		}
+		quarantineRef := QuarantineRef(remote, ref)
+		quarantined := unionNotes(repo.GetNotes(quarantineRef, revision), fetched)
+		if err := repo.SetNotes(quarantineRef, revision, quarantined); err != nil {
+			return err
+		}
+		// Merging notes that match the local ones is a no-op.
+		if err := repo.SetNotes(fetchedRef, revision, repo.GetNotes(ref, revision)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListQuarantined returns the reviews with notes in quarantine, sorted by
+// remote and then revision.
+func ListQuarantined(repo repository.Repo) ([]QuarantinedReview, error) {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	var result []QuarantinedReview
+	for _, remote := range remotes {
+		seen := make(map[string]bool)
+		for _, ref := range quarantinedRefs {
+			for _, revision := range repo.ListNotedRevisions(QuarantineRef(remote, ref)) {
+				if !seen[revision] {
+					seen[revision] = true
+					result = append(result, QuarantinedReview{Remote: remote, Revision: revision})
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Remote != result[j].Remote {
+			return result[i].Remote < result[j].Remote
+		}
+		return result[i].Revision < result[j].Revision
+	})
+	return result, nil
+}
+
+// Summary returns the summary of the review as it would be if the quarantined
+// notes were accepted, or nil if there is no review request for it.
+func (q QuarantinedReview) Summary(repo repository.Repo) *Summary {
+	notes := make(map[string][]repository.Note)
+	for _, ref := range quarantinedRefs {
+		notes[ref] = unionNotes(repo.GetNotes(ref, q.Revision), repo.GetNotes(QuarantineRef(q.Remote, ref), q.Revision))
+	}
+	summary, err := getSummaryFromNotes(repo, q.Revision, notes[request.Ref], notes[comment.Ref])
+	if err != nil {
+		return nil
+	}
+	return summary
+}
+
+// Title returns the first line of the description of the review, or the
+// title of the discussion, as it would be if the quarantined notes were
+// accepted.
+func (q QuarantinedReview) Title(repo repository.Repo) string {
+	if summary := q.Summary(repo); summary != nil {
+		return strings.SplitN(summary.Request.Description, "\n", 2)[0]
+	}
+	topics := discussion.ParseAllValid(unionNotes(repo.GetNotes(discussion.Ref, q.Revision), repo.GetNotes(QuarantineRef(q.Remote, discussion.Ref), q.Revision)))
+	if len(topics) == 0 {
+		return ""
+	}
+	sort.Stable(discussionsByTimestamp(topics))
+	return topics[len(topics)-1].Title
+}
+
+// Verify returns the reason that the quarantined notes could not be verified,
+// or nil if they can now be verified.
+func (q QuarantinedReview) Verify(repo repository.Repo) error {
+	return verifyNotes(repo, func(ref string) string {
+		return QuarantineRef(q.Remote, ref)
+	}, q.Revision)
+}
+
+// Accept merges the quarantined notes into the local notes, and removes them
+// from quarantine.
+func (q QuarantinedReview) Accept(repo repository.Repo) error {
+	for _, ref := range quarantinedRefs {
+		local := repo.GetNotes(ref, q.Revision)
+		existing := make(map[string]bool)
+		for _, note := range local {
+			existing[string(note)] = true
+		}
+		for _, note := range unionNotes(nil, repo.GetNotes(QuarantineRef(q.Remote, ref), q.Revision)) {
+			if existing[string(note)] {
+				continue
+			}
+			if err := repo.AppendNote(ref, q.Revision, note); err != nil {
+				return err
+			}
+		}
+	}
+	return q.Drop(repo)
+}
+
+// Drop discards the quarantined notes.
+func (q QuarantinedReview) Drop(repo repository.Repo) error {
+	for _, ref := range quarantinedRefs {
+		if err := repo.SetNotes(QuarantineRef(q.Remote, ref), q.Revision, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package query defines a small query language for filtering code reviews.
+//
+// A query is a whitespace separated list of terms, each of which is either
+// a bare word (matched against the text of the review), or a "key:value"
+// pair. Values containing whitespace may be quoted with double quotes, and a
+// term may be negated by prefixing it with a '-'. For example:
+//
+//	status:open reviewer:me target:release-2 has:unresolved text:"flaky test"
+//
+// All of the terms must match for a review to match the query.
+//
+// Users given with an "@" (e.g. "reviewer:bob@example.com") must match the
+// whole email, once both are canonicalized, while users given without one
+// (e.g. "reviewer:bob") match any email containing them.
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+const (
+	// Me is the value that can be used in place of the current user's email.
+	Me = "me"
+
+	// DateFormat is the format expected for the values of dates in queries.
+	DateFormat = "2006-01-02"
+)
+
+// Keys that can be used in query terms.
+const (
+	KeyStatus    = "status"
+	KeyReviewer  = "reviewer"
+	KeyRequester = "requester"
+	KeyTarget    = "target"
+	KeySource    = "source"
+	KeyHas       = "has"
+	KeyText      = "text"
+	KeyAfter     = "after"
+	KeyBefore    = "before"
+	KeySort      = "sort"
+	KeyLimit     = "limit"
+)
+
+// Values accepted for the "sort" key.
+const (
+	SortNewest    = "newest"
+	SortOldest    = "oldest"
+	SortUpdated   = "updated"
+	SortTarget    = "target"
+	SortRequester = "requester"
+)
+
+var statuses = map[string]func(r *review.Summary) bool{
+	"open":      func(r *review.Summary) bool { return r.IsOpen() },
+	"pending":   func(r *review.Summary) bool { return r.IsOpen() && r.Resolved == nil },
+	"accepted":  func(r *review.Summary) bool { return r.IsOpen() && r.Resolved != nil && *r.Resolved },
+	"rejected":  func(r *review.Summary) bool { return r.IsOpen() && r.Resolved != nil && !*r.Resolved },
+	"submitted": func(r *review.Summary) bool { return r.Submitted },
+	"abandoned": func(r *review.Summary) bool { return r.IsAbandoned() },
+	"closed":    func(r *review.Summary) bool { return !r.IsOpen() },
+	"all":       func(r *review.Summary) bool { return true },
+}
+
+var properties = map[string]func(r *review.Summary) bool{
+	"unresolved": func(r *review.Summary) bool {
+		for _, thread := range r.Comments {
+			if thread.Resolved != nil && !*thread.Resolved {
+				return true
+			}
+		}
+		return false
+	},
+	"comments":     func(r *review.Summary) bool { return len(r.Comments) > 0 },
+	"reviewers":    func(r *review.Summary) bool { return len(r.Request.Reviewers) > 0 },
+	"dependencies": func(r *review.Summary) bool { return len(r.Request.DependsOn) > 0 },
+}
+
+var sorts = map[string]func(a, b *review.Summary) bool{
+	SortNewest: func(a, b *review.Summary) bool { return a.Request.Timestamp > b.Request.Timestamp },
+	SortOldest: func(a, b *review.Summary) bool { return a.Request.Timestamp < b.Request.Timestamp },
+	SortUpdated: func(a, b *review.Summary) bool {
+		return lastUpdated(a) > lastUpdated(b)
+	},
+	SortTarget:    func(a, b *review.Summary) bool { return a.Request.TargetRef < b.Request.TargetRef },
+	SortRequester: func(a, b *review.Summary) bool { return a.Request.Requester < b.Request.Requester },
+}
+
+// Term represents a single "key:value" term in a query.
+type Term struct {
+	Key     string
+	Value   string
+	Negated bool
+
+	matches func(r *review.Summary) bool
+}
+
+// Query represents a parsed query.
+type Query struct {
+	Terms []Term
+	Sort  string
+	Limit int
+}
+
+// Parse parses the given query text.
+//
+// The 'userEmail' argument is used for the value of the special "me" user,
+// and the 'people' argument to canonicalize the emails of users.
+func Parse(text string, userEmail string, people *identity.Registry) (*Query, error) {
+	words, err := split(text)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTerms(words, userEmail, people)
+}
+
+// ParseTerms parses a query made up of the given terms, e.g. the command
+// line arguments of a command, each of which is a single term even if it
+// contains whitespace.
+func ParseTerms(words []string, userEmail string, people *identity.Registry) (*Query, error) {
+	q := &Query{Sort: SortNewest}
+	for _, word := range words {
+		negated := false
+		if strings.HasPrefix(word, "-") && len(word) > 1 {
+			negated = true
+			word = word[1:]
+		}
+		key, value, found := strings.Cut(word, ":")
+		if !found || !isKey(key) {
+			key, value = KeyText, word
+		}
+		value = unquote(value)
+		switch key {
+		case KeySort:
+			if _, ok := sorts[value]; !ok {
+				return nil, fmt.Errorf("Unknown sort order %q", value)
+			}
+			q.Sort = value
+			continue
+		case KeyLimit:
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return nil, fmt.Errorf("Invalid limit %q", value)
+			}
+			q.Limit = limit
+			continue
+		}
+		matches, err := newMatcher(key, value, userEmail, people)
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, Term{
+			Key:     key,
+			Value:   value,
+			Negated: negated,
+			matches: matches,
+		})
+	}
+	return q, nil
+}
+
+// Has returns whether or not the query has a term with the given key.
+func (q *Query) Has(key string) bool {
+	for _, term := range q.Terms {
+		if term.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether or not the given review matches every term of the query.
+func (q *Query) Matches(r *review.Summary) bool {
+	for _, term := range q.Terms {
+		if term.matches(r) == term.Negated {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the reviews matching the query, sorted and limited as specified by it.
+func (q *Query) Filter(reviews []review.Summary) []review.Summary {
+	var results []review.Summary
+	for i := range reviews {
+		if q.Matches(&reviews[i]) {
+			results = append(results, reviews[i])
+		}
+	}
+	less := sorts[q.Sort]
+	if less == nil {
+		less = sorts[SortNewest]
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return less(&results[i], &results[j])
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+func isKey(key string) bool {
+	switch key {
+	case KeyStatus, KeyReviewer, KeyRequester, KeyTarget, KeySource, KeyHas,
+		KeyText, KeyAfter, KeyBefore, KeySort, KeyLimit:
+		return true
+	}
+	return false
+}
+
+func newMatcher(key, value, userEmail string, people *identity.Registry) (func(r *review.Summary) bool, error) {
+	switch key {
+	case KeyStatus:
+		matches, ok := statuses[value]
+		if !ok {
+			return nil, fmt.Errorf("Unknown review status %q", value)
+		}
+		return matches, nil
+	case KeyHas:
+		matches, ok := properties[value]
+		if !ok {
+			return nil, fmt.Errorf("Unknown review property %q", value)
+		}
+		return matches, nil
+	case KeyReviewer:
+		user := resolveUser(value, userEmail)
+		return func(r *review.Summary) bool {
+			for _, reviewer := range r.Request.Reviewers {
+				if matchesUser(people, reviewer, user) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case KeyRequester:
+		user := resolveUser(value, userEmail)
+		return func(r *review.Summary) bool {
+			return matchesUser(people, r.Request.Requester, user)
+		}, nil
+	case KeyTarget:
+		return func(r *review.Summary) bool {
+			return matchesRef(r.Request.TargetRef, value)
+		}, nil
+	case KeySource:
+		return func(r *review.Summary) bool {
+			return matchesRef(r.Request.ReviewRef, value)
+		}, nil
+	case KeyText:
+		text := strings.ToLower(value)
+		return func(r *review.Summary) bool {
+			return containsText(r, text)
+		}, nil
+	case KeyAfter, KeyBefore:
+		date, err := time.Parse(DateFormat, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid date %q; expected the format %q", value, DateFormat)
+		}
+		if key == KeyAfter {
+			return func(r *review.Summary) bool {
+				return requestTime(r) >= date.Unix()
+			}, nil
+		}
+		return func(r *review.Summary) bool {
+			return requestTime(r) < date.Unix()
+		}, nil
+	}
+	return nil, fmt.Errorf("Unknown query key %q", key)
+}
+
+func resolveUser(value, userEmail string) string {
+	if value == Me {
+		return userEmail
+	}
+	return value
+}
+
+// matchesUser returns whether or not the given user matches the user in a
+// query, which is either a whole email or, without an "@", part of one.
+func matchesUser(people *identity.Registry, user, queryUser string) bool {
+	if queryUser == "" {
+		return false
+	}
+	if strings.Contains(queryUser, "@") {
+		return strings.EqualFold(people.Canonical(user), people.Canonical(queryUser))
+	}
+	return strings.Contains(strings.ToLower(user), strings.ToLower(queryUser))
+}
+
+// matchesRef returns whether or not the given ref matches the (possibly abbreviated) ref in a query.
+func matchesRef(ref, queryRef string) bool {
+	return ref == queryRef || ref == "refs/heads/"+queryRef
+}
+
+func containsText(r *review.Summary, text string) bool {
+	if strings.Contains(strings.ToLower(r.Request.Description), text) {
+		return true
+	}
+	return threadsContainText(r.Comments, text)
+}
+
+func threadsContainText(threads []review.CommentThread, text string) bool {
+	for _, thread := range threads {
+		if strings.Contains(strings.ToLower(thread.Comment.Description), text) {
+			return true
+		}
+		if threadsContainText(thread.Children, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func requestTime(r *review.Summary) int64 {
+	timestamp, err := strconv.ParseInt(r.Request.Timestamp, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return timestamp
+}
+
+// lastUpdated returns the timestamp of the latest activity on a review.
+func lastUpdated(r *review.Summary) string {
+	latest := r.Request.LastModified()
+	var visit func(threads []review.CommentThread)
+	visit = func(threads []review.CommentThread) {
+		for _, thread := range threads {
+			if thread.Comment.Timestamp > latest {
+				latest = thread.Comment.Timestamp
+			}
+			visit(thread.Children)
+		}
+	}
+	visit(r.Comments)
+	return latest
+}
+
+// split splits the query text into words, keeping quoted strings together.
+func split(text string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	inQuotes := false
+	for _, c := range text {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			inWord = true
+			word.WriteRune(c)
+		case unicode.IsSpace(c) && !inQuotes:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("Unterminated quote in query %q", text)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func unquote(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+func sampleReviews() []review.Summary {
+	rejected := false
+	return []review.Summary{
+		review.Summary{
+			Revision: "A",
+			Request: request.Request{
+				Timestamp:   "1767225600", // 2026-01-01
+				Requester:   "alice@example.com",
+				Reviewers:   []string{"bob@example.com"},
+				TargetRef:   "refs/heads/release-2",
+				Description: "Fix the flaky test",
+			},
+			Comments: []review.CommentThread{
+				review.CommentThread{
+					Comment:  comment.Comment{Timestamp: "1767225700", Description: "Still failing"},
+					Resolved: &rejected,
+				},
+			},
+		},
+		review.Summary{
+			Revision: "B",
+			Request: request.Request{
+				Timestamp:   "1767139200", // 2025-12-31
+				Requester:   "bob@example.com",
+				Reviewers:   []string{"alice@example.com"},
+				TargetRef:   "refs/heads/master",
+				Description: "Add a feature",
+			},
+		},
+		review.Summary{
+			Revision: "C",
+			Request: request.Request{
+				Timestamp:   "1767312000", // 2026-01-02
+				Requester:   "carol@example.com",
+				Reviewers:   []string{"bob@example.com"},
+				TargetRef:   "refs/heads/release-2",
+				Description: "Another flaky fix",
+			},
+			Submitted: true,
+		},
+	}
+}
+
+func filterRevisions(t *testing.T, text string) []string {
+	q, err := Parse(text, "bob@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revisions []string
+	for _, r := range q.Filter(sampleReviews()) {
+		revisions = append(revisions, r.Revision)
+	}
+	return revisions
+}
+
+func checkRevisions(t *testing.T, text string, expected ...string) {
+	revisions := filterRevisions(t, text)
+	if len(revisions) != len(expected) {
+		t.Fatalf("Unexpected results for the query %q: %v", text, revisions)
+	}
+	for i := range expected {
+		if revisions[i] != expected[i] {
+			t.Fatalf("Unexpected results for the query %q: %v", text, revisions)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	checkRevisions(t, "", "C", "A", "B")
+	checkRevisions(t, "status:open", "A", "B")
+	checkRevisions(t, "status:open reviewer:me", "A")
+	checkRevisions(t, "-requester:me", "C", "A")
+	checkRevisions(t, "target:release-2 sort:oldest", "A", "C")
+	checkRevisions(t, "has:unresolved", "A")
+	checkRevisions(t, `text:"flaky test"`, "A")
+	checkRevisions(t, "flaky limit:1", "C")
+	checkRevisions(t, "failing", "A")
+	checkRevisions(t, "after:2026-01-01 before:2026-01-02", "A")
+}
+
+func TestUserTerms(t *testing.T) {
+	reviews := sampleReviews()
+	reviews[1].Request.Reviewers = []string{"jimbob@example.com"}
+	people := identity.New()
+	people.AddMailmap("Alice <alice@example.com> <alice@laptop>\n")
+	for text, expected := range map[string][]string{
+		"reviewer:bob@example.com":    {"C", "A"},
+		"reviewer:BOB@example.com":    {"C", "A"},
+		"reviewer:bob":                {"C", "A", "B"},
+		"requester:alice@laptop":      {"A"},
+		"requester:ALICE@example.com": {"A"},
+	} {
+		q, err := Parse(text, "", people)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var revisions []string
+		for _, r := range q.Filter(reviews) {
+			revisions = append(revisions, r.Revision)
+		}
+		if !reflect.DeepEqual(revisions, expected) {
+			t.Errorf("Unexpected results for the query %q: %v", text, revisions)
+		}
+	}
+}
+
+func TestParseTerms(t *testing.T) {
+	q, err := ParseTerms([]string{"text:flaky test", "-status:closed"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revisions []string
+	for _, r := range q.Filter(sampleReviews()) {
+		revisions = append(revisions, r.Revision)
+	}
+	if !reflect.DeepEqual(revisions, []string{"A"}) {
+		t.Errorf("Unexpected results for separate query terms: %v", revisions)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{
+		"status:unknown",
+		"has:unknown",
+		"sort:unknown",
+		"limit:many",
+		"after:yesterday",
+		`text:"unterminated`,
+	} {
+		if _, err := Parse(text, "", nil); err == nil {
+			t.Errorf("Expected an error parsing the query %q", text)
+		}
+	}
+}
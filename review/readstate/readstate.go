@@ -0,0 +1,181 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package readstate tracks which review activity each user has already seen.
+//
+// The read state is purely local, and is stored in the repository's data
+// directory rather than in git-notes, so it is never pushed or pulled.
+package readstate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review"
+)
+
+// fileName is the name of the read state file within the repository's data directory.
+const fileName = "appraise-read-state.json"
+
+// Store holds, for each user, the timestamp of the latest activity they have
+// seen on each review.
+type Store struct {
+	path  string
+	mutex sync.Mutex
+	// Users maps from user email, to review revision, to timestamp.
+	Users map[string]map[string]string `json:"users"`
+}
+
+// Load reads the read state for the given repository.
+//
+// A missing read state file is treated as if nothing had been read yet.
+func Load(repo repository.Repo) (*Store, error) {
+	dataDir, err := repo.GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(repo.GetPath(), dataDir)
+	}
+	s := &Store{
+		path:  filepath.Join(dataDir, fileName),
+		Users: make(map[string]map[string]string),
+	}
+	contents, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, s); err != nil {
+		return nil, err
+	}
+	if s.Users == nil {
+		s.Users = make(map[string]map[string]string)
+	}
+	return s, nil
+}
+
+// Save writes the read state back to the repository's data directory.
+func (s *Store) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// LastRead returns the timestamp of the latest activity on the review seen
+// by the user, or the empty string if they have not seen the review.
+func (s *Store) LastRead(user, revision string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Users[user][revision]
+}
+
+// MarkRead records that the user has seen all of the current activity on the review.
+func (s *Store) MarkRead(user string, r *review.Summary) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.Users[user] == nil {
+		s.Users[user] = make(map[string]string)
+	}
+	s.Users[user][r.Revision] = LatestActivity(r)
+}
+
+// MarkUnread forgets that the user has seen any of the activity on the review.
+func (s *Store) MarkUnread(user, revision string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.Users[user], revision)
+}
+
+// HasUnread returns whether or not the review has any activity by other
+// users that the given user has not yet seen.
+func (s *Store) HasUnread(user string, r *review.Summary) bool {
+	lastRead := s.LastRead(user, r.Revision)
+	for _, req := range r.AllRequests {
+		if req.Requester != user && isAfter(req.LastModified(), lastRead) {
+			return true
+		}
+	}
+	return len(unreadThreads(r.Comments, user, lastRead, make(map[string]bool))) > 0
+}
+
+// UnreadThreads returns the hashes of the comment threads on the review
+// whose root comment, by another user, has not yet been seen by the given user.
+func (s *Store) UnreadThreads(user string, r *review.Summary) map[string]bool {
+	return unreadThreads(r.Comments, user, s.LastRead(user, r.Revision), make(map[string]bool))
+}
+
+func unreadThreads(threads []review.CommentThread, user, lastRead string, unread map[string]bool) map[string]bool {
+	for _, thread := range threads {
+		if thread.Comment.Author != user && isAfter(thread.Comment.Timestamp, lastRead) {
+			unread[thread.Hash] = true
+		}
+		unreadThreads(thread.Children, user, lastRead, unread)
+	}
+	return unread
+}
+
+// LatestActivity returns the timestamp of the latest request or comment on the review.
+func LatestActivity(r *review.Summary) string {
+	latest := r.Request.LastModified()
+	for _, req := range r.AllRequests {
+		if isAfter(req.LastModified(), latest) {
+			latest = req.LastModified()
+		}
+	}
+	var visit func(threads []review.CommentThread)
+	visit = func(threads []review.CommentThread) {
+		for _, thread := range threads {
+			if isAfter(thread.Comment.Timestamp, latest) {
+				latest = thread.Comment.Timestamp
+			}
+			visit(thread.Children)
+		}
+	}
+	visit(r.Comments)
+	return latest
+}
+
+// isAfter returns whether or not the first timestamp is strictly after the
+// second one, with the empty timestamp being before all others.
+func isAfter(timestamp, other string) bool {
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if other == "" {
+		return true
+	}
+	o, err := strconv.ParseInt(other, 10, 64)
+	if err != nil {
+		return true
+	}
+	return t > o
+}
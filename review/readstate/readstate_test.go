@@ -0,0 +1,83 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readstate
+
+import (
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+const me = "me@example.com"
+
+func TestUnread(t *testing.T) {
+	r := &review.Summary{
+		Revision:    "A",
+		Request:     request.Request{Requester: me, Timestamp: "0000000010"},
+		AllRequests: []request.Request{request.Request{Requester: me, Timestamp: "0000000010"}},
+		Comments: []review.CommentThread{
+			review.CommentThread{
+				Hash:    "mine",
+				Comment: comment.Comment{Author: me, Timestamp: "0000000020"},
+			},
+			review.CommentThread{
+				Hash:    "theirs",
+				Comment: comment.Comment{Author: "bob@example.com", Timestamp: "0000000030"},
+			},
+		},
+	}
+	s := &Store{Users: make(map[string]map[string]string)}
+	if !s.HasUnread(me, r) {
+		t.Errorf("Expected the review to have unread activity")
+	}
+	if unread := s.UnreadThreads(me, r); len(unread) != 1 || !unread["theirs"] {
+		t.Errorf("Unexpected unread threads: %v", unread)
+	}
+
+	s.MarkRead(me, r)
+	if s.HasUnread(me, r) {
+		t.Errorf("Expected the review to have no unread activity after marking it as read")
+	}
+	if !s.HasUnread("bob@example.com", r) {
+		t.Errorf("Marking a review as read should not affect other users")
+	}
+
+	r.Comments = append(r.Comments, review.CommentThread{
+		Hash:    "reply",
+		Comment: comment.Comment{Author: "bob@example.com", Timestamp: "0000000040"},
+	})
+	if unread := s.UnreadThreads(me, r); len(unread) != 1 || !unread["reply"] {
+		t.Errorf("Unexpected unread threads after a new comment: %v", unread)
+	}
+
+	s.MarkUnread(me, r.Revision)
+	if unread := s.UnreadThreads(me, r); len(unread) != 2 {
+		t.Errorf("Unexpected unread threads after marking as unread: %v", unread)
+	}
+
+	// Rewriting the request (e.g. rebasing the review) keeps its timestamp,
+	// but is still activity that other users have not seen.
+	s.MarkRead("bob@example.com", r)
+	rebased := request.Request{Requester: me, Timestamp: "0000000010", Modified: "0000000050", Alias: "B"}
+	r.Request = rebased
+	r.AllRequests = append(r.AllRequests, rebased)
+	if !s.HasUnread("bob@example.com", r) {
+		t.Errorf("Expected the rebased review to have unread activity")
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// rebaseStateFile is the name of the file, within the repository's data
+// directory, that records a rebase of a review which has not yet finished.
+const rebaseStateFile = "appraise-rebase-state.json"
+
+var (
+	// ErrRebaseStopped is returned when rebasing a review stops before
+	// completing, e.g. because of conflicts.
+	ErrRebaseStopped = errors.New("The rebase of the review stopped before completing.\n" +
+		"Resolve any conflicts, then run \"git appraise rebase -continue\" to finish it,\n" +
+		"or run \"git appraise rebase -abort\" to give up on it.")
+
+	// ErrRebaseInProgress is returned when trying to start a rebase of a
+	// review while a previous one has not yet finished.
+	ErrRebaseInProgress = errors.New("A rebase of a review is already in progress.\n" +
+		"Run \"git appraise rebase -continue\" or \"git appraise rebase -abort\" first.")
+
+	// ErrNoRebaseInProgress is returned when trying to continue or abort a
+	// rebase of a review when there is none.
+	ErrNoRebaseInProgress = errors.New("There is no rebase of a review in progress.")
+
+	// ErrRebaseNotFinished is returned when continuing a rebase of a review
+	// whose underlying git rebase is no longer in progress, but did not
+	// finish rebasing the review, e.g. because it was aborted.
+	ErrRebaseNotFinished = errors.New("The rebase of the review was not finished, e.g. because it was aborted\n" +
+		"or the review ref is no longer checked out.\n" +
+		"Run \"git appraise rebase -abort\" to give up on it.")
+)
+
+// RebaseOptions controls how a review is rebased onto its target ref.
+type RebaseOptions struct {
+	// Archive causes the previous head of the review to be added to the
+	// archive ref, so that it is not garbage collected.
+	Archive bool `json:"archive,omitempty"`
+	// Sign causes both the rebased commits and the updated request to be signed.
+	Sign bool `json:"sign,omitempty"`
+	// Interactive causes the user to be prompted to edit the list of
+	// commits being rebased.
+	Interactive bool `json:"interactive,omitempty"`
+}
+
+// rebaseState records a rebase of a review that stopped before completing.
+type rebaseState struct {
+	Revision     string `json:"revision"`
+	ReviewRef    string `json:"reviewRef"`
+	OriginalHead string `json:"originalHead"`
+	RebaseOptions
+}
+
+func rebaseStatePath(repo repository.Repo) (string, error) {
+	dataDir, err := repo.GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(repo.GetPath(), dataDir)
+	}
+	return filepath.Join(dataDir, rebaseStateFile), nil
+}
+
+// loadRebaseState reads the state of the unfinished rebase, if any.
+func loadRebaseState(repo repository.Repo) (*rebaseState, error) {
+	path, err := rebaseStatePath(repo)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var state rebaseState
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func saveRebaseState(repo repository.Repo, state *rebaseState) error {
+	path, err := rebaseStatePath(repo)
+	if err != nil {
+		return err
+	}
+	contents, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, contents, 0644)
+}
+
+func removeRebaseState(repo repository.Repo) error {
+	path, err := rebaseStatePath(repo)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// IsRebaseInProgress returns whether or not there is a rebase of a review
+// that has been started but not yet continued or aborted.
+func IsRebaseInProgress(repo repository.Repo) (bool, error) {
+	state, err := loadRebaseState(repo)
+	return state != nil, err
+}
+
+// RebaseWithOptions rebases the review onto its target ref.
+//
+// If the rebase stops before completing, e.g. because of conflicts, then
+// the state of the rebase is recorded and ErrRebaseStopped is returned.
+// The rebase can then be finished using ContinueRebase, or given up on
+// using AbortRebase.
+func (r *Review) RebaseWithOptions(opts RebaseOptions) error {
+	if inProgress, err := IsRebaseInProgress(r.Repo); err != nil {
+		return err
+	} else if inProgress {
+		return ErrRebaseInProgress
+	}
+	orig, err := r.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+	if err := r.Repo.SwitchToRef(r.Request.ReviewRef); err != nil {
+		return err
+	}
+
+	switch {
+	case !opts.Interactive:
+		err = r.Repo.RebaseRefNonInteractive(r.Request.TargetRef, opts.Sign)
+	case opts.Sign:
+		err = r.Repo.RebaseAndSignRef(r.Request.TargetRef)
+	default:
+		err = r.Repo.RebaseRef(r.Request.TargetRef)
+	}
+	// An interactive rebase can stop without failing, e.g. to edit a commit.
+	stopped, stateErr := r.Repo.IsRebaseInProgress()
+	if stateErr != nil {
+		return stateErr
+	}
+	if stopped {
+		state := &rebaseState{
+			Revision:      r.Revision,
+			ReviewRef:     r.Request.ReviewRef,
+			OriginalHead:  orig,
+			RebaseOptions: opts,
+		}
+		if err := saveRebaseState(r.Repo, state); err != nil {
+			return err
+		}
+		return ErrRebaseStopped
+	}
+	if err != nil {
+		return err
+	}
+	return r.finishRebase(orig, opts)
+}
+
+// finishRebase records the rebased head of the review as its alias.
+//
+// The rebased head is read from HEAD, rather than from the review ref, as
+// review refs which are not branches leave the rebase in a detached HEAD state.
+func (r *Review) finishRebase(orig string, opts RebaseOptions) error {
+	alias, err := r.Repo.GetCommitHash("HEAD")
+	if err != nil {
+		return err
+	}
+	base, err := r.Repo.GetCommitHash(r.Request.TargetRef)
+	if err != nil {
+		return err
+	}
+	return r.Rewrite(orig, base, alias, opts)
+}
+
+// Rewrite records that the commits of the review, which previously ended
+// with the 'previous' commit, have been rewritten to end with the 'alias'
+// commit, on top of the 'base' commit.
+//
+// The base replaces the one recorded when the review was requested, so that
+// GetBaseCommit returns it once the review is closed; the original base is
+// not an ancestor of the rewritten commits.
+//
+// The Archive and Sign options control whether or not the previous head of
+// the review is archived, and the updated request signed, respectively.
+func (r *Review) Rewrite(previous, base, alias string, opts RebaseOptions) error {
+	if opts.Archive {
+		if err := r.Repo.ArchiveRef(previous, archiveRef); err != nil {
+			return err
+		}
+	}
+	r.Request.BaseCommit = base
+	r.Request.Alias = alias
+	r.Request.Modified = currentTimestamp()
+	if opts.Sign {
+		signing, err := gpg.LoadSigningConfig(r.Repo)
+		if err != nil {
+			return err
+		}
+		if err := signing.Sign(&r.Request); err != nil {
+			return err
+		}
+	}
+	newNote, err := r.Request.Write()
+	if err != nil {
+		return err
+	}
+	return r.Repo.AppendNote(request.Ref, r.Revision, newNote)
+}
+
+// isRebaseFinished returns whether or not HEAD holds the result of the
+// recorded rebase of the review: the review ref is still checked out, and
+// HEAD has moved from the original head of the review onto the target ref.
+func (r *Review) isRebaseFinished(state *rebaseState) (bool, error) {
+	reviewRef := state.ReviewRef
+	if reviewRef == "" {
+		reviewRef = r.Request.ReviewRef
+	}
+	// Rebasing review refs which are not branches leaves a detached HEAD.
+	if strings.HasPrefix(reviewRef, "refs/heads/") {
+		if headRef, err := r.Repo.GetHeadRef(); err != nil || headRef != reviewRef {
+			return false, nil
+		}
+	}
+	head, err := r.Repo.GetCommitHash("HEAD")
+	if err != nil {
+		return false, err
+	}
+	if head == state.OriginalHead {
+		return false, nil
+	}
+	return r.Repo.IsAncestor(r.Request.TargetRef, head)
+}
+
+// ContinueRebase finishes a rebase of a review that previously stopped.
+//
+// The underlying git rebase is continued if the user has not already done
+// so, after which the review is updated exactly as if the rebase had
+// completed without stopping. If the user instead aborted the git rebase,
+// or switched away from the review ref, then ErrRebaseNotFinished is returned.
+func ContinueRebase(repo repository.Repo) error {
+	state, err := loadRebaseState(repo)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return ErrNoRebaseInProgress
+	}
+	if inProgress, err := repo.IsRebaseInProgress(); err != nil {
+		return err
+	} else if inProgress {
+		err := repo.ContinueRebase()
+		if stopped, stateErr := repo.IsRebaseInProgress(); stateErr != nil {
+			return stateErr
+		} else if stopped {
+			return ErrRebaseStopped
+		}
+		if err != nil {
+			return err
+		}
+	}
+	r, err := Get(repo, state.Revision)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return errors.New("The review being rebased no longer exists.")
+	}
+	if finished, err := r.isRebaseFinished(state); err != nil {
+		return err
+	} else if !finished {
+		return ErrRebaseNotFinished
+	}
+	if err := r.finishRebase(state.OriginalHead, state.RebaseOptions); err != nil {
+		return err
+	}
+	return removeRebaseState(repo)
+}
+
+// AbortRebase gives up on a rebase of a review that previously stopped,
+// leaving both the review and its commits as they were before the rebase.
+func AbortRebase(repo repository.Repo) error {
+	state, err := loadRebaseState(repo)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return ErrNoRebaseInProgress
+	}
+	if inProgress, err := repo.IsRebaseInProgress(); err != nil {
+		return err
+	} else if inProgress {
+		if err := repo.AbortRebase(); err != nil {
+			return err
+		}
+	}
+	return removeRebaseState(repo)
+}
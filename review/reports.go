@@ -0,0 +1,92 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"sort"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/ci"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+)
+
+// CIReport is a CI report along with the result of checking its signature.
+type CIReport struct {
+	Report ci.Report `json:"report"`
+	// Signature is the result of checking the signature on the report; it
+	// is only set if the signatures have been checked.
+	Signature *gpg.Result `json:"signature,omitempty"`
+}
+
+// AddCIReport records the given CI report for the given commit.
+func AddCIReport(repo repository.Repo, commit string, report ci.Report) error {
+	if err := report.Validate(); err != nil {
+		return err
+	}
+	note, err := report.Write()
+	if err != nil {
+		return err
+	}
+	return repo.AppendNote(ci.Ref, commit, note)
+}
+
+// GetCIReports returns the CI reports for the given commit, sorted by their
+// timestamps, with the signature on each of them checked.
+//
+// The signature on a report is checked against the keys of its agent, as
+// recorded in the people file on the trusted ref.
+func GetCIReports(repo repository.Repo, commit string) ([]CIReport, error) {
+	people, err := identity.LoadTrusted(repo)
+	if err != nil {
+		return nil, err
+	}
+	v := gpg.LoadConfig(repo)
+	reports := ci.ParseAllValid(repo.GetNotes(ci.Ref, commit))
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Timestamp < reports[j].Timestamp
+	})
+	var results []CIReport
+	for i := range reports {
+		report := &reports[i]
+		results = append(results, CIReport{
+			Report:    *report,
+			Signature: gpg.Check(v, people, report.Agent, report),
+		})
+	}
+	return results, nil
+}
+
+// GetCIMatrix returns the latest CI report from each agent for the head of
+// the review, sorted by agent.
+func (r *Review) GetCIMatrix() ([]ci.Report, error) {
+	return ci.GetLatestReportPerAgent(r.Reports)
+}
+
+// GetCIStatus returns the aggregate status of the latest CI reports from each
+// agent for the head of the review, or the empty string if it is unknown.
+func (r *Summary) GetCIStatus() string {
+	details, err := r.Details()
+	if err != nil {
+		return ""
+	}
+	matrix, err := details.GetCIMatrix()
+	if err != nil {
+		return ""
+	}
+	return ci.AggregateStatus(matrix)
+}
@@ -45,14 +45,29 @@ type Request struct {
 	Description string   `json:"description,omitempty"`
 	// Version represents the version of the metadata format.
 	Version int `json:"v,omitempty"`
-	// BaseCommit stores the commit ID of the target ref at the time the review was requested.
-	// This is optional, and only used for submitted reviews which were anchored at a merge commit.
+	// BaseCommit stores the commit ID of the target ref at the time the review was requested,
+	// or at the time its commits were last rebased or otherwise rewritten on top of it.
+	// This is optional, and only used for submitted reviews which were anchored at a merge commit,
+	// or whose commits were rewritten.
 	// This allows someone viewing that submitted review to find the diff against which the
 	// code was reviewed.
 	BaseCommit string `json:"baseCommit,omitempty"`
 	// Alias stores a post-rebase commit ID for the review. This allows the tool
 	// to track the history of a review even if the commit history changes.
 	Alias string `json:"alias,omitempty"`
+	// DependsOn stores the revisions of other reviews that this review is
+	// stacked on top of. The review should not be submitted until all of
+	// them have been, and its diff is computed relative to the head of the
+	// first one that is still open.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Reverts stores the revision of a submitted review whose changes this
+	// review reverts.
+	Reverts string `json:"reverts,omitempty"`
+	// Modified records when an existing request was rewritten (e.g. when the
+	// review was abandoned, reopened, or rebased), while Timestamp is kept
+	// as it was, so that the review keeps its place when reviews are ordered
+	// by the time they were requested.
+	Modified string `json:"modified,omitempty"`
 
 	gpg.Sig
 }
@@ -70,6 +85,14 @@ func New(requester string, reviewers []string, reviewRef, targetRef, description
 	}
 }
 
+// LastModified returns the time at which the request was last written.
+func (request *Request) LastModified() string {
+	if request.Modified != "" {
+		return request.Modified
+	}
+	return request.Timestamp
+}
+
 // Parse parses a review request from a git note.
 func Parse(note repository.Note) (Request, error) {
 	bytes := []byte(note)
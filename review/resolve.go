@@ -0,0 +1,215 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// minimumHashPrefixLength is the shortest abbreviated review hash that is
+// accepted; this matches the shortest abbreviation accepted by git.
+const minimumHashPrefixLength = 4
+
+// numberReviews assigns each of the given reviews its numeric alias.
+//
+// Reviews are numbered from 1 in the order in which they were first
+// requested, so the number of a review does not change as it is updated.
+// It does change, however, when reviews that were requested before it are
+// fetched from a remote, so numbers are only suitable for interactive use.
+func numberReviews(reviews []Summary) {
+	byFirstRequest := make([]*Summary, len(reviews))
+	for i := range reviews {
+		byFirstRequest[i] = &reviews[i]
+	}
+	sort.SliceStable(byFirstRequest, func(i, j int) bool {
+		first, second := byFirstRequest[i], byFirstRequest[j]
+		if first.AllRequests[0].Timestamp != second.AllRequests[0].Timestamp {
+			return first.AllRequests[0].Timestamp < second.AllRequests[0].Timestamp
+		}
+		return first.Revision < second.Revision
+	})
+	for i, r := range byFirstRequest {
+		r.Number = i + 1
+	}
+}
+
+func isHash(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'f') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// resolveDirectly resolves the given name without listing every review.
+//
+// The name is first looked up as a commit with a review request, and then
+// as the review ref of an open review, or the rebased head of a review, for
+// which only the review requests are read.
+//
+// It returns the revision of the review if exactly one matches. Otherwise,
+// it returns whether or not the name might match more than one review, in
+// which case every review has to be listed to tell them apart.
+func resolveDirectly(repo repository.Repo, name string) (revision string, ambiguous bool) {
+	commit, err := repo.GetCommitHash(name)
+	if err == nil && request.ParseAllValid(repo.GetNotes(request.Ref, commit)) != nil {
+		return commit, false
+	}
+	if err != nil && len(name) >= minimumHashPrefixLength && isHash(name) {
+		// The name may be a prefix of the hashes of several reviews.
+		return "", true
+	}
+	ref, _ := repo.GetRefName(name)
+	if commit == "" && ref == "" {
+		return "", false
+	}
+	notesMap, err := repo.GetAllNotes(request.Ref)
+	if err != nil {
+		return "", false
+	}
+	var refMatches, aliasMatches []string
+	for candidate, notes := range notesMap {
+		requests := request.ParseAllValid(notes)
+		if requests == nil {
+			continue
+		}
+		sort.Stable(requestsByTimestamp(requests))
+		latest := requests[len(requests)-1]
+		if ref != "" && latest.ReviewRef == ref && latest.TargetRef != "" {
+			head := candidate
+			if latest.Alias != "" {
+				head = latest.Alias
+			}
+			if submitted, err := repo.IsAncestor(head, latest.TargetRef); err == nil && !submitted {
+				refMatches = append(refMatches, candidate)
+			}
+		}
+		if commit != "" && latest.Alias == commit {
+			aliasMatches = append(aliasMatches, candidate)
+		}
+	}
+	matches := refMatches
+	if len(matches) == 0 {
+		matches = aliasMatches
+	}
+	if len(matches) == 1 {
+		return matches[0], false
+	}
+	return "", len(matches) > 1
+}
+
+// Resolve returns the full revision of the review identified by the given name.
+//
+// The name can be any of the following:
+//  1. The revision of the review, or an unambiguous prefix of it.
+//  2. The numeric alias of the review shown by "list", optionally prefixed
+//     with '#'.
+//  3. A ref, such as "my-branch", "HEAD", or "@{-1}", that is the review ref
+//     of exactly one open review.
+//  4. Any other name for the revision, or for the current head, of the review.
+//
+// Every review is only listed for numeric aliases, and for names that might
+// match more than one review; other names are looked up directly.
+//
+// If the name matches more than one review, then the returned error lists all
+// of them. If it matches none, then the name is returned unchanged, so that the
+// caller reports the failure to find a review for it.
+func Resolve(repo repository.Repo, name string) (string, error) {
+	if len(name) == 40 && isHash(name) {
+		return name, nil
+	}
+	if strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("Invalid review name %q", name)
+	}
+	if _, err := strconv.Atoi(strings.TrimPrefix(name, "#")); err != nil {
+		revision, ambiguous := resolveDirectly(repo, name)
+		if revision != "" {
+			return revision, nil
+		}
+		if !ambiguous {
+			return name, nil
+		}
+	}
+	return resolveFromAll(repo, name)
+}
+
+// resolveFromAll resolves the given name by listing every review.
+func resolveFromAll(repo repository.Repo, name string) (string, error) {
+	reviews := unsortedListAll(repo)
+	sort.Stable(summariesWithNewestRequestsFirst(reviews))
+	var matches []*Summary
+	matched := make(map[string]bool)
+	match := func(r *Summary) {
+		if !matched[r.Revision] {
+			matched[r.Revision] = true
+			matches = append(matches, r)
+		}
+	}
+
+	if number, err := strconv.Atoi(strings.TrimPrefix(name, "#")); err == nil {
+		for i := range reviews {
+			if reviews[i].Number == number {
+				match(&reviews[i])
+			}
+		}
+	}
+	if len(name) >= minimumHashPrefixLength && isHash(name) {
+		for i := range reviews {
+			if strings.HasPrefix(reviews[i].Revision, name) {
+				match(&reviews[i])
+			}
+		}
+	}
+	if !strings.HasPrefix(name, "#") {
+		if ref, err := repo.GetRefName(name); err == nil && ref != "" {
+			for i := range reviews {
+				if reviews[i].IsOpen() && reviews[i].Request.ReviewRef == ref {
+					match(&reviews[i])
+				}
+			}
+		}
+	}
+	if len(matches) == 0 && !strings.HasPrefix(name, "#") {
+		if commit, err := repo.GetCommitHash(name); err == nil {
+			for i := range reviews {
+				if reviews[i].Revision == commit || reviews[i].Request.Alias == commit {
+					match(&reviews[i])
+				}
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return name, nil
+	case 1:
+		return matches[0].Revision, nil
+	}
+	var candidates []string
+	for _, r := range matches {
+		subject := strings.SplitN(strings.TrimSpace(r.Request.Description), "\n", 2)[0]
+		candidates = append(candidates, fmt.Sprintf("#%d %.12s %s", r.Number, r.Revision, subject))
+	}
+	return "", fmt.Errorf("The review name %q is ambiguous; it matches:\n  %s", name, strings.Join(candidates, "\n  "))
+}
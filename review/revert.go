@@ -0,0 +1,78 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PreviousTargetRef returns the target ref of the latest request for the
+// review that had one, i.e. the target ref the review had before it was
+// abandoned, or the empty string if there is no such request.
+func (r *Summary) PreviousTargetRef() string {
+	for i := len(r.AllRequests) - 1; i >= 0; i-- {
+		if r.AllRequests[i].TargetRef != "" {
+			return r.AllRequests[i].TargetRef
+		}
+	}
+	return ""
+}
+
+// GetRevertCommits returns the commits that make up a submitted review, in
+// the order in which they should be reverted (i.e. newest first).
+//
+// Merge commits are skipped, as the changes they bring in from the target
+// ref are not part of the review.
+func (r *Review) GetRevertCommits() ([]string, error) {
+	if !r.Submitted {
+		return nil, errors.New("Only submitted reviews can be reverted.")
+	}
+	base, err := r.GetBaseCommit()
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := r.Repo.ListCommitsBetween(base, head)
+	if err != nil {
+		return nil, err
+	}
+	var reverts []string
+	for i := len(commits) - 1; i >= 0; i-- {
+		details, err := r.Repo.GetCommitDetails(commits[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(details.Parents) <= 1 {
+			reverts = append(reverts, commits[i])
+		}
+	}
+	if len(reverts) == 0 {
+		return nil, fmt.Errorf("There are no commits to revert in the review %.12s.", r.Revision)
+	}
+	return reverts, nil
+}
+
+// RevertDescription returns the default description for a review reverting this one.
+func (r *Summary) RevertDescription() string {
+	subject := strings.SplitN(strings.TrimSpace(r.Request.Description), "\n", 2)[0]
+	return fmt.Sprintf("Revert %q\n\nThis reverts the changes submitted in review %s.", subject, r.Revision)
+}
@@ -22,12 +22,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/KoviRobi/git-appraise/repository"
 	"github.com/KoviRobi/git-appraise/review/analyses"
 	"github.com/KoviRobi/git-appraise/review/ci"
 	"github.com/KoviRobi/git-appraise/review/comment"
 	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
 	"github.com/KoviRobi/git-appraise/review/request"
 )
 
@@ -35,6 +38,15 @@ const archiveRef = "refs/devtools/archives/reviews"
 
 var emptyTree = repository.NewTree(map[string]repository.TreeChild{})
 
+// currentTimestamp returns the current time in the format used for the
+// timestamps of review requests.
+//
+// Requests that are rewritten (e.g. when the review is rebased) record it as
+// the time they were modified, rather than replacing their timestamp.
+func currentTimestamp() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
 // CommentThread represents the tree-based hierarchy of comments.
 //
 // The Resolved field represents the aggregate status of the entire thread. If
@@ -51,6 +63,9 @@ type CommentThread struct {
 	Children []CommentThread    `json:"children,omitempty"`
 	Resolved *bool              `json:"resolved,omitempty"`
 	Edited   bool               `json:"edited,omitempty"`
+	// Signature is the result of checking the signature on the comment; it
+	// is only set once the signatures of the review have been checked.
+	Signature *gpg.Result `json:"signature,omitempty"`
 }
 
 // Summary represents the high-level state of a code review.
@@ -71,6 +86,14 @@ type Summary struct {
 	Comments    []CommentThread   `json:"comments,omitempty"`
 	Resolved    *bool             `json:"resolved,omitempty"`
 	Submitted   bool              `json:"submitted"`
+	// Number is the numeric alias of the review; it is only set for
+	// reviews that were listed together with all of the other reviews.
+	Number int `json:"number,omitempty"`
+	// People is the registry used to canonicalize the identities in the review.
+	People *identity.Registry `json:"-"`
+	// Signature is the result of checking the signature on the request; it
+	// is only set once the signatures of the review have been checked.
+	Signature *gpg.Result `json:"signature,omitempty"`
 }
 
 // Review represents the entire state of a code review.
@@ -106,11 +129,19 @@ func (threads byTimestamp) Less(i, j int) bool {
 type requestsByTimestamp []request.Request
 
 // Interface methods for sorting review requests by timestamp
+//
+// Requests are ordered by when they were last modified, so that a rewritten
+// request (which keeps the timestamp of the request it rewrites) sorts after
+// that request regardless of the order of the notes, which is alphabetical
+// once they have been merged.
 func (requests requestsByTimestamp) Len() int { return len(requests) }
 func (requests requestsByTimestamp) Swap(i, j int) {
 	requests[i], requests[j] = requests[j], requests[i]
 }
 func (requests requestsByTimestamp) Less(i, j int) bool {
+	if modifiedI, modifiedJ := requests[i].LastModified(), requests[j].LastModified(); modifiedI != modifiedJ {
+		return modifiedI < modifiedJ
+	}
 	return requests[i].Timestamp < requests[j].Timestamp
 }
 
@@ -167,22 +198,6 @@ func (thread *CommentThread) updateResolvedStatus() {
 	thread.Resolved = resolved
 }
 
-// Verify verifies the signature on a comment.
-func (thread *CommentThread) Verify() error {
-	err := gpg.Verify(&thread.Comment)
-	if err != nil {
-		hash, _ := thread.Comment.Hash()
-		return fmt.Errorf("verification of comment [%s] failed: %s", hash, err)
-	}
-	for _, child := range thread.Children {
-		err = child.Verify()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // mutableThread is an internal-only data structure used to store partially constructed comment threads.
 type mutableThread struct {
 	Hash     string
@@ -239,8 +254,10 @@ func buildCommentThreads(commentsByHash map[string]comment.Comment) []CommentThr
 	var rootHashes []string
 	for hash, thread := range threadsByHash {
 		if thread.Comment.Original != "" {
+			// Only the author of a comment may edit or retract it, so edits
+			// by anyone else are ignored.
 			original, ok := threadsByHash[thread.Comment.Original]
-			if ok {
+			if ok && original.Comment.Author == thread.Comment.Author {
 				original.Edits = append(original.Edits, &thread.Comment)
 			}
 		} else if thread.Comment.Parent == "" {
@@ -323,9 +340,21 @@ func GetSummaryViaRefs(repo repository.Repo, requestRef, commentRef, revision st
 
 // GetSummary returns the summary of the specified code review.
 //
+// The review can be specified by any of the names accepted by Resolve.
+//
 // If no review request exists, the returned review summary is nil.
-func GetSummary(repo repository.Repo, revision string) (*Summary, error) {
-	return GetSummaryViaRefs(repo, request.Ref, comment.Ref, revision)
+func GetSummary(repo repository.Repo, name string) (*Summary, error) {
+	revision, err := Resolve(repo, name)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := GetSummaryViaRefs(repo, request.Ref, comment.Ref, revision)
+	if err != nil {
+		return nil, err
+	}
+	people, _ := identity.Load(repo)
+	summary.canonicalizeIdentities(people)
+	return summary, nil
 }
 
 // Details returns the detailed review for the given summary.
@@ -351,23 +380,6 @@ func (r *Summary) IsOpen() bool {
 	return !r.Submitted && !r.IsAbandoned()
 }
 
-// Verify returns whether or not a summary's comments are a) signed, and b)
-/// that those signatures are verifiable.
-func (r *Summary) Verify() error {
-	err := gpg.Verify(&r.Request)
-	if err != nil {
-		return fmt.Errorf("couldn't verify request targeting: %q: %s",
-			r.Request.TargetRef, err)
-	}
-	for _, thread := range r.Comments {
-		err := thread.Verify()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // Get returns the specified code review.
 //
 // If no review request exists, the returned review is nil.
@@ -413,6 +425,9 @@ func unsortedListAll(repo repository.Repo) []Summary {
 		return nil
 	}
 
+	// A malformed people file should not prevent reviews from being
+	// listed, so the identities are just left as they are in that case.
+	people, _ := identity.Load(repo)
 	isSubmittedCheck := getIsSubmittedCheck(repo)
 	var reviews []Summary
 	for commit, notes := range reviewNotesMap {
@@ -423,8 +438,10 @@ func unsortedListAll(repo repository.Repo) []Summary {
 		if !summary.IsAbandoned() {
 			summary.Submitted = isSubmittedCheck(summary.Request.TargetRef, summary.getStartingCommit())
 		}
+		summary.canonicalizeIdentities(people)
 		reviews = append(reviews, *summary)
 	}
+	numberReviews(reviews)
 	return reviews
 }
 
@@ -472,14 +489,19 @@ func GetCurrent(repo repository.Repo) (*Review, error) {
 
 // GetBuildStatusMessage returns a string of the current build-and-test status
 // of the review, or "unknown" if the build-and-test status cannot be determined.
+//
+// If multiple agents have reported on the review, then this is the aggregate
+// of their latest statuses.
 func (r *Review) GetBuildStatusMessage() string {
 	statusMessage := "unknown"
-	ciReport, err := ci.GetLatestCIReport(r.Reports)
+	matrix, err := r.GetCIMatrix()
 	if err != nil {
 		return fmt.Sprintf("unknown: %s", err)
 	}
-	if ciReport != nil {
-		statusMessage = fmt.Sprintf("%s (%q)", ciReport.Status, ciReport.URL)
+	if len(matrix) == 1 && matrix[0].Status != "" {
+		statusMessage = fmt.Sprintf("%s (%q)", matrix[0].Status, matrix[0].URL)
+	} else if aggregate := ci.AggregateStatus(matrix); aggregate != "" {
+		statusMessage = fmt.Sprintf("%s (%d agents)", aggregate, len(matrix))
 	}
 	return statusMessage
 }
@@ -594,7 +616,9 @@ func (r *Review) findLastCommit(startingCommit, latestCommit string, commentThre
 	}
 	for _, commentThread := range commentThreads {
 		comment := commentThread.Comment
-		if comment.Location != nil {
+		if comment.Location != nil && !comment.Location.IsOldSide() {
+			// Comments on the old side are anchored at the base commit,
+			// which is never the head of the review.
 			updateLatest(comment.Location.Commit)
 		}
 		updateLatest(r.findLastCommit(startingCommit, latestCommit, commentThread.Children))
@@ -663,6 +687,24 @@ func (r *Review) GetBaseCommit() (string, error) {
 		}
 	}
 
+	// A review stacked on top of another open review is compared against the
+	// head of that review, so that its diff does not include the changes
+	// being reviewed there.
+	dependencyHead, err := r.getDependencyHeadCommit()
+	if err != nil {
+		return "", err
+	}
+	if dependencyHead != "" {
+		if isAncestor, err := r.Repo.IsAncestor(dependencyHead, rightHandSide); err == nil && isAncestor {
+			return dependencyHead, nil
+		}
+		// The review it depends upon has changed since this review was
+		// last stacked on top of it, so fall back to the recorded base.
+		if r.Request.BaseCommit != "" {
+			return r.Request.BaseCommit, nil
+		}
+	}
+
 	return r.Repo.MergeBase(leftHandSide, rightHandSide)
 }
 
@@ -681,6 +723,18 @@ func (r *Review) ListCommits() ([]string, error) {
 
 // GetDiff returns the diff for a review.
 func (r *Review) GetDiff(diffArgs ...string) (string, error) {
+	if len(r.Request.DependsOn) > 0 && r.IsOpen() {
+		// Stacked reviews are shown relative to the review they depend upon.
+		baseCommit, err := r.GetBaseCommit()
+		if err != nil {
+			return "", err
+		}
+		headCommit, err := r.GetHeadCommit()
+		if err != nil {
+			return "", err
+		}
+		return r.Repo.Diff(baseCommit, headCommit, diffArgs...)
+	}
 	headCommit, err := r.Repo.GetCommitHash(r.Summary.Revision)
 	if err != nil {
 		return headCommit, err
@@ -705,35 +759,14 @@ func (r *Review) AddComment(c comment.Comment) error {
 // review will be added to the 'refs/devtools/archives/reviews' ref prior
 // to being rewritten. That ensures the review history is kept from being
 // garbage collected.
+//
+// If the rebase stops before completing, then ErrRebaseStopped is returned
+// and the rebase can later be finished using ContinueRebase.
 func (r *Review) Rebase(archivePrevious bool) error {
-	if archivePrevious {
-		orig, err := r.GetHeadCommit()
-		if err != nil {
-			return err
-		}
-		if err := r.Repo.ArchiveRef(orig, archiveRef); err != nil {
-			return err
-		}
-	}
-	if err := r.Repo.SwitchToRef(r.Request.ReviewRef); err != nil {
-		return err
-	}
-
-	err := r.Repo.RebaseRef(r.Request.TargetRef)
-	if err != nil {
-		return err
-	}
-
-	alias, err := r.Repo.GetCommitHash("HEAD")
-	if err != nil {
-		return err
-	}
-	r.Request.Alias = alias
-	newNote, err := r.Request.Write()
-	if err != nil {
-		return err
-	}
-	return r.Repo.AppendNote(request.Ref, r.Revision, newNote)
+	return r.RebaseWithOptions(RebaseOptions{
+		Archive:     archivePrevious,
+		Interactive: true,
+	})
 }
 
 // RebaseAndSign performs an interactive rebase of the review onto its
@@ -745,44 +778,11 @@ func (r *Review) Rebase(archivePrevious bool) error {
 // to being rewritten. That ensures the review history is kept from being
 // garbage collected.
 func (r *Review) RebaseAndSign(archivePrevious bool) error {
-	if archivePrevious {
-		orig, err := r.GetHeadCommit()
-		if err != nil {
-			return err
-		}
-		if err := r.Repo.ArchiveRef(orig, archiveRef); err != nil {
-			return err
-		}
-	}
-	if err := r.Repo.SwitchToRef(r.Request.ReviewRef); err != nil {
-		return err
-	}
-
-	err := r.Repo.RebaseAndSignRef(r.Request.TargetRef)
-	if err != nil {
-		return err
-	}
-
-	alias, err := r.Repo.GetCommitHash("HEAD")
-	if err != nil {
-		return err
-	}
-	r.Request.Alias = alias
-
-	key, err := r.Repo.GetUserSigningKey()
-	if err != nil {
-		return err
-	}
-	err = gpg.Sign(key, &r.Request)
-	if err != nil {
-		return err
-	}
-
-	newNote, err := r.Request.Write()
-	if err != nil {
-		return err
-	}
-	return r.Repo.AppendNote(request.Ref, r.Revision, newNote)
+	return r.RebaseWithOptions(RebaseOptions{
+		Archive:     archivePrevious,
+		Sign:        true,
+		Interactive: true,
+	})
 }
 
 func wellKnownCommitForPath(repo repository.Repo, path string, archive bool) (string, error) {
@@ -821,10 +821,8 @@ func AddDetachedComment(repo repository.Repo, c *comment.Comment) error {
 	return repo.AppendNote(comment.Ref, wellKnownCommit, commentNote)
 }
 
+// GetDetachedComments returns the detached comments on the given file or
+// directory, following the renames of the file in the history of HEAD.
 func GetDetachedComments(repo repository.Repo, path string) ([]CommentThread, error) {
-	wellKnownCommit, err := wellKnownCommitForPath(repo, path, false)
-	if err != nil {
-		return nil, fmt.Errorf("Failure finding the well-known commit for detached comments on %q: %v", path, err)
-	}
-	return GetComments(repo, wellKnownCommit)
+	return GetDetachedCommentsAt(repo, "HEAD", path)
 }
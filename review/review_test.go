@@ -17,10 +17,19 @@ limitations under the License.
 package review
 
 import (
+	"errors"
 	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/analyses"
+	"github.com/KoviRobi/git-appraise/review/checklist"
+	"github.com/KoviRobi/git-appraise/review/ci"
 	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/discussion"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
 	"github.com/KoviRobi/git-appraise/review/request"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -133,6 +142,46 @@ func TestRequestSorting(t *testing.T) {
 	}
 }
 
+func TestRewrittenRequestSortingAfterMerge(t *testing.T) {
+	original := request.Request{
+		Timestamp:   "0000000010",
+		ReviewRef:   "refs/heads/change",
+		TargetRef:   "refs/heads/master",
+		Description: "Change",
+	}
+	rebased := original
+	rebased.Alias = "B"
+	rebased.Modified = "0000000020"
+	abandoned := rebased
+	abandoned.TargetRef = ""
+	abandoned.Modified = "0000000030"
+	var notes []repository.Note
+	for _, r := range []request.Request{original, rebased, abandoned} {
+		note, err := r.Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, note)
+	}
+
+	// Merging the notes sorts their lines, which puts the abandoned request,
+	// with its empty target ref, before the others.
+	merged := unionNotes(notes, nil)
+	if reflect.DeepEqual(merged, notes) {
+		t.Fatalf("Merging the notes unexpectedly left them in order: %q", merged)
+	}
+	summary, err := getSummaryFromNotes(repository.NewMockRepoForTest(), "A", merged, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Request.TargetRef != "" || summary.Request.Alias != "B" {
+		t.Fatalf("Unexpected latest request after merging the notes: %+v", summary.Request)
+	}
+	if summary.IsOpen() {
+		t.Fatal("The abandoned review is open after merging the notes")
+	}
+}
+
 func validateUnresolved(t *testing.T, resolved *bool) {
 	if resolved != nil {
 		t.Fatalf("Expected resolved status to be unset, but instead it was %v", *resolved)
@@ -868,3 +917,969 @@ func TestRebaseDetachedHead(t *testing.T) {
 		t.Fatalf("Failed to submit the review: %q", submittedReviewJSON)
 	}
 }
+
+// stopRebaseForTest starts a rebase of the pending review that stops before completing.
+func stopRebaseForTest(t *testing.T) (repository.MockRepo, *Review) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	repo.SetDataDir(t.TempDir())
+	pendingReview, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.StopRebases(true)
+	if err := pendingReview.Rebase(true); err != ErrRebaseStopped {
+		t.Fatalf("Unexpected result of a rebase that stops: %v", err)
+	}
+	repo.StopRebases(false)
+	if inProgress, err := IsRebaseInProgress(repo); err != nil || !inProgress {
+		t.Fatalf("Failed to record the stopped rebase: %v, %v", inProgress, err)
+	}
+	if err := pendingReview.Rebase(true); err != ErrRebaseInProgress {
+		t.Fatalf("Unexpected result of starting a second rebase: %v", err)
+	}
+	if stopped, err := Get(repo, repository.TestCommitG); err != nil || stopped.Request.Alias != "" {
+		t.Fatalf("Unexpected alias for a review whose rebase stopped: %v, %v", stopped, err)
+	}
+	return repo, pendingReview
+}
+
+func TestContinueRebase(t *testing.T) {
+	repo, pendingReview := stopRebaseForTest(t)
+	if err := ContinueRebase(repo); err != nil {
+		t.Fatal(err)
+	}
+	if inProgress, err := IsRebaseInProgress(repo); err != nil || inProgress {
+		t.Fatalf("Failed to finish the rebase: %v, %v", inProgress, err)
+	}
+	rebased, err := Get(repo, pendingReview.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewCommit, err := repo.GetCommitHash(rebased.Request.ReviewRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.Request.Alias == "" || rebased.Request.Alias != reviewCommit {
+		t.Fatalf("Failed to set the review alias after continuing: %q, %q", rebased.Request.Alias, reviewCommit)
+	}
+	if isAncestor, err := repo.IsAncestor(pendingReview.Revision, archiveRef); err != nil || !isAncestor {
+		t.Fatalf("Commit %q is not archived: %v", pendingReview.Revision, err)
+	}
+	if err := ContinueRebase(repo); err != ErrNoRebaseInProgress {
+		t.Fatalf("Unexpected result of continuing a finished rebase: %v", err)
+	}
+}
+
+func TestContinueAbandonedRebase(t *testing.T) {
+	repo, pendingReview := stopRebaseForTest(t)
+	// The user gave up on the underlying git rebase themselves.
+	if err := repo.AbortRebase(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ContinueRebase(repo); err != ErrRebaseNotFinished {
+		t.Fatalf("Unexpected result of continuing an aborted rebase: %v", err)
+	}
+
+	// The user switched away from the review ref.
+	if err := repo.SwitchToRef(repository.TestTargetRef); err != nil {
+		t.Fatal(err)
+	}
+	if err := ContinueRebase(repo); err != ErrRebaseNotFinished {
+		t.Fatalf("Unexpected result of continuing a rebase on another ref: %v", err)
+	}
+	unchanged, err := Get(repo, pendingReview.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.Request.Alias != "" {
+		t.Fatalf("Unexpected alias after failing to continue a rebase: %q", unchanged.Request.Alias)
+	}
+	if err := AbortRebase(repo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbortRebase(t *testing.T) {
+	repo, pendingReview := stopRebaseForTest(t)
+	if err := AbortRebase(repo); err != nil {
+		t.Fatal(err)
+	}
+	if inProgress, err := repo.IsRebaseInProgress(); err != nil || inProgress {
+		t.Fatalf("Failed to abort the git rebase: %v, %v", inProgress, err)
+	}
+	if inProgress, err := IsRebaseInProgress(repo); err != nil || inProgress {
+		t.Fatalf("Failed to forget the aborted rebase: %v, %v", inProgress, err)
+	}
+	aborted, err := Get(repo, pendingReview.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aborted.Request.Alias != "" {
+		t.Fatalf("Unexpected alias after aborting a rebase: %q", aborted.Request.Alias)
+	}
+	if reviewCommit, err := repo.GetCommitHash(aborted.Request.ReviewRef); err != nil || reviewCommit != repository.TestCommitI {
+		t.Fatalf("Unexpected review ref after aborting a rebase: %q, %v", reviewCommit, err)
+	}
+	if err := AbortRebase(repo); err != ErrNoRebaseInProgress {
+		t.Fatalf("Unexpected result of aborting a finished rebase: %v", err)
+	}
+}
+
+func TestGetStack(t *testing.T) {
+	reviews := []Summary{
+		Summary{
+			Revision: "C",
+			Request:  request.Request{Description: "Third", DependsOn: []string{"B"}},
+		},
+		Summary{
+			Revision: "D",
+			Request:  request.Request{Description: "Fourth", DependsOn: []string{"A"}},
+		},
+		Summary{
+			Revision: "B",
+			Request:  request.Request{Description: "Second", DependsOn: []string{"A"}},
+		},
+		Summary{
+			Revision: "A",
+			Request:  request.Request{Description: "First"},
+		},
+	}
+	s, err := getStack(reviews, "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Summary.Revision != "A" {
+		t.Fatalf("Unexpected root of the stack: %q", s.Summary.Revision)
+	}
+	if len(s.Children) != 2 || s.Children[0].Summary.Revision != "B" || s.Children[1].Summary.Revision != "D" {
+		t.Fatalf("Unexpected children of the stack root: %v", s.Children)
+	}
+	if len(s.Children[0].Children) != 1 || s.Children[0].Children[0].Summary.Revision != "C" {
+		t.Fatalf("Unexpected children of the second review: %v", s.Children[0].Children)
+	}
+}
+
+// stackedReviewForTest requests a review of a commit on top of the head of
+// the pending review, which it depends upon.
+func stackedReviewForTest(t *testing.T) (repository.MockRepo, *Review) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	stackedCommit, err := repo.CreateCommit(&repository.CommitDetails{
+		Summary: "Stacked commit",
+		Time:    "0000000010",
+		Parents: []string{repository.TestCommitI},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stackedRef := "refs/heads/ojarjur/stacked"
+	if err := repo.SetRef(stackedRef, stackedCommit, ""); err != nil {
+		t.Fatal(err)
+	}
+	stackedRequest := request.New("ojarjur", []string{"ojarjur"}, stackedRef, repository.TestTargetRef, "Stacked")
+	stackedRequest.BaseCommit = repository.TestCommitI
+	stackedRequest.DependsOn = []string{repository.TestCommitG}
+	note, err := stackedRequest.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AppendNote(request.Ref, stackedCommit, note); err != nil {
+		t.Fatal(err)
+	}
+	stacked, err := Get(repo, stackedCommit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, stacked
+}
+
+func TestGetBaseCommitOfStackedReview(t *testing.T) {
+	repo, stacked := stackedReviewForTest(t)
+	if base, err := stacked.GetBaseCommit(); err != nil || base != repository.TestCommitI {
+		t.Fatalf("Unexpected base commit for a stacked review: %q, %v", base, err)
+	}
+	unsubmitted, err := stacked.GetUnsubmittedDependencies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsubmitted) != 1 || unsubmitted[0].Revision != repository.TestCommitG {
+		t.Fatalf("Unexpected unsubmitted dependencies: %v", unsubmitted)
+	}
+
+	// Once the review it depends upon is rebased, the recorded base is used.
+	pendingReview, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pendingReview.Rebase(true); err != nil {
+		t.Fatal(err)
+	}
+	if base, err := stacked.GetBaseCommit(); err != nil || base != repository.TestCommitI {
+		t.Fatalf("Unexpected base commit once the dependency has changed: %q, %v", base, err)
+	}
+}
+
+func TestRebaseOnto(t *testing.T) {
+	repo, stacked := stackedReviewForTest(t)
+	if err := repo.SwitchToRef(repository.TestTargetRef); err != nil {
+		t.Fatal(err)
+	}
+	previousHead := stacked.Revision
+	if err := stacked.RebaseOnto(repository.TestCommitJ, true); err != nil {
+		t.Fatal(err)
+	}
+	if headRef, err := repo.GetHeadRef(); err != nil || headRef != repository.TestTargetRef {
+		t.Fatalf("Failed to restore the original ref after rebasing: %q, %v", headRef, err)
+	}
+	if isAncestor, err := repo.IsAncestor(previousHead, archiveRef); err != nil || !isAncestor {
+		t.Fatalf("Commit %q is not archived: %v", previousHead, err)
+	}
+	rebased, err := Get(repo, stacked.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.Request.BaseCommit != repository.TestCommitJ {
+		t.Fatalf("Unexpected base commit after rebasing: %q", rebased.Request.BaseCommit)
+	}
+	reviewCommit, err := repo.GetCommitHash(rebased.Request.ReviewRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.Request.Alias == "" || rebased.Request.Alias != reviewCommit {
+		t.Fatalf("Unexpected alias after rebasing: %q", rebased.Request.Alias)
+	}
+}
+
+func TestRebaseOntoStopped(t *testing.T) {
+	repo, stacked := stackedReviewForTest(t)
+	if err := repo.SwitchToRef(repository.TestTargetRef); err != nil {
+		t.Fatal(err)
+	}
+	requestNotes := repo.GetNotes(request.Ref, stacked.Revision)
+	repo.StopRebases(true)
+	if err := stacked.RebaseOnto(repository.TestCommitJ, false); err == nil {
+		t.Fatal("Unexpected success rebasing when the rebase stops")
+	}
+	if inProgress, err := repo.IsRebaseInProgress(); err != nil || inProgress {
+		t.Fatalf("Failed to abort the stopped rebase: %v, %v", inProgress, err)
+	}
+	if headRef, err := repo.GetHeadRef(); err != nil || headRef != repository.TestTargetRef {
+		t.Fatalf("Failed to restore the original ref after a stopped rebase: %q, %v", headRef, err)
+	}
+	if reviewCommit, err := repo.GetCommitHash(stacked.Request.ReviewRef); err != nil || reviewCommit != stacked.Revision {
+		t.Fatalf("Unexpected review ref after a stopped rebase: %q, %v", reviewCommit, err)
+	}
+	if notes := repo.GetNotes(request.Ref, stacked.Revision); !reflect.DeepEqual(notes, requestNotes) {
+		t.Fatalf("Unexpected request after a stopped rebase: %q", notes)
+	}
+}
+
+func TestRestack(t *testing.T) {
+	repo, stacked := stackedReviewForTest(t)
+	pendingReview, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pendingReview.Rebase(true); err != nil {
+		t.Fatal(err)
+	}
+	parentHead, err := repo.GetCommitHash(pendingReview.Request.ReviewRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := GetStack(repo, stacked.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Summary.Revision != repository.TestCommitG || len(s.Children) != 1 || s.Children[0].Summary.Revision != stacked.Revision {
+		t.Fatalf("Unexpected stack: %+v", s)
+	}
+	if err := s.Restack(true); err != nil {
+		t.Fatal(err)
+	}
+	restacked, err := Get(repo, stacked.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restacked.Request.BaseCommit != parentHead {
+		t.Fatalf("Unexpected base commit after restacking: %q", restacked.Request.BaseCommit)
+	}
+	head, err := restacked.GetHeadCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isAncestor, err := repo.IsAncestor(parentHead, head); err != nil || !isAncestor {
+		t.Fatalf("The restacked review is not on top of the review it depends upon: %v", err)
+	}
+	if base, err := restacked.GetBaseCommit(); err != nil || base != parentHead {
+		t.Fatalf("Unexpected base commit for the restacked review: %q, %v", base, err)
+	}
+
+	// Restacking again leaves the review, which is already on top of the
+	// review it depends upon, alone.
+	if err := s.Restack(true); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Get(repo, stacked.Revision); err != nil || again.Request.Alias != restacked.Request.Alias {
+		t.Fatalf("Unexpected change to the review when restacking again: %v", err)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	accepted := true
+	r := &Review{
+		Summary: &Summary{
+			Revision: "A",
+			AllRequests: []request.Request{
+				request.Request{Timestamp: "0000000010", TargetRef: "refs/heads/master", Description: "First"},
+				request.Request{Timestamp: "0000000030", TargetRef: "refs/heads/master", Description: "Second"},
+				request.Request{Timestamp: "0000000030", Modified: "0000000050", TargetRef: "refs/heads/master", Alias: "B"},
+				request.Request{Timestamp: "0000000030", Modified: "0000000060"},
+			},
+			Comments: []CommentThread{
+				CommentThread{
+					Comment: comment.Comment{Timestamp: "0000000020", Description: "Needs tests"},
+					Children: []CommentThread{
+						CommentThread{
+							Comment: comment.Comment{Timestamp: "0000000040", Resolved: &accepted},
+						},
+					},
+				},
+			},
+		},
+	}
+	var types []EventType
+	for _, event := range r.Timeline() {
+		types = append(types, event.Type)
+	}
+	expected := []EventType{EventRequested, EventComment, EventUpdated, EventAccepted, EventRebased, EventAbandoned}
+	if len(types) != len(expected) {
+		t.Fatalf("Unexpected timeline: %v", types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("Unexpected timeline: %v", types)
+		}
+	}
+}
+
+func TestRewriteKeepsRequestTimestamp(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	var before []string
+	for _, summary := range ListAll(repo) {
+		before = append(before, summary.Revision)
+	}
+	pendingReview, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requested := pendingReview.Request.Timestamp
+	if err := pendingReview.Rebase(true); err != nil {
+		t.Fatal(err)
+	}
+
+	rebased, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.Request.Timestamp != requested {
+		t.Fatalf("Unexpected request timestamp after rebasing: %q", rebased.Request.Timestamp)
+	}
+	if rebased.Request.Modified == "" || rebased.Request.LastModified() != rebased.Request.Modified {
+		t.Fatalf("Failed to record when the request was rebased: %q", rebased.Request.Modified)
+	}
+	var after []string
+	for _, summary := range ListAll(repo) {
+		after = append(after, summary.Revision)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Fatalf("Unexpected order of reviews after rebasing: %v, rather than %v", after, before)
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	accepted := true
+	rejected := false
+	r := Summary{
+		Revision: "abc",
+		Request:  request.Request{Requester: "me@example.com"},
+		Comments: []CommentThread{
+			CommentThread{
+				Comment: comment.Comment{Author: "alice@example.com", Timestamp: "0000000001", Resolved: &rejected},
+				Children: []CommentThread{
+					CommentThread{
+						Comment: comment.Comment{Author: "alice@example.com", Timestamp: "0000000002", Resolved: &accepted},
+					},
+				},
+			},
+			CommentThread{
+				Comment: comment.Comment{Author: "bob@example.com", Timestamp: "0000000001", Resolved: &accepted},
+			},
+			CommentThread{
+				Comment: comment.Comment{Author: "bob@example.com", Timestamp: "0000000003", Resolved: &rejected},
+			},
+			CommentThread{
+				Comment: comment.Comment{Author: "carol@example.com", Description: "LGTM, thanks"},
+			},
+			CommentThread{
+				Comment: comment.Comment{Author: "me@example.com", Description: "+1", Resolved: &accepted},
+			},
+		},
+	}
+	expected := []string{
+		"Reviewed-by: alice@example.com",
+		"Acked-by: carol@example.com",
+		"Review-Id: abc",
+	}
+	if trailers := r.Trailers(); !reflect.DeepEqual(trailers, expected) {
+		t.Errorf("Unexpected trailers: %q", trailers)
+	}
+
+	for _, tc := range []struct{ message, expected string }{
+		{"Subject", "Subject\n\nReview-Id: abc\n"},
+		{"Subject\n\nBody\n", "Subject\n\nBody\n\nReview-Id: abc\n"},
+		{"Subject\n\nSigned-off-by: me@example.com", "Subject\n\nSigned-off-by: me@example.com\nReview-Id: abc\n"},
+		{"Subject\n\nReview-Id: abc\n", "Subject\n\nReview-Id: abc\n"},
+	} {
+		if message := AppendTrailers(tc.message, []string{"Review-Id: abc"}); message != tc.expected {
+			t.Errorf("Unexpected message with trailers for %q: %q", tc.message, message)
+		}
+	}
+}
+
+func TestUnmetPreconditions(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	r, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Preconditions{RequireCI: true, RequireAnalyses: true}
+	if unmet := r.UnmetPreconditions(p); len(unmet) != 2 {
+		t.Errorf("Unexpected unmet preconditions without any reports: %q", unmet)
+	}
+
+	// The latest report from agent "a" is a failure, even though the latest
+	// report overall, from agent "b", is a success.
+	r.Reports = []ci.Report{
+		ci.Report{Timestamp: "1", Status: ci.StatusFailure, Agent: "a"},
+		ci.Report{Timestamp: "2", Status: ci.StatusSuccess, Agent: "b"},
+	}
+	r.Analyses = []analyses.Report{
+		analyses.Report{Timestamp: "1", Status: analyses.StatusForYourInformation},
+	}
+	if unmet := r.UnmetPreconditions(p); len(unmet) != 1 {
+		t.Errorf("Unexpected unmet preconditions with a failing agent: %q", unmet)
+	}
+	r.Reports = append(r.Reports, ci.Report{Timestamp: "3", Status: ci.StatusSuccess, Agent: "a"})
+	if unmet := r.UnmetPreconditions(p); len(unmet) != 0 {
+		t.Errorf("Unexpected unmet preconditions: %q", unmet)
+	}
+	r.Reports = r.Reports[:2]
+	p.CIAgents = []string{"a", "b", "c"}
+	if unmet := r.UnmetPreconditions(p); len(unmet) != 2 {
+		t.Errorf("Unexpected unmet preconditions for specific agents: %q", unmet)
+	}
+}
+
+func TestPreviousTargetRef(t *testing.T) {
+	r := Summary{
+		Request: request.Request{TargetRef: ""},
+		AllRequests: []request.Request{
+			request.Request{Timestamp: "0000000001", TargetRef: "refs/heads/master"},
+			request.Request{Timestamp: "0000000002", TargetRef: "refs/heads/release"},
+			request.Request{Timestamp: "0000000003", TargetRef: ""},
+		},
+	}
+	if !r.IsAbandoned() {
+		t.Fatal("Expected the review to be abandoned")
+	}
+	if target := r.PreviousTargetRef(); target != "refs/heads/release" {
+		t.Errorf("Unexpected previous target ref: %q", target)
+	}
+}
+
+func TestGetBaseCommitAfterRebase(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	pendingReview, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pendingReview.Rebase(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SwitchToRef(pendingReview.Request.TargetRef); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MergeRef(pendingReview.Request.ReviewRef, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The review was rebased onto the target ref before being submitted, so
+	// its base is that target ref, rather than the base of its original commits.
+	submittedReview, err := Get(repo, pendingReview.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !submittedReview.Submitted {
+		t.Fatal("Failed to submit the rebased review")
+	}
+	if base, err := submittedReview.GetBaseCommit(); err != nil || base != repository.TestCommitJ {
+		t.Fatalf("Unexpected base commit for a rebased review: %q, %v", base, err)
+	}
+	reverts, err := submittedReview.GetRevertCommits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reverts, []string{submittedReview.Request.Alias}) {
+		t.Fatalf("Unexpected commits to revert for a rebased review: %v", reverts)
+	}
+}
+
+func TestGetRevertCommits(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	submittedReview, err := Get(repo, repository.TestCommitB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverts, err := submittedReview.GetRevertCommits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reverts, []string{repository.TestCommitB}) {
+		t.Errorf("Unexpected commits to revert: %v", reverts)
+	}
+
+	pendingReview, err := Get(repo, repository.TestCommitG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pendingReview.GetRevertCommits(); err == nil {
+		t.Error("Unexpected success reverting a review that has not been submitted")
+	}
+}
+
+func TestRetractedThreads(t *testing.T) {
+	rejected := false
+	root := comment.Comment{
+		Timestamp:   "012345",
+		Author:      "reviewer@example.com",
+		Resolved:    &rejected,
+		Description: "root",
+	}
+	rootHash, err := root.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := comment.Comment{
+		Timestamp:   "012346",
+		Description: "other",
+	}
+	otherHash, err := other.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	threads := buildCommentThreads(map[string]comment.Comment{
+		rootHash:  root,
+		otherHash: other,
+	})
+	updateThreadsStatus(threads)
+
+	thread, err := FindThread(threads, rootHash[:7])
+	if err != nil || thread.Hash != rootHash {
+		t.Fatalf("Unexpected thread for prefix %q: %v, %v", rootHash[:7], thread, err)
+	}
+	if _, err := FindThread(threads, "x"); err != ErrNoMatchingComment {
+		t.Fatalf("Unexpected error for a missing comment: %v", err)
+	}
+	if _, err := FindThread(threads, ""); err == nil {
+		t.Fatal("Unexpected success finding an empty hash")
+	}
+
+	retraction := thread.Retract(root.Author)
+	retraction.Timestamp = "012347"
+	retractionHash, err := retraction.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	threads = buildCommentThreads(map[string]comment.Comment{
+		rootHash:       root,
+		otherHash:      other,
+		retractionHash: retraction,
+	})
+	if resolved := updateThreadsStatus(threads); resolved != nil {
+		t.Fatalf("Unexpected status after retracting the rejection: %v", *resolved)
+	}
+	visible := HideRetracted(threads)
+	if len(visible) != 1 || visible[0].Hash != otherHash {
+		t.Fatalf("Unexpected visible threads: %v", visible)
+	}
+}
+
+func TestEditsByOtherAuthorsIgnored(t *testing.T) {
+	rejected := false
+	root := comment.Comment{
+		Timestamp:   "012345",
+		Author:      "reviewer@example.com",
+		Resolved:    &rejected,
+		Description: "root",
+	}
+	rootHash, err := root.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread := CommentThread{Hash: rootHash, Comment: root}
+	retraction := thread.Retract("requester@example.com")
+	retraction.Timestamp = "012346"
+	retractionHash, err := retraction.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit := thread.Edit("requester@example.com", "rewritten")
+	edit.Timestamp = "012347"
+	editHash, err := edit.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	threads := buildCommentThreads(map[string]comment.Comment{
+		rootHash:       root,
+		retractionHash: retraction,
+		editHash:       edit,
+	})
+	if resolved := updateThreadsStatus(threads); resolved == nil || *resolved {
+		t.Fatalf("Unexpected status after another author retracted the rejection: %v", resolved)
+	}
+	if len(threads) != 1 || threads[0].Edited || len(threads[0].Edits) != 0 || threads[0].Comment.Description != "root" {
+		t.Fatalf("Unexpected threads after edits by another author: %+v", threads)
+	}
+	if visible := HideRetracted(threads); len(visible) != 1 {
+		t.Fatalf("Unexpected visible threads after another author retracted a comment: %v", visible)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	for name, expected := range map[string]string{
+		"#3":   repository.TestCommitG,
+		"2":    repository.TestCommitD,
+		"G":    repository.TestCommitG,
+		"HEAD": "HEAD",
+	} {
+		revision, err := Resolve(repo, name)
+		if err != nil {
+			t.Fatalf("Unexpected error resolving %q: %v", name, err)
+		}
+		if revision != expected {
+			t.Errorf("Unexpected revision for %q: %q, expected %q", name, revision, expected)
+		}
+	}
+
+	// All of the reviews share the same review ref, but only one is open.
+	if revision, err := Resolve(repo, "ojarjur/mychange"); err != nil || revision != repository.TestCommitG {
+		t.Fatalf("Unexpected result resolving a review ref: %q, %v", revision, err)
+	}
+
+	// Request a second review from the same review ref.
+	second := request.New("ojarjur", nil, repository.TestReviewRef, repository.TestTargetRef, "H")
+	second.Timestamp = "0000000006"
+	note, err := second.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AppendNote(request.Ref, repository.TestCommitH, note); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve(repo, "ojarjur/mychange"); err == nil || !strings.Contains(err.Error(), "#4 H") {
+		t.Fatalf("Unexpected result resolving an ambiguous review name: %v", err)
+	}
+	if revision, err := Resolve(repo, repository.TestCommitH); err != nil || revision != repository.TestCommitH {
+		t.Fatalf("Unexpected result resolving the revision of a review: %q, %v", revision, err)
+	}
+}
+
+func TestAuditSignatures(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	r, err := Get(repo, repository.TestCommitD)
+	if err != nil || r == nil {
+		t.Fatalf("Failed to load the review: %v", err)
+	}
+	auditor := &Auditor{Repo: repo, Verifier: gpg.LoadConfig(repo), CheckMerges: true}
+	checks, err := auditor.Audit(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 || checks[0].Kind != SignedRequest || checks[1].Kind != SignedComment {
+		t.Fatalf("Unexpected signature checks: %+v", checks)
+	}
+	for _, check := range checks {
+		if check.Result.Status != gpg.StatusUnsigned || check.Problem == "" || check.Author != "ojarjur" {
+			t.Errorf("Unexpected result for an unsigned %s: %+v", check.Kind, check)
+		}
+	}
+}
+
+func TestFindMergeCommit(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	// Submit the pending review by fast-forwarding the target ref to it, and
+	// then merge an unrelated commit into the target ref.
+	if err := repo.SetRef(repository.TestTargetRef, repository.TestCommitI, repository.TestCommitJ); err != nil {
+		t.Fatal(err)
+	}
+	unrelated, err := repo.CreateCommit(&repository.CommitDetails{
+		Summary: "Unrelated commit",
+		Time:    "7",
+		Parents: []string{repository.TestCommitA},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MergeRef(unrelated, false, "Merge an unrelated commit"); err != nil {
+		t.Fatal(err)
+	}
+	if merge, err := repo.FindMergeCommit(repository.TestCommitI, repository.TestTargetRef); err != nil || merge != "" {
+		t.Errorf("Unexpected merge commit for a fast-forwarded review: %q, %v", merge, err)
+	}
+
+	// The unrelated commit itself was merged in, by the merge commit.
+	head, err := repo.GetCommitHash(repository.TestTargetRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merge, err := repo.FindMergeCommit(unrelated, repository.TestTargetRef); err != nil || merge != head {
+		t.Errorf("Unexpected merge commit for a merged commit: %q, %v", merge, err)
+	}
+}
+
+// verifierForTest maps each valid signature to the key that made it.
+type verifierForTest map[string]string
+
+func (v verifierForTest) Verify(s gpg.Signable) (string, []string, error) {
+	key, ok := v[*s.Signature()]
+	if !ok {
+		return "", nil, errors.New("invalid signature")
+	}
+	return key, nil, nil
+}
+
+func TestTrustedPeopleFile(t *testing.T) {
+	repo := repository.NewMockRepoForTest().(repository.MockRepo)
+	repo.SetFile(repository.TestCommitJ, identity.PeoplePath, "[person \"alice@example.com\"]\n\tkey = A11CEA11CEA11CEA\n")
+	// The author of a review claims that their own key is Alice's on their
+	// branch, which is then checked out.
+	repo.SetFile(repository.TestCommitI, identity.PeoplePath, "[person \"alice@example.com\"]\n\tkey = BADBADBADBADBAD0\n")
+	if err := repo.SwitchToRef(repository.TestReviewRef); err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range []string{
+		`{"timestamp": "0000000010", "author": "alice@example.com", "description": "forged", "signature": "by-mallory"}`,
+		`{"timestamp": "0000000011", "author": "alice@example.com", "description": "genuine", "signature": "by-alice"}`,
+	} {
+		if err := repo.AppendNote(comment.Ref, repository.TestCommitD, repository.Note(note)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Get(repo, repository.TestCommitD)
+	if err != nil || r == nil {
+		t.Fatalf("Failed to load the review: %v", err)
+	}
+	auditor, err := NewAuditor(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditor.Verifier = verifierForTest{"by-mallory": "BADBADBADBADBAD0", "by-alice": "A11CEA11CEA11CEA"}
+	checks, err := auditor.Audit(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]gpg.Status)
+	for _, check := range checks {
+		if check.Author == "alice@example.com" {
+			statuses[check.Result.Key] = check.Result.Status
+		}
+	}
+	if statuses["BADBADBADBADBAD0"] != gpg.StatusUntrustedKey || statuses["A11CEA11CEA11CEA"] != gpg.StatusValid {
+		t.Errorf("The people file of the checked out branch changed whose signatures are trusted: %v", statuses)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	fetchedRef := repository.RemoteNotesRef("origin", comment.Ref)
+	newComment := repository.Note(`{"timestamp": "0000000009", "author": "mallory", "description": "unsigned"}`)
+	fetched := append(repo.GetNotes(comment.Ref, repository.TestCommitB), newComment)
+	if err := repo.SetNotes(fetchedRef, repository.TestCommitB, fetched); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFetched(repo, "origin", repository.TestCommitB); err == nil {
+		t.Fatal("Unexpectedly verified an unsigned comment")
+	}
+	if err := Quarantine(repo, "origin", repository.TestCommitB); err != nil {
+		t.Fatal(err)
+	}
+	if notes := repo.GetNotes(fetchedRef, repository.TestCommitB); !reflect.DeepEqual(notes, repo.GetNotes(comment.Ref, repository.TestCommitB)) {
+		t.Errorf("The fetched notes were not replaced by the local ones: %q", notes)
+	}
+	quarantined, err := ListQuarantined(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []QuarantinedReview{{Remote: "origin", Revision: repository.TestCommitB}}
+	if !reflect.DeepEqual(quarantined, expected) {
+		t.Fatalf("Unexpected quarantined reviews: %v", quarantined)
+	}
+	if summary := quarantined[0].Summary(repo); summary == nil || len(summary.Comments) != 2 {
+		t.Errorf("Unexpected summary of the quarantined review: %+v", summary)
+	}
+
+	if err := quarantined[0].Accept(repo); err != nil {
+		t.Fatal(err)
+	}
+	if threads, err := GetComments(repo, repository.TestCommitB); err != nil || len(threads) != 2 {
+		t.Errorf("Unexpected comments after accepting the quarantined notes: %v, %v", threads, err)
+	}
+	if quarantined, _ := ListQuarantined(repo); len(quarantined) != 0 {
+		t.Errorf("Unexpected quarantined reviews after accepting them: %v", quarantined)
+	}
+}
+
+func TestQuarantineTopicsAndMarks(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	topic := discussion.New("mallory", "Unsigned topic", "", nil)
+	topic.Timestamp = "0000000010"
+	revision, err := StartDiscussion(repo, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := topic
+	closed.State = discussion.StateClosed
+	closed.Timestamp = "0000000011"
+	topicNote, err := closed.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetNotes(repository.RemoteNotesRef("origin", discussion.Ref), revision, []repository.Note{topicNote}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFetched(repo, "origin", revision); err == nil {
+		t.Error("Unexpectedly verified an unsigned discussion topic")
+	}
+
+	mark := checklist.New("mallory", checklist.Item{Number: 1, Text: "Tested"}, true)
+	mark.Timestamp = "0000000012"
+	markNote, err := mark.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetNotes(repository.RemoteNotesRef("origin", checklist.Ref), repository.TestCommitB, []repository.Note{markNote}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFetched(repo, "origin", repository.TestCommitB); err == nil {
+		t.Error("Unexpectedly verified an unsigned checklist mark")
+	}
+
+	for _, r := range []string{revision, repository.TestCommitB} {
+		if err := Quarantine(repo, "origin", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	quarantined, err := ListQuarantined(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarantined) != 2 {
+		t.Fatalf("Unexpected quarantined notes: %v", quarantined)
+	}
+	for _, q := range quarantined {
+		if q.Revision == revision && q.Title(repo) != "Unsigned topic" {
+			t.Errorf("Unexpected title of a quarantined discussion: %q", q.Title(repo))
+		}
+	}
+	if d, err := GetDiscussion(repo, revision); err != nil || d == nil || !d.IsOpen() {
+		t.Errorf("The quarantined topic changed the discussion: %+v, %v", d, err)
+	}
+}
+
+func TestDiscussions(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	first := discussion.New("alice", "Use plugins", "", []string{"rfc"})
+	first.Timestamp = "0000000010"
+	firstRevision, err := StartDiscussion(repo, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := discussion.New("bob", "Drop the old format", "", nil)
+	second.Timestamp = "0000000020"
+	if _, err := StartDiscussion(repo, second); err != nil {
+		t.Fatal(err)
+	}
+
+	discussions, err := ListDiscussions(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discussions) != 2 || discussions[0].Topic.Title != second.Title || discussions[1].Number != 1 {
+		t.Fatalf("Unexpected discussions: %+v", discussions)
+	}
+	d, err := GetDiscussion(repo, "#1")
+	if err != nil || d == nil || d.Revision != firstRevision {
+		t.Fatalf("Unexpected discussion #1: %+v, %v", d, err)
+	}
+	if missing, err := GetDiscussion(repo, "#3"); err != nil || missing != nil {
+		t.Errorf("Unexpected discussion #3: %+v, %v", missing, err)
+	}
+
+	reply := comment.New("bob", "Sounds good")
+	reply.Timestamp = "0000000030"
+	if err := d.AddComment(reply); err != nil {
+		t.Fatal(err)
+	}
+	closed := d.Topic
+	closed.State = discussion.StateClosed
+	closed.Timestamp = "0000000040"
+	if err := d.Update(closed); err != nil {
+		t.Fatal(err)
+	}
+	d, err = GetDiscussion(repo, firstRevision[:8])
+	if err != nil || d == nil {
+		t.Fatalf("Failed to load the discussion by its hash: %v", err)
+	}
+	if d.IsOpen() || d.Replies() != 1 || d.LastActivity() != closed.Timestamp || !d.Topic.HasLabel("rfc") {
+		t.Errorf("Unexpected state of the discussion: %+v", d)
+	}
+	if reviews := ListAll(repo); len(reviews) != len(unsortedListAll(repository.NewMockRepoForTest())) {
+		t.Errorf("Discussions were listed as reviews: %d", len(reviews))
+	}
+}
+
+func TestDetachedComments(t *testing.T) {
+	repo := repository.NewMockRepoForTest()
+	for _, path := range []string{"src/a.go", "src", "docs/README"} {
+		c := comment.New("alice", "About "+path)
+		c.Location = &comment.Location{Commit: repository.TestCommitB, Path: path}
+		if err := AddDetachedComment(repo, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if threads, err := GetDetachedComments(repo, "./src/"); err != nil || len(threads) != 1 || threads[0].Comment.Description != "About src" {
+		t.Errorf("Unexpected comments on the src directory: %v, %v", threads, err)
+	}
+	within, err := ListDetachedComments(repo, "src/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(within) != 2 || len(within["src"]) != 1 || len(within["src/a.go"]) != 1 {
+		t.Errorf("Unexpected comments within the src directory: %v", within)
+	}
+	// Comments on reviews have paths too, but are not detached comments.
+	if all, err := ListDetachedComments(repo, ""); err != nil || len(all) != 3 {
+		t.Errorf("Unexpected detached comments: %v, %v", all, err)
+	}
+
+	renames := map[string]string{"old/a.go": "src/a.go", "src/a.go": "lib/a.go"}
+	if p := currentPath("old/a.go", renames); p != "lib/a.go" {
+		t.Errorf("Unexpected current path of a renamed file: %q", p)
+	}
+	directory := comment.Location{Commit: repository.TestCommitB, Path: "src/", Range: &comment.Range{StartLine: 1}}
+	if err := directory.Check(repo); err == nil {
+		t.Error("Unexpectedly accepted a line range on a directory")
+	}
+}
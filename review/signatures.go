@@ -0,0 +1,277 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/gpg"
+	"github.com/KoviRobi/git-appraise/review/identity"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// checkSignatures checks the signatures on the request and on every comment
+// of the review, and records the results in their Signature fields.
+//
+// This must be done before the identities in the review are canonicalized,
+// as that changes the contents that were signed.
+func (r *Summary) checkSignatures(v gpg.Verifier, p gpg.Policy) {
+	r.Signature = gpg.Check(v, p, r.Request.Requester, &r.Request)
+	checkThreadSignatures(v, p, r.Comments)
+}
+
+// checkThreadSignatures checks the signatures on every comment in the given
+// threads, and records the results in their Signature fields.
+func checkThreadSignatures(v gpg.Verifier, p gpg.Policy, threads []CommentThread) {
+	for i := range threads {
+		thread := &threads[i]
+		thread.Signature = gpg.Check(v, p, thread.Comment.Author, &thread.Comment)
+		checkThreadSignatures(v, p, thread.Children)
+	}
+}
+
+// CheckSignatures checks the signatures on the request and on every comment
+// of the review, and records the results in their Signature fields.
+//
+// The keys that are trusted to sign for each person are taken from the
+// people file on the trusted ref.
+func (r *Summary) CheckSignatures() error {
+	policy, err := identity.LoadTrusted(r.Repo)
+	if err != nil {
+		return err
+	}
+	// The review has had its identities canonicalized, so the signatures
+	// are checked against a fresh copy of it, as it was signed.
+	signed, err := GetSummaryViaRefs(r.Repo, request.Ref, comment.Ref, r.Revision)
+	if err != nil {
+		return err
+	}
+	signed.checkSignatures(gpg.LoadConfig(r.Repo), policy)
+	r.Signature = signed.Signature
+	results := make(map[string]*gpg.Result)
+	var collect func(threads []CommentThread)
+	collect = func(threads []CommentThread) {
+		for _, thread := range threads {
+			results[thread.Hash] = thread.Signature
+			collect(thread.Children)
+		}
+	}
+	collect(signed.Comments)
+	var apply func(threads []CommentThread)
+	apply = func(threads []CommentThread) {
+		for i := range threads {
+			threads[i].Signature = results[threads[i].Hash]
+			apply(threads[i].Children)
+		}
+	}
+	apply(r.Comments)
+	return nil
+}
+
+// signatureError returns an error describing why the given signature is not
+// acceptable, or nil if it is.
+//
+// Signatures by keys that are not known to the trust policy are only
+// acceptable if it is not required that they be trusted.
+func signatureError(result *gpg.Result, requireTrusted bool) error {
+	switch result.Status {
+	case gpg.StatusValid:
+		return nil
+	case gpg.StatusUntrustedKey:
+		if !requireTrusted {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s", result)
+}
+
+// Verify returns whether or not a summary's request and comments are a)
+// signed, and b) that those signatures are verifiable.
+//
+// If the people file on the trusted ref records the keys of anyone, then the
+// signatures must also have been made using the keys of their authors.
+func (r *Summary) Verify() error {
+	people, err := identity.LoadTrusted(r.Repo)
+	if err != nil {
+		return err
+	}
+	r.checkSignatures(gpg.LoadConfig(r.Repo), people)
+	requireTrusted := people.HasKeys()
+	if err := signatureError(r.Signature, requireTrusted); err != nil {
+		return fmt.Errorf("couldn't verify request targeting: %q: %s",
+			r.Request.TargetRef, err)
+	}
+	return verifyThreads(r.Comments, requireTrusted)
+}
+
+// verifyThreads returns an error describing the first comment in the given
+// threads whose signature is not acceptable, or nil if they all are.
+//
+// The signatures must have already been checked.
+func verifyThreads(threads []CommentThread, requireTrusted bool) error {
+	for _, thread := range threads {
+		if err := signatureError(thread.Signature, requireTrusted); err != nil {
+			return fmt.Errorf("verification of comment [%s] failed: %s", thread.Hash, err)
+		}
+		if err := verifyThreads(thread.Children, requireTrusted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The kinds of items whose signatures are checked by an audit.
+const (
+	SignedRequest = "request"
+	SignedComment = "comment"
+	SignedMerge   = "merge"
+)
+
+// SignatureCheck is the result of checking the signature on a single request,
+// comment, or merge commit of a review.
+type SignatureCheck struct {
+	Review string `json:"review"`
+	Kind   string `json:"kind"`
+	// ID identifies the item: the timestamp of a request, or the hash of a
+	// comment or commit.
+	ID     string      `json:"id"`
+	Author string      `json:"author"`
+	Result *gpg.Result `json:"result"`
+	// Problem describes why the signature is not acceptable, if it is not.
+	Problem string `json:"problem,omitempty"`
+}
+
+// Auditor checks the signatures on everything that was written for reviews.
+type Auditor struct {
+	Repo     repository.Repo
+	Verifier gpg.Verifier
+	People   *identity.Registry
+	// CheckMerges controls whether the merge commits that submitted reviews
+	// are also required to be signed.
+	CheckMerges bool
+}
+
+// NewAuditor returns an auditor that uses the signature settings of the given
+// repository, and the people file on its trusted ref.
+func NewAuditor(repo repository.Repo) (*Auditor, error) {
+	people, err := identity.LoadTrusted(repo)
+	if err != nil {
+		return nil, err
+	}
+	return &Auditor{
+		Repo:     repo,
+		Verifier: gpg.LoadConfig(repo),
+		People:   people,
+	}, nil
+}
+
+func (a *Auditor) check(r *Review, kind, id, author string, result *gpg.Result) SignatureCheck {
+	check := SignatureCheck{
+		Review: r.Revision,
+		Kind:   kind,
+		ID:     id,
+		Author: author,
+		Result: result,
+	}
+	if err := signatureError(result, a.People.HasKeys()); err != nil {
+		check.Problem = err.Error()
+	}
+	return check
+}
+
+// Audit checks the signature on every request and comment that was written
+// for the given review, including those that have since been superseded by
+// later requests or edits.
+func (a *Auditor) Audit(r *Review) ([]SignatureCheck, error) {
+	var checks []SignatureCheck
+	requests := request.ParseAllValid(a.Repo.GetNotes(request.Ref, r.Revision))
+	sort.Stable(requestsByTimestamp(requests))
+	for i := range requests {
+		req := &requests[i]
+		result := gpg.Check(a.Verifier, a.People, req.Requester, req)
+		checks = append(checks, a.check(r, SignedRequest, req.Timestamp, req.Requester, result))
+	}
+
+	commentsByHash := comment.ParseAllValid(a.Repo.GetNotes(comment.Ref, r.Revision))
+	var hashes []string
+	for hash := range commentsByHash {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		ci, cj := commentsByHash[hashes[i]], commentsByHash[hashes[j]]
+		if ci.Timestamp != cj.Timestamp {
+			return ci.Timestamp < cj.Timestamp
+		}
+		return hashes[i] < hashes[j]
+	})
+	for _, hash := range hashes {
+		c := commentsByHash[hash]
+		result := gpg.Check(a.Verifier, a.People, c.Author, &c)
+		checks = append(checks, a.check(r, SignedComment, hash, c.Author, result))
+	}
+
+	if !a.CheckMerges || !r.Submitted || r.IsAbandoned() {
+		return checks, nil
+	}
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	merge, err := a.Repo.FindMergeCommit(head, r.Request.TargetRef)
+	if err != nil {
+		return nil, err
+	}
+	if merge == "" {
+		// The review was submitted without a merge commit.
+		return checks, nil
+	}
+	details, err := a.Repo.GetCommitDetails(merge)
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.checkCommit(merge, details.CommitterEmail)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, a.check(r, SignedMerge, merge, details.CommitterEmail, result))
+	return checks, nil
+}
+
+// checkCommit checks the signature on the given commit, which states that it
+// was committed by the given identity.
+func (a *Auditor) checkCommit(commit, committer string) (*gpg.Result, error) {
+	status, key, err := a.Repo.GetCommitSignature(commit)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "N":
+		return &gpg.Result{Status: gpg.StatusUnsigned}, nil
+	case "G", "U":
+		// git has verified the signature, but only the policy decides
+		// whether the key may sign for the committer.
+		return gpg.Trust(a.People, committer, key), nil
+	}
+	return &gpg.Result{
+		Status:  gpg.StatusInvalid,
+		Key:     key,
+		Message: fmt.Sprintf("git reports the signature status as %q", status),
+	}, nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+
+	"github.com/KoviRobi/git-appraise/repository"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// Stack represents a tree of stacked reviews.
+//
+// Every child review depends upon (is stacked on top of) its parent.
+type Stack struct {
+	Summary  *Summary
+	Children []*Stack
+}
+
+// GetDependencies returns the summaries of the reviews that the given review depends upon.
+func (r *Summary) GetDependencies() ([]*Summary, error) {
+	var dependencies []*Summary
+	for _, revision := range r.Request.DependsOn {
+		dependency, err := GetSummary(r.Repo, revision)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the review %.12s depended upon: %v", revision, err)
+		}
+		dependencies = append(dependencies, dependency)
+	}
+	return dependencies, nil
+}
+
+// GetUnsubmittedDependencies returns the summaries of the reviews that the
+// given review depends upon, but which have not yet been submitted.
+func (r *Summary) GetUnsubmittedDependencies() ([]*Summary, error) {
+	dependencies, err := r.GetDependencies()
+	if err != nil {
+		return nil, err
+	}
+	var unsubmitted []*Summary
+	for _, dependency := range dependencies {
+		if !dependency.Submitted {
+			unsubmitted = append(unsubmitted, dependency)
+		}
+	}
+	return unsubmitted, nil
+}
+
+// getDependencyHeadCommit returns the head commit of the first open review
+// that the given review depends upon, or the empty string if there is none.
+func (r *Review) getDependencyHeadCommit() (string, error) {
+	dependencies, err := r.GetDependencies()
+	if err != nil {
+		return "", err
+	}
+	for _, dependency := range dependencies {
+		if !dependency.IsOpen() {
+			continue
+		}
+		details, err := dependency.Details()
+		if err != nil {
+			return "", err
+		}
+		return details.GetHeadCommit()
+	}
+	return "", nil
+}
+
+// GetStack returns the stack of reviews that the given review is a part of.
+//
+// The returned stack is rooted at the bottom-most review, found by following
+// the first dependency of each review, and includes every review that
+// (transitively) depends upon it.
+func GetStack(repo repository.Repo, revision string) (*Stack, error) {
+	commitHash, err := repo.GetCommitHash(revision)
+	if err != nil {
+		return nil, err
+	}
+	return getStack(ListAll(repo), commitHash)
+}
+
+func getStack(reviews []Summary, revision string) (*Stack, error) {
+	summaries := make(map[string]*Summary)
+	dependents := make(map[string][]*Summary)
+	// Reviews are listed newest first, but we want the stack to be displayed in
+	// the order in which the reviews were requested.
+	for i := len(reviews) - 1; i >= 0; i-- {
+		summary := &reviews[i]
+		summaries[summary.Revision] = summary
+		for _, dependency := range summary.Request.DependsOn {
+			dependents[dependency] = append(dependents[dependency], summary)
+		}
+	}
+	root, ok := summaries[revision]
+	if !ok {
+		return nil, fmt.Errorf("There is no review for the revision %q", revision)
+	}
+	visited := map[string]bool{root.Revision: true}
+	for len(root.Request.DependsOn) > 0 {
+		parent, ok := summaries[root.Request.DependsOn[0]]
+		if !ok || visited[parent.Revision] {
+			break
+		}
+		visited[parent.Revision] = true
+		root = parent
+	}
+	return buildStack(root, dependents, make(map[string]bool)), nil
+}
+
+func buildStack(root *Summary, dependents map[string][]*Summary, visited map[string]bool) *Stack {
+	visited[root.Revision] = true
+	stack := &Stack{Summary: root}
+	for _, dependent := range dependents[root.Revision] {
+		if visited[dependent.Revision] {
+			continue
+		}
+		stack.Children = append(stack.Children, buildStack(dependent, dependents, visited))
+	}
+	return stack
+}
+
+// RebaseOnto rebases the review onto the given commit, replaying only the
+// commits after the review's base commit.
+//
+// This is used to restack a review after the review it depends upon has
+// changed. The base commit of the review is updated to the new commit.
+//
+// The ref that was checked out beforehand is checked out again afterwards.
+// If the rebase stops, e.g. because of conflicts, then it is aborted, leaving
+// the review as it was.
+//
+// If the 'archivePrevious' argument is true, then the previous head of the
+// review will be added to the 'refs/devtools/archives/reviews' ref prior
+// to being rewritten.
+func (r *Review) RebaseOnto(onto string, archivePrevious bool) error {
+	upstream := r.Request.BaseCommit
+	if upstream == "" {
+		var err error
+		upstream, err = r.GetBaseCommit()
+		if err != nil {
+			return err
+		}
+	}
+	if archivePrevious {
+		orig, err := r.GetHeadCommit()
+		if err != nil {
+			return err
+		}
+		if err := r.Repo.ArchiveRef(orig, archiveRef); err != nil {
+			return err
+		}
+	}
+	originalRef, err := r.Repo.GetHeadRef()
+	if err != nil {
+		// HEAD is detached, so restore the commit it points to.
+		originalRef, err = r.Repo.GetCommitHash("HEAD")
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.Repo.SwitchToRef(r.Request.ReviewRef); err != nil {
+		return err
+	}
+	if err := r.Repo.RebaseRefOnto(onto, upstream); err != nil {
+		if stopped, stateErr := r.Repo.IsRebaseInProgress(); stateErr != nil {
+			return stateErr
+		} else if stopped {
+			if abortErr := r.Repo.AbortRebase(); abortErr != nil {
+				return abortErr
+			}
+		}
+		if switchErr := r.Repo.SwitchToRef(originalRef); switchErr != nil {
+			return switchErr
+		}
+		return fmt.Errorf("Failed to rebase the review %.12s onto %.12s: %v", r.Revision, onto, err)
+	}
+
+	alias, err := r.Repo.GetCommitHash("HEAD")
+	if err != nil {
+		return err
+	}
+	if err := r.Repo.SwitchToRef(originalRef); err != nil {
+		return err
+	}
+	r.Request.Alias = alias
+	r.Request.Modified = currentTimestamp()
+	r.Request.BaseCommit = onto
+	newNote, err := r.Request.Write()
+	if err != nil {
+		return err
+	}
+	return r.Repo.AppendNote(request.Ref, r.Revision, newNote)
+}
+
+// Restack rebases every open review in the stack on top of its parent.
+//
+// The root of the stack is not itself rebased; this is meant to be called
+// after the root review has been updated (e.g. amended or rebased).
+func (s *Stack) Restack(archivePrevious bool) error {
+	root, err := s.Summary.Details()
+	if err != nil {
+		return err
+	}
+	rootHead, err := root.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+	for _, child := range s.Children {
+		if !child.Summary.IsOpen() {
+			continue
+		}
+		if len(child.Summary.Request.DependsOn) > 1 {
+			return fmt.Errorf("Unable to restack the review %.12s as it depends on more than one review", child.Summary.Revision)
+		}
+		childReview, err := child.Summary.Details()
+		if err != nil {
+			return err
+		}
+		childHead, err := childReview.GetHeadCommit()
+		if err != nil {
+			return err
+		}
+		// Reviews that are already on top of their parent's head are left alone.
+		if isAncestor, err := childReview.Repo.IsAncestor(rootHead, childHead); err != nil {
+			return err
+		} else if !isAncestor {
+			if err := childReview.RebaseOnto(rootHead, archivePrevious); err != nil {
+				return err
+			}
+		}
+		if err := child.Restack(archivePrevious); err != nil {
+			return err
+		}
+	}
+	return nil
+}
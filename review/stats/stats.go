@@ -0,0 +1,294 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package stats computes aggregate metrics about code reviews.
+//
+// All of the metrics are derived from the timestamps recorded in the review
+// requests and comments, along with the commits in each review.
+package stats
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/review"
+)
+
+// Ways in which the metrics can be grouped.
+const (
+	ByReviewer = "reviewer"
+	ByTarget   = "target"
+)
+
+// ReviewStats holds the raw data about a single review used for computing metrics.
+//
+// All times are in seconds since the Unix epoch, with zero meaning "never".
+type ReviewStats struct {
+	Revision  string
+	Requester string
+	TargetRef string
+	// Requested is the time of the first request for the review.
+	Requested int64
+	// FirstComment is the time of the first comment by each reviewer.
+	FirstComment map[string]int64
+	// Accepted is the time of the first acceptance by each reviewer.
+	Accepted map[string]int64
+	// Rounds is the number of distinct commits that reviewers commented upon.
+	Rounds       int
+	Commits      int
+	LinesAdded   int
+	LinesDeleted int
+	Submitted    bool
+	Abandoned    bool
+}
+
+// Metrics holds the aggregate metrics for a group of reviews.
+//
+// Durations are in seconds, and are computed only over the reviews for which
+// the corresponding event occurred.
+type Metrics struct {
+	Key                      string  `json:"key"`
+	Reviews                  int     `json:"reviews"`
+	Submitted                int     `json:"submitted"`
+	Abandoned                int     `json:"abandoned"`
+	AbandonRate              float64 `json:"abandonRate"`
+	MeanTimeToFirstComment   int64   `json:"meanTimeToFirstComment"`
+	MedianTimeToFirstComment int64   `json:"medianTimeToFirstComment"`
+	MeanTimeToAccept         int64   `json:"meanTimeToAccept"`
+	MedianTimeToAccept       int64   `json:"medianTimeToAccept"`
+	MeanRounds               float64 `json:"meanRounds"`
+	MeanCommits              float64 `json:"meanCommits"`
+	MeanLinesChanged         float64 `json:"meanLinesChanged"`
+}
+
+func parseTimestamp(timestamp string) int64 {
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return t
+}
+
+func setEarliest(times map[string]int64, user string, t int64) {
+	if previous := times[user]; t > 0 && (previous == 0 || t < previous) {
+		times[user] = t
+	}
+}
+
+// New computes the raw stats for the given review from its notes.
+//
+// This does not read the commits of the review; use LoadSize for that.
+func New(r *review.Summary) *ReviewStats {
+	s := &ReviewStats{
+		Revision:     r.Revision,
+		Requester:    r.Request.Requester,
+		TargetRef:    r.Request.TargetRef,
+		FirstComment: make(map[string]int64),
+		Accepted:     make(map[string]int64),
+		Submitted:    r.Submitted,
+		Abandoned:    r.IsAbandoned(),
+	}
+	s.Requested = parseTimestamp(r.Request.Timestamp)
+	for _, req := range r.AllRequests {
+		if t := parseTimestamp(req.Timestamp); t > 0 && t < s.Requested {
+			s.Requested = t
+		}
+		// Abandoning a review clears its target, so fall back to the last one requested.
+		if s.TargetRef == "" && req.TargetRef != "" {
+			s.TargetRef = req.TargetRef
+		}
+	}
+	for _, reviewer := range r.Request.Reviewers {
+		s.FirstComment[reviewer] = 0
+	}
+	commentedCommits := make(map[string]bool)
+	var visit func(threads []review.CommentThread)
+	visit = func(threads []review.CommentThread) {
+		for _, thread := range threads {
+			c := thread.Comment
+			if c.Author != "" && c.Author != s.Requester {
+				t := parseTimestamp(c.Timestamp)
+				setEarliest(s.FirstComment, c.Author, t)
+				if c.Resolved != nil && *c.Resolved {
+					setEarliest(s.Accepted, c.Author, t)
+				}
+				commit := r.Revision
+				if c.Location != nil && c.Location.Commit != "" {
+					commit = c.Location.Commit
+				}
+				commentedCommits[commit] = true
+			}
+			visit(thread.Children)
+		}
+	}
+	visit(r.Comments)
+	s.Rounds = len(commentedCommits)
+	return s
+}
+
+// LoadSize reads the number of commits and lines changed in the review.
+func (s *ReviewStats) LoadSize(r *review.Review) error {
+	base, err := r.GetBaseCommit()
+	if err != nil {
+		return err
+	}
+	head, err := r.GetHeadCommit()
+	if err != nil {
+		return err
+	}
+	commits, err := r.Repo.ListCommitsBetween(base, head)
+	if err != nil {
+		return err
+	}
+	s.Commits = len(commits)
+	numstat, err := r.Repo.Diff(base, head, "--numstat")
+	if err != nil {
+		return err
+	}
+	s.LinesAdded, s.LinesDeleted = parseNumstat(numstat)
+	return nil
+}
+
+// parseNumstat sums the lines added and deleted in the output of "git diff --numstat".
+//
+// Binary files, which are reported with a "-" in place of the line counts, are ignored.
+func parseNumstat(numstat string) (added, deleted int) {
+	for _, line := range strings.Split(numstat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		a, aErr := strconv.Atoi(fields[0])
+		d, dErr := strconv.Atoi(fields[1])
+		if aErr == nil && dErr == nil {
+			added += a
+			deleted += d
+		}
+	}
+	return added, deleted
+}
+
+// firstComment returns the time of the earliest comment by any reviewer.
+func (s *ReviewStats) firstComment() int64 {
+	return earliest(s.FirstComment)
+}
+
+// firstAccept returns the time of the earliest acceptance by any reviewer.
+func (s *ReviewStats) firstAccept() int64 {
+	return earliest(s.Accepted)
+}
+
+func earliest(times map[string]int64) int64 {
+	var result int64
+	for _, t := range times {
+		if t > 0 && (result == 0 || t < result) {
+			result = t
+		}
+	}
+	return result
+}
+
+type accumulator struct {
+	metrics       Metrics
+	firstComments []int64
+	accepts       []int64
+	rounds        int
+	commits       int
+	linesChanged  int
+}
+
+func (a *accumulator) add(s *ReviewStats, firstComment, accepted int64) {
+	a.metrics.Reviews++
+	if s.Submitted {
+		a.metrics.Submitted++
+	}
+	if s.Abandoned {
+		a.metrics.Abandoned++
+	}
+	if firstComment > 0 && s.Requested > 0 {
+		a.firstComments = append(a.firstComments, max(0, firstComment-s.Requested))
+	}
+	if accepted > 0 && s.Requested > 0 {
+		a.accepts = append(a.accepts, max(0, accepted-s.Requested))
+	}
+	a.rounds += s.Rounds
+	a.commits += s.Commits
+	a.linesChanged += s.LinesAdded + s.LinesDeleted
+}
+
+func (a *accumulator) finish() Metrics {
+	m := a.metrics
+	n := float64(m.Reviews)
+	m.AbandonRate = float64(m.Abandoned) / n
+	m.MeanTimeToFirstComment, m.MedianTimeToFirstComment = meanAndMedian(a.firstComments)
+	m.MeanTimeToAccept, m.MedianTimeToAccept = meanAndMedian(a.accepts)
+	m.MeanRounds = float64(a.rounds) / n
+	m.MeanCommits = float64(a.commits) / n
+	m.MeanLinesChanged = float64(a.linesChanged) / n
+	return m
+}
+
+func meanAndMedian(durations []int64) (mean, median int64) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total int64
+	for _, d := range durations {
+		total += d
+	}
+	mean = total / int64(len(durations))
+	middle := len(durations) / 2
+	if len(durations)%2 == 0 {
+		median = (durations[middle-1] + durations[middle]) / 2
+	} else {
+		median = durations[middle]
+	}
+	return mean, median
+}
+
+// Aggregate computes the metrics for the given reviews, grouped either by
+// reviewer or by target ref.
+//
+// When grouping by reviewer, each review counts towards every one of its
+// reviewers, and the times are those of that reviewer's own comments.
+func Aggregate(reviews []*ReviewStats, by string) []Metrics {
+	groups := make(map[string]*accumulator)
+	group := func(key string) *accumulator {
+		a, ok := groups[key]
+		if !ok {
+			a = &accumulator{metrics: Metrics{Key: key}}
+			groups[key] = a
+		}
+		return a
+	}
+	for _, s := range reviews {
+		if by == ByReviewer {
+			for reviewer, firstComment := range s.FirstComment {
+				group(reviewer).add(s, firstComment, s.Accepted[reviewer])
+			}
+		} else {
+			group(s.TargetRef).add(s, s.firstComment(), s.firstAccept())
+		}
+	}
+	var metrics []Metrics
+	for _, a := range groups {
+		metrics = append(metrics, a.finish())
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Key < metrics[j].Key })
+	return metrics
+}
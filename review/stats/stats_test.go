@@ -0,0 +1,115 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/KoviRobi/git-appraise/review"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+func TestAggregate(t *testing.T) {
+	accepted := true
+	first := review.Summary{
+		Revision: "A",
+		Request: request.Request{
+			Timestamp: "1000",
+			Requester: "alice",
+			Reviewers: []string{"bob", "carol"},
+			TargetRef: "refs/heads/master",
+		},
+		AllRequests: []request.Request{
+			request.Request{Timestamp: "1000"},
+		},
+		Comments: []review.CommentThread{
+			review.CommentThread{
+				Comment: comment.Comment{Timestamp: "1100", Author: "bob"},
+				Children: []review.CommentThread{
+					review.CommentThread{
+						Comment: comment.Comment{Timestamp: "1150", Author: "alice"},
+					},
+				},
+			},
+			review.CommentThread{
+				Comment: comment.Comment{
+					Timestamp: "1300",
+					Author:    "bob",
+					Resolved:  &accepted,
+					Location:  &comment.Location{Commit: "B"},
+				},
+			},
+		},
+	}
+	second := review.Summary{
+		Revision: "C",
+		Request: request.Request{
+			Timestamp: "2000",
+			Requester: "carol",
+			Reviewers: []string{"bob"},
+		},
+		AllRequests: []request.Request{
+			request.Request{Timestamp: "2000", TargetRef: "refs/heads/master"},
+			request.Request{Timestamp: "2500"},
+		},
+		Comments: []review.CommentThread{
+			review.CommentThread{
+				Comment: comment.Comment{Timestamp: "2500", Author: "bob"},
+			},
+		},
+	}
+
+	firstStats := New(&first)
+	if firstStats.Rounds != 2 {
+		t.Errorf("Unexpected number of rounds: %d", firstStats.Rounds)
+	}
+	secondStats := New(&second)
+	if !secondStats.Abandoned || secondStats.TargetRef != "refs/heads/master" {
+		t.Errorf("Unexpected stats for an abandoned review: %+v", secondStats)
+	}
+
+	byTarget := Aggregate([]*ReviewStats{firstStats, secondStats}, ByTarget)
+	if len(byTarget) != 1 {
+		t.Fatalf("Unexpected metrics by target: %+v", byTarget)
+	}
+	m := byTarget[0]
+	if m.Reviews != 2 || m.Abandoned != 1 || m.AbandonRate != 0.5 {
+		t.Errorf("Unexpected review counts: %+v", m)
+	}
+	if m.MeanTimeToFirstComment != 300 || m.MedianTimeToFirstComment != 300 {
+		t.Errorf("Unexpected time to first comment: %+v", m)
+	}
+	if m.MeanTimeToAccept != 300 {
+		t.Errorf("Unexpected time to accept: %+v", m)
+	}
+
+	byReviewer := Aggregate([]*ReviewStats{firstStats, secondStats}, ByReviewer)
+	if len(byReviewer) != 2 || byReviewer[0].Key != "bob" || byReviewer[1].Key != "carol" {
+		t.Fatalf("Unexpected metrics by reviewer: %+v", byReviewer)
+	}
+	if byReviewer[0].Reviews != 2 || byReviewer[1].Reviews != 1 || byReviewer[1].MeanTimeToFirstComment != 0 {
+		t.Errorf("Unexpected metrics by reviewer: %+v", byReviewer)
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	added, deleted := parseNumstat("1\t2\ta.txt\n-\t-\timage.png\n10\t0\tb.txt\n")
+	if added != 11 || deleted != 2 {
+		t.Errorf("Unexpected line counts: +%d -%d", added, deleted)
+	}
+}
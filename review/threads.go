@@ -0,0 +1,113 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/review/comment"
+)
+
+// ErrNoMatchingComment indicates that no comment matches a given hash.
+var ErrNoMatchingComment = errors.New("There is no matching comment.")
+
+// FindThread returns the comment thread whose hash starts with the given
+// (possibly abbreviated) hash.
+//
+// An error is returned if no thread matches, or if the hash is ambiguous.
+func FindThread(threads []CommentThread, hash string) (*CommentThread, error) {
+	if hash == "" {
+		return nil, ErrNoMatchingComment
+	}
+	var matches []*CommentThread
+	var find func(threads []CommentThread)
+	find = func(threads []CommentThread) {
+		for i := range threads {
+			thread := &threads[i]
+			if strings.HasPrefix(thread.Hash, hash) {
+				matches = append(matches, thread)
+			}
+			find(thread.Children)
+		}
+	}
+	find(threads)
+	if len(matches) == 0 {
+		return nil, ErrNoMatchingComment
+	}
+	if len(matches) > 1 {
+		var hashes []string
+		for _, match := range matches {
+			hashes = append(hashes, match.Hash)
+		}
+		return nil, fmt.Errorf("The comment hash %q is ambiguous; it matches:\n  %s", hash, strings.Join(hashes, "\n  "))
+	}
+	return matches[0], nil
+}
+
+// ResolveCommentHash expands the given (possibly abbreviated) comment hash
+// into the full hash of the matching comment thread.
+func ResolveCommentHash(threads []CommentThread, hash string) (string, error) {
+	thread, err := FindThread(threads, hash)
+	if err != nil {
+		return "", err
+	}
+	return thread.Hash, nil
+}
+
+// HideRetracted returns the given comment threads without the ones that have
+// been retracted.
+//
+// Retracted threads that still have replies which are not retracted are
+// kept, so that those replies remain visible.
+func HideRetracted(threads []CommentThread) []CommentThread {
+	var visible []CommentThread
+	for _, thread := range threads {
+		thread.Children = HideRetracted(thread.Children)
+		if thread.Comment.Retracted && len(thread.Children) == 0 {
+			continue
+		}
+		visible = append(visible, thread)
+	}
+	return visible
+}
+
+// Edit returns a new version of the thread's root comment, with the given
+// author and description.
+//
+// The new version keeps the location, parent, and resolved bit of the
+// current version of the comment.
+func (thread *CommentThread) Edit(author, description string) comment.Comment {
+	c := comment.New(author, description)
+	c.Original = thread.Hash
+	c.Parent = thread.Comment.Parent
+	c.Location = thread.Comment.Location
+	c.Resolved = thread.Comment.Resolved
+	return c
+}
+
+// Retract returns a tombstone version of the thread's root comment, which
+// withdraws that comment.
+func (thread *CommentThread) Retract(author string) comment.Comment {
+	c := comment.New(author, "")
+	c.Original = thread.Hash
+	c.Parent = thread.Comment.Parent
+	c.Location = thread.Comment.Location
+	c.Retracted = true
+	return c
+}
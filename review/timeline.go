@@ -0,0 +1,219 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/review/analyses"
+	"github.com/KoviRobi/git-appraise/review/ci"
+	"github.com/KoviRobi/git-appraise/review/comment"
+	"github.com/KoviRobi/git-appraise/review/request"
+)
+
+// EventType identifies the kind of an event in the timeline of a review.
+type EventType string
+
+// The types of events that can occur in the timeline of a review.
+const (
+	EventRequested EventType = "requested"
+	EventUpdated   EventType = "updated"
+	EventRebased   EventType = "rebased"
+	EventAbandoned EventType = "abandoned"
+	EventReopened  EventType = "reopened"
+	EventComment   EventType = "comment"
+	EventAccepted  EventType = "accepted"
+	EventRejected  EventType = "rejected"
+	EventEdited    EventType = "edited"
+	EventRetracted EventType = "retracted"
+	EventCI        EventType = "ci"
+	EventAnalysis  EventType = "analysis"
+	EventSubmitted EventType = "submitted"
+)
+
+// Event represents a single thing that happened during the lifetime of a review.
+//
+// Exactly one of the Request, Comment, Report, and Analysis fields is set,
+// depending upon the type of the event, except for submission events which
+// have none of them set.
+type Event struct {
+	Type      EventType `json:"type"`
+	Timestamp string    `json:"timestamp,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	// Commit is the commit that the event refers to, if any.
+	Commit string `json:"commit,omitempty"`
+	// CommentHash is the hash of the thread that a comment event belongs to.
+	CommentHash string           `json:"commentHash,omitempty"`
+	Request     *request.Request `json:"request,omitempty"`
+	Comment     *comment.Comment `json:"comment,omitempty"`
+	Report      *ci.Report       `json:"report,omitempty"`
+	Analysis    *analyses.Report `json:"analysis,omitempty"`
+}
+
+type eventsByTimestamp []Event
+
+// Interface methods for sorting events by timestamp.
+//
+// Events with no timestamp are sorted after all of the others.
+func (events eventsByTimestamp) Len() int      { return len(events) }
+func (events eventsByTimestamp) Swap(i, j int) { events[i], events[j] = events[j], events[i] }
+func (events eventsByTimestamp) Less(i, j int) bool {
+	ti, erri := strconv.ParseInt(events[i].Timestamp, 10, 64)
+	tj, errj := strconv.ParseInt(events[j].Timestamp, 10, 64)
+	if erri != nil || errj != nil {
+		return erri == nil && errj != nil
+	}
+	return ti < tj
+}
+
+// Summary returns a single-line, human readable, description of the event.
+func (e *Event) Summary() string {
+	firstLine := func(s string) string {
+		return strings.SplitN(s, "\n", 2)[0]
+	}
+	switch {
+	case e.Type == EventRebased && e.Request.BaseCommit != "":
+		return fmt.Sprintf("onto %.12s", e.Request.BaseCommit)
+	case e.Request != nil:
+		return firstLine(e.Request.Description)
+	case e.Comment != nil:
+		return firstLine(e.Comment.Description)
+	case e.Report != nil:
+		return strings.TrimSpace(e.Report.Status + " " + e.Report.URL)
+	case e.Analysis != nil:
+		return strings.TrimSpace(e.Analysis.Status + " " + e.Analysis.URL)
+	}
+	return ""
+}
+
+// requestEventType determines what changed between two consecutive requests for a review.
+func requestEventType(previous, current *request.Request) EventType {
+	if previous == nil {
+		return EventRequested
+	}
+	if current.TargetRef == "" && previous.TargetRef != "" {
+		return EventAbandoned
+	}
+	if current.TargetRef != "" && previous.TargetRef == "" {
+		return EventReopened
+	}
+	if current.Alias != previous.Alias || current.BaseCommit != previous.BaseCommit {
+		return EventRebased
+	}
+	return EventUpdated
+}
+
+func commentEventType(c *comment.Comment) EventType {
+	if c.Resolved == nil {
+		return EventComment
+	}
+	if *c.Resolved {
+		return EventAccepted
+	}
+	return EventRejected
+}
+
+func threadEvents(threads []CommentThread) []Event {
+	var events []Event
+	for _, thread := range threads {
+		original := thread.Original
+		if original == nil {
+			original = &thread.Comment
+		}
+		event := Event{
+			Type:        commentEventType(original),
+			Timestamp:   original.Timestamp,
+			Author:      original.Author,
+			CommentHash: thread.Hash,
+			Comment:     original,
+		}
+		if original.Location != nil {
+			event.Commit = original.Location.Commit
+		}
+		events = append(events, event)
+		for _, edit := range thread.Edits {
+			eventType := EventEdited
+			if edit.Retracted {
+				eventType = EventRetracted
+			}
+			events = append(events, Event{
+				Type:        eventType,
+				Timestamp:   edit.Timestamp,
+				Author:      edit.Author,
+				CommentHash: thread.Hash,
+				Comment:     edit,
+			})
+		}
+		events = append(events, threadEvents(thread.Children)...)
+	}
+	return events
+}
+
+// Timeline returns every event in the review, in chronological order.
+//
+// The CI reports and analyses included are those for the current head of the
+// review. Since the time of submission is not recorded, a submission event
+// has no timestamp and is always last.
+func (r *Review) Timeline() []Event {
+	var events []Event
+	var previous *request.Request
+	for i := range r.AllRequests {
+		current := &r.AllRequests[i]
+		commit := r.Revision
+		if current.Alias != "" {
+			commit = current.Alias
+		}
+		events = append(events, Event{
+			Type:      requestEventType(previous, current),
+			Timestamp: current.LastModified(),
+			Author:    current.Requester,
+			Commit:    commit,
+			Request:   current,
+		})
+		previous = current
+	}
+	events = append(events, threadEvents(r.Comments)...)
+	for i := range r.Reports {
+		report := &r.Reports[i]
+		events = append(events, Event{
+			Type:      EventCI,
+			Timestamp: report.Timestamp,
+			Author:    report.Agent,
+			Report:    report,
+		})
+	}
+	for i := range r.Analyses {
+		analysis := &r.Analyses[i]
+		events = append(events, Event{
+			Type:      EventAnalysis,
+			Timestamp: analysis.Timestamp,
+			Analysis:  analysis,
+		})
+	}
+	sort.Stable(eventsByTimestamp(events))
+	if r.Submitted {
+		head, _ := r.GetHeadCommit()
+		events = append(events, Event{
+			Type:   EventSubmitted,
+			Commit: head,
+		})
+	}
+	return events
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2015 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package review
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/KoviRobi/git-appraise/repository"
+)
+
+// The keys of the trailers that can be added to submitted commits.
+const (
+	TrailerReviewedBy = "Reviewed-by"
+	TrailerReviewID   = "Review-Id"
+	TrailerAckedBy    = "Acked-by"
+)
+
+// ackRegexp matches comments which acknowledge a change without accepting it.
+var ackRegexp = regexp.MustCompile(`(?i)^(ack(ed)?|lgtm|\+1)\b`)
+
+// trailerRegexp matches a single line of a trailer block.
+var trailerRegexp = regexp.MustCompile(`^[\w-]+: `)
+
+// latestVerdicts records the latest accept or reject verdict of each comment author.
+func latestVerdicts(threads []CommentThread, verdicts map[string]bool, timestamps map[string]string) {
+	for _, thread := range threads {
+		c := thread.Comment
+		if c.Resolved != nil && c.Timestamp >= timestamps[c.Author] {
+			verdicts[c.Author] = *c.Resolved
+			timestamps[c.Author] = c.Timestamp
+		}
+		latestVerdicts(thread.Children, verdicts, timestamps)
+	}
+}
+
+// ackers records the authors of comments acknowledging the change.
+func ackers(threads []CommentThread, result map[string]bool) {
+	for _, thread := range threads {
+		if ackRegexp.MatchString(strings.TrimSpace(thread.Comment.Description)) {
+			result[thread.Comment.Author] = true
+		}
+		ackers(thread.Children, result)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Trailers returns the trailers describing the review, for adding to its
+// submitted commits.
+//
+// These are a "Reviewed-by" trailer for each user whose latest verdict was
+// to accept the review, an "Acked-by" trailer for each other user who
+// acknowledged the review in a comment (e.g. "LGTM" or "+1"), and a
+// "Review-Id" trailer with the revision of the review.
+func (r *Summary) Trailers() []string {
+	verdicts := make(map[string]bool)
+	latestVerdicts(r.Comments, verdicts, make(map[string]string))
+	reviewedBy := make(map[string]bool)
+	for author, accepted := range verdicts {
+		if accepted && author != r.Request.Requester {
+			reviewedBy[author] = true
+		}
+	}
+	ackedBy := make(map[string]bool)
+	ackers(r.Comments, ackedBy)
+
+	var trailers []string
+	for _, author := range sortedKeys(reviewedBy) {
+		trailers = append(trailers, TrailerReviewedBy+": "+author)
+	}
+	for _, author := range sortedKeys(ackedBy) {
+		if !reviewedBy[author] && author != r.Request.Requester {
+			trailers = append(trailers, TrailerAckedBy+": "+author)
+		}
+	}
+	return append(trailers, TrailerReviewID+": "+r.Revision)
+}
+
+// AppendTrailers appends the given trailers to a commit message, skipping
+// any that it already contains.
+//
+// The trailers are added to the trailer block at the end of the message if
+// there is one, or else as a new paragraph.
+func AppendTrailers(message string, trailers []string) string {
+	message = strings.TrimRight(message, "\n")
+	lines := strings.Split(message, "\n")
+	existing := make(map[string]bool)
+	for _, line := range lines {
+		existing[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, trailer := range trailers {
+		if !existing[trailer] {
+			missing = append(missing, trailer)
+		}
+	}
+	if len(missing) == 0 {
+		return message + "\n"
+	}
+
+	inTrailerBlock := len(lines) > 1
+	for i := len(lines) - 1; i >= 0 && lines[i] != ""; i-- {
+		if !trailerRegexp.MatchString(lines[i]) || i == 0 {
+			inTrailerBlock = false
+			break
+		}
+	}
+	separator := "\n\n"
+	if message == "" {
+		separator = ""
+	} else if inTrailerBlock {
+		separator = "\n"
+	}
+	return message + separator + strings.Join(missing, "\n") + "\n"
+}
+
+// AddTrailers rewrites the commits after 'base' up to and including 'head'
+// so that each of their messages include the given trailers, and returns the
+// rewritten head.
+func AddTrailers(repo repository.Repo, base, head string, trailers []string, sign bool) (string, error) {
+	commits, err := repo.ListCommitsBetween(base, head)
+	if err != nil {
+		return "", err
+	}
+	toRewrite := make(map[string]bool)
+	for _, commit := range commits {
+		toRewrite[commit] = true
+	}
+	// Parents are always rewritten before their children, so that the
+	// children can point to the rewritten parents.
+	rewritten := make(map[string]string)
+	var rewrite func(commit string) (string, error)
+	rewrite = func(commit string) (string, error) {
+		if !toRewrite[commit] {
+			return commit, nil
+		}
+		if newCommit, ok := rewritten[commit]; ok {
+			return newCommit, nil
+		}
+		details, err := repo.GetCommitDetails(commit)
+		if err != nil {
+			return "", err
+		}
+		var parents []string
+		for _, parent := range details.Parents {
+			newParent, err := rewrite(parent)
+			if err != nil {
+				return "", err
+			}
+			parents = append(parents, newParent)
+		}
+		message, err := repo.GetCommitMessage(commit)
+		if err != nil {
+			return "", err
+		}
+		newCommit, err := repo.RewriteCommit(commit, parents, AppendTrailers(message, trailers), sign)
+		if err != nil {
+			return "", err
+		}
+		rewritten[commit] = newCommit
+		return newCommit, nil
+	}
+	headCommit, err := repo.GetCommitHash(head)
+	if err != nil {
+		return "", err
+	}
+	return rewrite(headCommit)
+}
+
+// Squash creates a single commit on top of 'base', with the same contents
+// and author as 'head', and with the given message, and returns its hash.
+func Squash(repo repository.Repo, base, head, message string, sign bool) (string, error) {
+	return repo.RewriteCommit(head, []string{base}, message, sign)
+}